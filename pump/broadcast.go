@@ -0,0 +1,182 @@
+package pump
+
+import (
+	"slices"
+	"sync"
+)
+
+// Broadcast is a pump where every reader sees every written interval.
+// Readers subscribe with Subscribe, each Subscriber gets all the intervals committed after it subscribed,
+// in the order they were committed.
+// A block goes back to the writers once all the subscribers have committed it,
+// so the slowest subscriber holds the writers back.
+// If there are no subscribers, committed intervals are dropped.
+type Broadcast struct {
+	toWrite   chan Interval
+	blockSize int
+	numBlocks int
+	s         *state
+	b         *subscribers
+}
+
+// subscribers tracks the subscribers and how many of them still have to commit each block.
+type subscribers struct {
+	mu   sync.Mutex
+	subs []*Subscriber
+	refs []int // By block.
+}
+
+// Subscriber is a reader of a Broadcast.
+type Subscriber struct {
+	toRead chan Interval
+	p      Broadcast
+}
+
+// NewBroadcast creates a new Broadcast with numBlocks blocks of blockSize elements each.
+// Panics if blockSize <= 0 or numBlocks <= 0, see New.
+func NewBroadcast(blockSize int, numBlocks int) Broadcast {
+	checkSize(blockSize, numBlocks)
+	p := Broadcast{
+		toWrite:   make(chan Interval, numBlocks),
+		blockSize: blockSize,
+		numBlocks: numBlocks,
+		s:         newState(),
+		b:         &subscribers{refs: make([]int, numBlocks)},
+	}
+	for i := 0; i < numBlocks; i++ {
+		p.toWrite <- p.full(i)
+	}
+	return p
+}
+
+// full returns the whole block i.
+func (p Broadcast) full(i int) Interval {
+	return Interval{Start: i * p.blockSize, End: (i + 1) * p.blockSize, block: i, blocks: 1}
+}
+
+// Subscribe adds a new reader.
+// It gets the intervals committed from now on.
+func (p Broadcast) Subscribe() *Subscriber {
+	s := &Subscriber{toRead: make(chan Interval, p.numBlocks), p: p}
+	p.b.mu.Lock()
+	defer p.b.mu.Unlock()
+	p.b.subs = append(p.b.subs, s)
+	return s
+}
+
+// StartWrite waits for a free interval to write to.
+// Returns false if the pump is closed.
+func (p Broadcast) StartWrite() (Interval, bool) {
+	if !p.s.startWrite() {
+		return Interval{}, false
+	}
+	select {
+	case b := <-p.toWrite:
+		return b, true
+	case <-p.s.done:
+		p.s.endWrite()
+		return Interval{}, false
+	}
+}
+
+// TryStartWrite is StartWrite that does not wait.
+// Returns false if there is no free interval or the pump is closed.
+func (p Broadcast) TryStartWrite() (Interval, bool) {
+	if !p.s.startWrite() {
+		return Interval{}, false
+	}
+	select {
+	case b := <-p.toWrite:
+		return b, true
+	default:
+		p.s.endWrite()
+		return Interval{}, false
+	}
+}
+
+// CommitWrite hands the first written elements of b over to all the subscribers.
+// If nothing was written, b goes back to the writers.
+func (p Broadcast) CommitWrite(b Interval, written int) {
+	defer p.s.endWrite()
+	if written == 0 {
+		p.toWrite <- p.full(b.block)
+		return
+	}
+	b.End = b.Start + written
+	p.b.mu.Lock()
+	defer p.b.mu.Unlock()
+	if len(p.b.subs) == 0 {
+		p.toWrite <- p.full(b.block)
+		return
+	}
+	p.b.refs[b.block] = len(p.b.subs)
+	for _, s := range p.b.subs {
+		// A subscriber holds at most numBlocks intervals, so the channel has room.
+		s.toRead <- b
+	}
+}
+
+// CancelWrite returns b to the writers without handing anything over to the subscribers.
+func (p Broadcast) CancelWrite(b Interval) {
+	p.CommitWrite(b, 0)
+}
+
+// release drops a reference to the block of b, once there are none left, the block goes back to the writers.
+func (p Broadcast) release(b Interval) {
+	p.b.mu.Lock()
+	defer p.b.mu.Unlock()
+	p.b.refs[b.block]--
+	if p.b.refs[b.block] == 0 {
+		p.toWrite <- p.full(b.block)
+	}
+}
+
+// Close marks the pump as closed, see Pump.Close.
+func (p Broadcast) Close() {
+	p.s.close()
+}
+
+// StartRead waits for a written interval to read from, see Pump.StartRead.
+// The subscriber must not write to the interval: the other subscribers read it too.
+func (s *Subscriber) StartRead() (Interval, bool) {
+	select {
+	case b := <-s.toRead:
+		return b, true
+	case <-s.p.s.drained:
+		select {
+		case b := <-s.toRead:
+			return b, true
+		default:
+			return Interval{}, false
+		}
+	}
+}
+
+// CommitRead marks b as read by this subscriber.
+// b must be an interval returned by StartRead.
+func (s *Subscriber) CommitRead(b Interval) {
+	s.p.release(b)
+}
+
+// Unsubscribe removes the subscriber: the intervals it has not started reading yet are committed,
+// and it gets no more intervals.
+// The intervals it has started reading should be committed before Unsubscribe.
+func (s *Subscriber) Unsubscribe() {
+	b := s.p.b
+	b.mu.Lock()
+	for i, x := range b.subs {
+		if x == s {
+			b.subs = slices.Delete(b.subs, i, i+1)
+			break
+		}
+	}
+	b.mu.Unlock()
+	for {
+		select {
+		case x := <-s.toRead:
+			s.p.release(x)
+		default:
+			return
+		}
+	}
+}
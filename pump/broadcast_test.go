@@ -0,0 +1,113 @@
+package pump
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBroadcast(t *testing.T) {
+	const subs, k = 3, 1000
+	p := NewBroadcast(1, 4)
+	buf := make([]int, 4)
+	var ss []*Subscriber
+	for i := 0; i < subs; i++ {
+		ss = append(ss, p.Subscribe())
+	}
+	go func() {
+		for i := 0; i < k; i++ {
+			b, _ := p.StartWrite()
+			buf[b.Start] = i
+			p.CommitWrite(b, 1)
+		}
+		p.Close()
+	}()
+	got := make([][]int, subs)
+	var wg sync.WaitGroup
+	for i, s := range ss {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				b, ok := s.StartRead()
+				if !ok {
+					return
+				}
+				got[i] = append(got[i], buf[b.Start])
+				s.CommitRead(b)
+			}
+		}()
+	}
+	wg.Wait()
+	for i := range got {
+		if len(got[i]) != k || !slices.IsSorted(got[i]) || got[i][0] != 0 || got[i][k-1] != k-1 {
+			t.Fatalf("subscriber %d: expected 0..%d, got %d values", i, k-1, len(got[i]))
+		}
+	}
+}
+
+func TestBroadcastSlowSubscriber(t *testing.T) {
+	const numBlocks = 4
+	p := NewBroadcast(8, numBlocks)
+	fast, slow := p.Subscribe(), p.Subscribe()
+	for i := 0; i < numBlocks; i++ {
+		b, ok := p.TryStartWrite()
+		if !ok {
+			t.Fatalf("expected a free interval")
+		}
+		p.CommitWrite(b, 1)
+	}
+	for i := 0; i < numBlocks; i++ {
+		b, _ := fast.StartRead()
+		fast.CommitRead(b)
+	}
+	// The slow subscriber holds all the blocks.
+	if b, ok := p.TryStartWrite(); ok {
+		t.Fatalf("expected the writer to stall, got %v", b)
+	}
+	started := make(chan Interval)
+	go func() {
+		b, _ := p.StartWrite()
+		started <- b
+	}()
+	select {
+	case b := <-started:
+		t.Fatalf("expected the writer to wait for the slow subscriber, got %v", b)
+	case <-time.After(10 * time.Millisecond):
+	}
+	b, _ := slow.StartRead()
+	slow.CommitRead(b)
+	if w := <-started; w.block != b.block || w.End-w.Start != 8 {
+		t.Fatalf("expected the full block of %v, got %v", b, w)
+	}
+	p.CancelWrite(b)
+
+	// Unsubscribing releases the blocks the subscriber has not read.
+	slow.Unsubscribe()
+	for i := 0; i < numBlocks; i++ {
+		b, ok := p.TryStartWrite()
+		if !ok {
+			t.Fatalf("expected %d free intervals, got %d", numBlocks, i)
+		}
+		p.CommitWrite(b, 1)
+		r, _ := fast.StartRead()
+		fast.CommitRead(r)
+	}
+}
+
+func TestBroadcastNoSubscribers(t *testing.T) {
+	p := NewBroadcast(8, 2)
+	for i := 0; i < 10; i++ {
+		b, ok := p.TryStartWrite()
+		if !ok {
+			t.Fatalf("expected committed intervals to be dropped without subscribers")
+		}
+		p.CommitWrite(b, 1)
+	}
+	s := p.Subscribe()
+	p.Close()
+	if b, ok := s.StartRead(); ok {
+		t.Fatalf("expected nothing to read, got %v", b)
+	}
+}
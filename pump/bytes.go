@@ -0,0 +1,87 @@
+package pump
+
+import "io"
+
+// Bytes is a SlicePump of bytes with io.Writer and io.Reader on top, see NewBytes.
+type Bytes struct {
+	SlicePump[byte]
+}
+
+// NewBytes creates a new pump of bytes that owns a single arena of numBlocks blocks of blockSize bytes each:
+// StartWrite and StartRead return sub-slices of the arena, so there is no buffer to keep in sync with the pump,
+// unlike with Pump.Writer and Pump.Reader.
+// Panics if blockSize <= 0 or numBlocks <= 0, see New.
+func NewBytes(blockSize int, numBlocks int) Bytes {
+	return Bytes{NewSlicePump[byte](blockSize, numBlocks)}
+}
+
+// WithZeroOnRecycle returns a copy of p that clears every block it returns to the writers,
+// see SlicePump.WithZeroOnRecycle.
+func (p Bytes) WithZeroOnRecycle() Bytes {
+	return Bytes{p.SlicePump.WithZeroOnRecycle()}
+}
+
+// Writer returns an io.Writer that copies the data into the blocks and hands them over to the readers, see Pump.Writer.
+// Bytes is an io.Writer itself, Writer is there for symmetry with Reader.
+func (p Bytes) Writer() io.Writer {
+	return p
+}
+
+// Reader returns an io.Reader that copies the data out of the written blocks, see Pump.Reader.
+// The Reader is not safe for concurrent use.
+func (p Bytes) Reader() io.Reader {
+	return &bytesReader{p: p}
+}
+
+// Write copies data into as many blocks as it takes, committing each one as it is filled,
+// and waits for free blocks if data does not fit into the pump.
+// Returns the number of bytes committed, less than len(data) only with ErrClosed, once the pump is closed.
+func (p Bytes) Write(data []byte) (int, error) {
+	return write(p, data)
+}
+
+// WriteString is Write of a string, without converting it to []byte.
+func (p Bytes) WriteString(s string) (int, error) {
+	return write(p, s)
+}
+
+func write[S string | []byte](p Bytes, data S) (int, error) {
+	n := 0
+	for len(data) > 0 {
+		b, ok := p.StartWrite()
+		if !ok {
+			return n, ErrClosed
+		}
+		k := copy(b, data)
+		p.CommitWrite(b, k)
+		data = data[k:]
+		n += k
+	}
+	return n, nil
+}
+
+type bytesReader struct {
+	p     Bytes
+	block []byte // The block being read, as returned by StartRead, nil if there is none.
+	pos   int    // Position of the unread data in the block.
+}
+
+func (r *bytesReader) Read(data []byte) (int, error) {
+	if len(data) == 0 {
+		return 0, nil
+	}
+	if r.block == nil {
+		b, ok := r.p.StartRead()
+		if !ok {
+			return 0, io.EOF
+		}
+		r.block, r.pos = b, 0
+	}
+	n := copy(data, r.block[r.pos:])
+	r.pos += n
+	if r.pos == len(r.block) {
+		r.p.CommitRead(r.block)
+		r.block = nil
+	}
+	return n, nil
+}
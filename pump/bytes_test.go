@@ -0,0 +1,180 @@
+package pump
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"runtime"
+	"slices"
+	"testing"
+	"testing/iotest"
+)
+
+func TestBytes(t *testing.T) {
+	r := rand.New(rand.NewSource(584))
+	data := make([]byte, 1000000)
+	r.Read(data)
+	p := NewBytes(100, 7)
+	errs := make(chan error, 1)
+	go func() {
+		w := p.Writer()
+		for rest := data; len(rest) > 0; {
+			k := min(len(rest), r.Intn(500))
+			if n, err := w.Write(rest[:k]); n != k || err != nil {
+				errs <- err
+				return
+			}
+			rest = rest[k:]
+		}
+		p.Close()
+		_, err := w.Write([]byte{1})
+		errs <- err
+	}()
+	got, err := io.ReadAll(iotest.HalfReader(p.Reader()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errs; err != ErrClosed {
+		t.Fatalf("expected ErrClosed after Close, got %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("expected to read back what was written")
+	}
+}
+
+func TestBytesWrite(t *testing.T) {
+	p := NewBytes(4, 3)
+	// A single block, then three blocks, the last one partial.
+	if n, err := p.Write([]byte("abc")); n != 3 || err != nil {
+		t.Fatalf("expected 3 bytes written, got %d, %v", n, err)
+	}
+	if n, err := p.WriteString("defghi"); n != 6 || err != nil {
+		t.Fatalf("expected 6 bytes written, got %d, %v", n, err)
+	}
+	var blocks []string
+	for range 3 {
+		b, _ := p.StartRead()
+		blocks = append(blocks, string(b))
+		p.CommitRead(b)
+	}
+	if !slices.Equal(blocks, []string{"abc", "defg", "hi"}) {
+		t.Fatalf("expected the data split at the block boundaries, got %q", blocks)
+	}
+
+	// More than the whole ring: Write waits for the reader.
+	data := make([]byte, 100)
+	rand.New(rand.NewSource(601)).Read(data)
+	errs := make(chan error, 1)
+	go func() {
+		n, err := p.Write(data)
+		if err == nil && n != len(data) {
+			err = io.ErrShortWrite
+		}
+		p.Close()
+		errs <- err
+	}()
+	got, _ := io.ReadAll(p.Reader())
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("expected to read back what was written")
+	}
+	if n, err := io.WriteString(p, "closed"); n != 0 || err != ErrClosed {
+		t.Fatalf("expected ErrClosed, got %d, %v", n, err)
+	}
+}
+
+func TestBytesWriteClosed(t *testing.T) {
+	p := NewBytes(4, 2)
+	go func() {
+		// Let the writer fill the ring, then take a block out and close.
+		for p.Readable() < 8 {
+			runtime.Gosched()
+		}
+		b, _ := p.StartRead()
+		p.CommitRead(b)
+		p.Close()
+	}()
+	n, err := p.Write(make([]byte, 20))
+	if err != ErrClosed || n < 8 || n > 12 {
+		t.Fatalf("expected 8 to 12 bytes written before ErrClosed, got %d, %v", n, err)
+	}
+}
+
+func TestBytesReadable(t *testing.T) {
+	p := NewBytes(4, 4)
+	w := p.Writer()
+	w.Write([]byte("hello")) // A full block and a partial one.
+	b, _ := p.StartWrite()
+	p.CommitWrite(b, copy(b, "!!"))
+	if p.Readable() != 7 {
+		t.Fatalf("expected 7 bytes to read, got %d", p.Readable())
+	}
+	b, _ = p.StartRead()
+	if p.Readable() != 7-len(b) {
+		t.Fatalf("expected %d bytes to read, got %d", 7-len(b), p.Readable())
+	}
+	p.CommitRead(b)
+	p.Close()
+	rest, _ := io.ReadAll(p.Reader())
+	if string(rest) != "o!!" || p.Readable() != 0 {
+		t.Fatalf("expected to read the rest, got %q with %d bytes left", rest, p.Readable())
+	}
+}
+
+func TestBytesZeroOnRecycle(t *testing.T) {
+	for _, zero := range []bool{false, true} {
+		p := NewBytes(8, 1)
+		if zero {
+			p = p.WithZeroOnRecycle()
+		}
+		// Read and commit a block, cancel a write and commit an empty one: the single block has to come back clean.
+		b, _ := p.StartWrite()
+		p.CommitWrite(b, copy(b, "secret"))
+		b, _ = p.StartRead()
+		p.CommitRead(b)
+		for _, recycle := range []func(b []byte){p.CancelWrite, func(b []byte) { p.CommitWrite(b, 0) }} {
+			b, _ = p.StartWrite()
+			if got := string(b[:6]); zero && got != "\x00\x00\x00\x00\x00\x00" || !zero && got != "secret" {
+				t.Fatalf("zero=%t: unexpected stale bytes %q", zero, b)
+			}
+			copy(b, "secret")
+			recycle(b)
+		}
+		b, _ = p.StartWrite()
+		if zero && !bytes.Equal(b, make([]byte, 8)) {
+			t.Fatalf("expected a zeroed block, got %q", b)
+		}
+	}
+}
+
+// benchmarkCopy copies b.N bytes through w into r.
+func benchmarkCopy(b *testing.B, close func(), w io.Writer, r io.Reader) {
+	data := make([]byte, 4096)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	go func() {
+		for i := 0; i < b.N; i++ {
+			w.Write(data)
+		}
+		close()
+	}()
+	io.Copy(io.Discard, r)
+}
+
+func BenchmarkBytes(b *testing.B) {
+	p := NewBytes(blockSize, numBlocks)
+	benchmarkCopy(b, p.Close, p.Writer(), p.Reader())
+}
+
+func BenchmarkBytesZeroOnRecycle(b *testing.B) {
+	p := NewBytes(blockSize, numBlocks).WithZeroOnRecycle()
+	benchmarkCopy(b, p.Close, p.Writer(), p.Reader())
+}
+
+func BenchmarkPumpWriterReader(b *testing.B) {
+	p := New(blockSize, numBlocks)
+	buf := make([]byte, blockSize*numBlocks)
+	benchmarkCopy(b, p.Close, p.Writer(buf), p.Reader(buf))
+}
@@ -0,0 +1,44 @@
+package pump
+
+import "sync"
+
+// NewChecked creates a new checked pump: it keeps track of the intervals being read,
+// and CommitRead (and CommitReadCtx) panics if the interval is not one of them:
+// it was committed already, or it did not come from StartRead.
+// Committing an interval twice would put its block among the free ones twice,
+// and two writers would end up writing to the same block.
+// Meant for development and tests: the tracking takes a mutex and a map entry per interval being read.
+func NewChecked(blockSize int, numBlocks int) Pump {
+	p := New(blockSize, numBlocks)
+	p.checked = &checkout{reading: make(map[int]int, numBlocks)}
+	return p
+}
+
+// checkout tracks the intervals being read by a checked pump.
+type checkout struct {
+	mu      sync.Mutex
+	reading map[int]int // Number of blocks by the first block of the interval.
+}
+
+// start records that a reader got b.
+func (c *checkout) start(b Interval) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reading[b.block] = b.blocks
+}
+
+// commit checks that b is being read and forgets it.
+func (c *checkout) commit(b Interval) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n, ok := c.reading[b.block]; !ok || n != b.blocks {
+		panic("pump: CommitRead of an interval that is not being read")
+	}
+	delete(c.reading, b.block)
+}
+
+func (c *checkout) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.reading)
+}
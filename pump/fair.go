@@ -0,0 +1,95 @@
+package pump
+
+import (
+	"sync"
+	"time"
+)
+
+// NewFair creates a new fair pump: writers get the free intervals in the order they called StartWrite,
+// instead of whoever happens to receive from the channel first.
+// A writer that commits and starts the next write right away goes to the back of the queue,
+// so it can not keep the free blocks to itself while the others starve.
+// The queue costs a mutex and, for the writers that have to wait, a channel per StartWrite,
+// so fair pumps are slower than the ones created with New under contention.
+// TryStartWrite fails while there are writers waiting. StartWriteN does not take part in the queue.
+func NewFair(blockSize int, numBlocks int) Pump {
+	p := New(blockSize, numBlocks)
+	p.fair = &turnstile{}
+	return p
+}
+
+// turnstile is a FIFO lock: the writer that holds it is the only one waiting for a free interval.
+type turnstile struct {
+	mu      sync.Mutex
+	held    bool
+	waiters []chan struct{} // In the order of arrival, closed to hand the turnstile over.
+}
+
+// acquire waits for the turn, giving up if done, cancel or timeout fire first.
+// nil channels never fire.
+func (t *turnstile) acquire(done, cancel <-chan struct{}, timeout <-chan time.Time) bool {
+	t.mu.Lock()
+	if !t.held {
+		t.held = true
+		t.mu.Unlock()
+		return true
+	}
+	ch := make(chan struct{})
+	t.waiters = append(t.waiters, ch)
+	t.mu.Unlock()
+	select {
+	case <-ch:
+		return true
+	case <-done:
+	case <-cancel:
+	case <-timeout:
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, w := range t.waiters {
+		if w == ch {
+			t.waiters = append(t.waiters[:i], t.waiters[i+1:]...)
+			return false
+		}
+	}
+	// The turn was handed over at the same time, pass it on.
+	t.releaseLocked()
+	return false
+}
+
+// tryAcquire takes the turn if nobody holds it.
+func (t *turnstile) tryAcquire() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.held {
+		return false
+	}
+	t.held = true
+	return true
+}
+
+// release hands the turn over to the first waiter, if any.
+func (t *turnstile) release() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.releaseLocked()
+}
+
+func (t *turnstile) releaseLocked() {
+	if len(t.waiters) == 0 {
+		t.held = false
+		return
+	}
+	close(t.waiters[0])
+	t.waiters = t.waiters[1:]
+}
+
+// queued returns the number of writers in the queue, including the one holding the turn.
+func (t *turnstile) queued() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.held {
+		return len(t.waiters) + 1
+	}
+	return 0
+}
@@ -0,0 +1,221 @@
+package pump
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// Writer returns an io.Writer that copies the data into the blocks of buf and hands them over to the readers.
+// buf is the slice the intervals of p refer to.
+// Every Write commits the blocks it used, the last one possibly partially filled.
+// Write returns ErrClosed if the pump is closed.
+func (p Pump) Writer(buf []byte) io.Writer {
+	return &writer{p: p, buf: buf}
+}
+
+// Reader returns an io.Reader that copies the data out of the written blocks of buf.
+// buf is the slice the intervals of p refer to.
+// A block is committed back to the writers once it is read completely.
+// Read returns io.EOF once the pump is closed and drained.
+// The Reader is not safe for concurrent use.
+func (p Pump) Reader(buf []byte) io.Reader {
+	return &reader{p: p, buf: buf}
+}
+
+// CopyFrom copies from src to dst through the pump until src returns io.EOF, an error occurs or ctx is done:
+// the calling goroutine reads from src into the blocks of buf, another one writes them to dst,
+// so reading and writing overlap.
+// buf is the slice the intervals of p refer to.
+// Returns the number of bytes written to dst and the first error: of dst (io.ErrShortWrite for a short write),
+// of src (the data read before it is still written to dst) or ctx.Err().
+// A Read from src or a Write to dst that blocks is not interrupted by ctx.
+// The pump is closed when CopyFrom returns.
+func (p Pump) CopyFrom(ctx context.Context, dst io.Writer, src io.Reader, buf []byte) (int64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var written int64
+	var werr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		written, werr = p.writeTo(ctx, dst, buf)
+		if werr != nil {
+			// Stop reading from src.
+			cancel()
+		}
+	}()
+	rerr := p.readFrom(ctx, src, buf)
+	p.Close()
+	<-done
+	if werr != nil {
+		return written, werr
+	}
+	return written, rerr
+}
+
+// readFrom reads from src into the pump until io.EOF, see CopyFrom.
+func (p Pump) readFrom(ctx context.Context, src io.Reader, buf []byte) error {
+	for {
+		b, err := p.StartWriteCtx(ctx)
+		if err != nil {
+			return err
+		}
+		n, err := src.Read(buf[b.Start:b.End])
+		p.CommitWrite(b, n)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// writeTo writes the intervals read from the pump to dst until the pump is closed and drained, see CopyFrom.
+func (p Pump) writeTo(ctx context.Context, dst io.Writer, buf []byte) (int64, error) {
+	var written int64
+	for {
+		b, err := p.StartReadCtx(ctx)
+		if err == ErrClosed {
+			return written, nil
+		}
+		if err != nil {
+			return written, err
+		}
+		n, err := dst.Write(buf[b.Start:b.End])
+		p.CommitRead(b)
+		written += int64(n)
+		if err == nil && n < b.End-b.Start {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+}
+
+// FlushingWriter returns an io.Writer that, unlike Writer, keeps filling the same block across Writes,
+// and commits it once it is full, on Flush, or flushAfter after the first byte was written into it,
+// whichever comes first. So a trickle of small writes fills the blocks, and the readers still get the data
+// within flushAfter, even if the writer goes idle.
+// buf is the slice the intervals of p refer to.
+// The flushes happen on a timer goroutine, the FlushingWriter is safe for concurrent use.
+func (p Pump) FlushingWriter(buf []byte, flushAfter time.Duration) *FlushingWriter {
+	return &FlushingWriter{p: p, buf: buf, flushAfter: flushAfter}
+}
+
+// FlushingWriter is an io.Writer that commits partially filled blocks after a delay, see Pump.FlushingWriter.
+type FlushingWriter struct {
+	p          Pump
+	buf        []byte
+	flushAfter time.Duration
+
+	mu    sync.Mutex
+	block Interval    // The block being filled, as returned by StartWrite.
+	n     int         // Number of bytes written to the block.
+	ok    bool        // Whether there is a block being filled.
+	timer *time.Timer // Flushes the block, nil until the first partial block.
+}
+
+// Write copies data into the blocks, committing the full ones.
+// Returns ErrClosed if the pump is closed.
+func (w *FlushingWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n := 0
+	for len(data) > 0 {
+		if !w.ok {
+			w.block, w.ok = w.p.StartWrite()
+			if !w.ok {
+				return n, ErrClosed
+			}
+			w.n = 0
+		}
+		k := copy(w.buf[w.block.Start+w.n:w.block.End], data)
+		if w.n == 0 {
+			w.arm()
+		}
+		w.n += k
+		data = data[k:]
+		n += k
+		if w.block.Start+w.n == w.block.End {
+			w.flush()
+		}
+	}
+	return n, nil
+}
+
+// Flush commits the block being filled, if any.
+func (w *FlushingWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.flush()
+}
+
+// arm starts the flush timer for a new block.
+func (w *FlushingWriter) arm() {
+	if w.timer == nil {
+		w.timer = time.AfterFunc(w.flushAfter, w.Flush)
+		return
+	}
+	w.timer.Reset(w.flushAfter)
+}
+
+func (w *FlushingWriter) flush() {
+	if !w.ok {
+		return
+	}
+	w.timer.Stop()
+	w.p.CommitWrite(w.block, w.n)
+	w.ok = false
+}
+
+type writer struct {
+	p   Pump
+	buf []byte
+}
+
+func (w *writer) Write(data []byte) (int, error) {
+	n := 0
+	for len(data) > 0 {
+		b, ok := w.p.StartWrite()
+		if !ok {
+			return n, ErrClosed
+		}
+		k := copy(w.buf[b.Start:b.End], data)
+		w.p.CommitWrite(b, k)
+		data = data[k:]
+		n += k
+	}
+	return n, nil
+}
+
+type reader struct {
+	p     Pump
+	buf   []byte
+	block Interval // The block being read, as returned by StartRead.
+	pos   int      // Position of the unread data in the block.
+	ok    bool     // Whether there is a block being read.
+}
+
+func (r *reader) Read(data []byte) (int, error) {
+	if len(data) == 0 {
+		return 0, nil
+	}
+	if !r.ok {
+		r.block, r.ok = r.p.StartRead()
+		if !r.ok {
+			return 0, io.EOF
+		}
+		r.pos = r.block.Start
+	}
+	n := copy(data, r.buf[r.pos:r.block.End])
+	r.pos += n
+	if r.pos == r.block.End {
+		r.p.CommitRead(r.block)
+		r.ok = false
+	}
+	return n, nil
+}
@@ -0,0 +1,89 @@
+package pump
+
+import (
+	"math/bits"
+	"sync"
+	"time"
+)
+
+// latencyBuckets is the number of buckets in the latency histogram, the last one takes everything above 2^38µs.
+const latencyBuckets = 40
+
+// LatencyTracker measures the end-to-end latency of a pump: the time from the CommitWrite of an interval
+// to its CommitRead, see WithLatency.
+type LatencyTracker struct {
+	deadline time.Duration
+	over     func(b Interval, latency time.Duration)
+
+	mu        sync.Mutex
+	committed map[int]time.Time // Time of CommitWrite by the first block of the interval.
+	hist      [latencyBuckets]int64
+	overs     int64
+}
+
+// NewLatencyTracker creates a tracker that calls over for every interval read later than deadline after
+// it was written. over is called synchronously by the reader, from CommitRead, so it should be fast.
+// Zero deadline or nil over only collects the histogram.
+func NewLatencyTracker(deadline time.Duration, over func(b Interval, latency time.Duration)) *LatencyTracker {
+	return &LatencyTracker{deadline: deadline, over: over, committed: make(map[int]time.Time)}
+}
+
+// WithLatency returns a copy of p that reports the latency of every interval to t.
+// Both the writers and the readers have to use the returned copy (or its copies), see WithObserver.
+// The latency is measured when CommitRead (or CommitReadCtx) is called.
+func (p Pump) WithLatency(t *LatencyTracker) Pump {
+	p.latency = t
+	return p
+}
+
+// Histogram returns the number of intervals read by latency:
+// element 0 counts latencies under a microsecond, element i the ones in [2^(i-1), 2^i) microseconds.
+func (t *LatencyTracker) Histogram() []int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]int64(nil), t.hist[:]...)
+}
+
+// Over returns the number of intervals read later than the deadline.
+func (t *LatencyTracker) Over() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.overs
+}
+
+// write records the time b was committed by a writer.
+func (t *LatencyTracker) write(b Interval) {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.committed[b.block] = now
+}
+
+// read records the latency of b, which is being committed by a reader.
+func (t *LatencyTracker) read(b Interval) {
+	t.mu.Lock()
+	at, ok := t.committed[b.block]
+	if !ok {
+		t.mu.Unlock()
+		return
+	}
+	delete(t.committed, b.block)
+	d := time.Since(at)
+	t.hist[min(bits.Len64(uint64(d.Microseconds())), latencyBuckets-1)]++
+	late := t.deadline > 0 && d > t.deadline
+	if late {
+		t.overs++
+	}
+	t.mu.Unlock()
+	if late && t.over != nil {
+		t.over(b, d)
+	}
+}
+
+func (t *LatencyTracker) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	clear(t.committed)
+	clear(t.hist[:])
+	t.overs = 0
+}
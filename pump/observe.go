@@ -0,0 +1,53 @@
+package pump
+
+// Op is the type of a pump event.
+type Op int
+
+const (
+	WriteStarted   Op = iota // A writer got an interval.
+	WriteCommitted           // A writer handed an interval over to the readers.
+	WriteCancelled           // A writer returned an interval without writing to it (CancelWrite or CommitWrite of nothing).
+	ReadStarted              // A reader got an interval.
+	ReadCommitted            // A reader returned an interval to the writers.
+)
+
+func (op Op) String() string {
+	switch op {
+	case WriteStarted:
+		return "WriteStarted"
+	case WriteCommitted:
+		return "WriteCommitted"
+	case WriteCancelled:
+		return "WriteCancelled"
+	case ReadStarted:
+		return "ReadStarted"
+	case ReadCommitted:
+		return "ReadCommitted"
+	}
+	return "Op(?)"
+}
+
+// Event is a step in the life of an interval, see WithObserver.
+type Event struct {
+	Op       Op
+	Interval Interval // As passed to or returned by the method, for WriteCommitted: the committed part.
+	Size     int      // Length of Interval, for WriteCommitted the number of elements written.
+}
+
+// WithObserver returns a copy of p that calls f on every event.
+// f is called synchronously by the goroutine doing the operation, after the operation succeeded,
+// so it should be fast. Events from different goroutines may be reported out of order:
+// a ReadStarted may come before the WriteCommitted of the same interval.
+// Only the returned copy (and its copies) reports events, p itself does not.
+func (p Pump) WithObserver(f func(ev Event)) Pump {
+	p.observe = f
+	return p
+}
+
+// event counts an event in the Stats and reports it to the observer, if any.
+func (p Pump) event(op Op, b Interval) {
+	p.s.counters.count(op, b)
+	if p.observe != nil {
+		p.observe(Event{Op: op, Interval: b, Size: b.End - b.Start})
+	}
+}
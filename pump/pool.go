@@ -0,0 +1,77 @@
+package pump
+
+import "sync"
+
+// WorkerPool runs a fixed number of workers reading from a Pump:
+// producers write intervals with StartWrite and CommitWrite, every committed interval is passed to fn by one of the workers,
+// then committed back to the writers.
+type WorkerPool struct {
+	p       Pump
+	workers int
+	fn      func(Interval)
+	wg      sync.WaitGroup
+	once    sync.Once
+}
+
+// NewWorkerPool creates a pool of workers over a new Pump with numBlocks blocks of blockSize elements each, see New.
+// fn is called concurrently by the workers, each interval is passed to it exactly once.
+// The workers do not run until Start.
+// Panics if workers <= 0, or blockSize or numBlocks are invalid.
+func NewWorkerPool(workers, blockSize, numBlocks int, fn func(Interval)) *WorkerPool {
+	if workers <= 0 {
+		panic("pump: WorkerPool needs at least one worker")
+	}
+	return &WorkerPool{p: New(blockSize, numBlocks), workers: workers, fn: fn}
+}
+
+// Start starts the workers. Calling it more than once does nothing.
+func (w *WorkerPool) Start() {
+	w.once.Do(func() {
+		w.wg.Add(w.workers)
+		for i := 0; i < w.workers; i++ {
+			go w.work()
+		}
+	})
+}
+
+func (w *WorkerPool) work() {
+	defer w.wg.Done()
+	for {
+		b, ok := w.p.StartRead()
+		if !ok {
+			return
+		}
+		w.fn(b)
+		w.p.CommitRead(b)
+	}
+}
+
+// Stop closes the pump and waits for the workers to process everything written to it and exit.
+// Writes in flight must be committed (or cancelled) for Stop to return, see Pump.Close.
+// The pool can not be restarted.
+func (w *WorkerPool) Stop() {
+	w.p.Close()
+	w.Start() // So the remaining intervals are processed even if the pool was never started.
+	w.wg.Wait()
+}
+
+// StartWrite waits for a free interval to write to, see Pump.StartWrite.
+// Returns false once the pool is stopped.
+func (w *WorkerPool) StartWrite() (Interval, bool) {
+	return w.p.StartWrite()
+}
+
+// TryStartWrite is StartWrite that does not wait, see Pump.TryStartWrite.
+func (w *WorkerPool) TryStartWrite() (Interval, bool) {
+	return w.p.TryStartWrite()
+}
+
+// CommitWrite submits the first written elements of b to the workers, see Pump.CommitWrite.
+func (w *WorkerPool) CommitWrite(b Interval, written int) {
+	w.p.CommitWrite(b, written)
+}
+
+// CancelWrite returns b to the writers without submitting it, see Pump.CancelWrite.
+func (w *WorkerPool) CancelWrite(b Interval) {
+	w.p.CancelWrite(b)
+}
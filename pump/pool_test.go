@@ -0,0 +1,66 @@
+package pump
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkerPool(t *testing.T) {
+	const producers, k, blockSize = 4, 1000, 3
+	before := runtime.NumGoroutine()
+	buf := make([]int, 8*blockSize)
+	seen := make([]atomic.Int32, producers*k)
+	w := NewWorkerPool(3, blockSize, 8, func(b Interval) {
+		for _, x := range buf[b.Start:b.End] {
+			seen[x].Add(1)
+		}
+	})
+	w.Start()
+	var wg sync.WaitGroup
+	for i := 0; i < producers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < k; {
+				b, _ := w.StartWrite()
+				n := 0
+				for ; n < b.End-b.Start && j < k; n, j = n+1, j+1 {
+					buf[b.Start+n] = i*k + j
+				}
+				w.CommitWrite(b, n)
+			}
+		}()
+	}
+	wg.Wait()
+	w.Stop()
+	for x := range seen {
+		if n := seen[x].Load(); n != 1 {
+			t.Fatalf("%d: expected to be processed once, processed %d times", x, n)
+		}
+	}
+	if _, ok := w.StartWrite(); ok {
+		t.Fatalf("expected StartWrite to fail after Stop")
+	}
+	// The workers may still be exiting right after Stop returns.
+	for deadline := time.Now().Add(time.Second); runtime.NumGoroutine() > before; {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d goroutines after Stop, got %d", before, runtime.NumGoroutine())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWorkerPoolStopNotStarted(t *testing.T) {
+	var processed int
+	w := NewWorkerPool(2, 1, 2, func(b Interval) { processed++ })
+	b, _ := w.StartWrite()
+	w.CommitWrite(b, 1)
+	w.Stop()
+	w.Stop()
+	if processed != 1 {
+		t.Fatalf("expected the submitted interval to be processed on Stop, processed %d", processed)
+	}
+}
@@ -1,72 +1,743 @@
 package pump
 
-import "context"
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrClosed is returned by StartWriteCtx and StartReadCtx once the pump is closed.
+var ErrClosed = errors.New("pump: closed")
 
 type Pump struct {
-	toRead    chan Interval
-	toWrite   chan Interval
+	c         *channels
 	blockSize int
+	s         *state
+	ord       *ordering       // nil unless the pump is ordered.
+	fair      *turnstile      // nil unless the pump is fair, see NewFair.
+	checked   *checkout       // nil unless the pump is checked, see NewChecked.
+	latency   *LatencyTracker // nil unless set by WithLatency.
+	head      *head
+	observe   func(ev Event) // nil unless set by WithObserver.
 }
 
-// New creates a new pump.
-func New(blockSize int, numBlocks int) Pump {
-	toWrite := make(chan Interval, numBlocks)
-	for i := 0; i < numBlocks; i++ {
-		toWrite <- Interval{Start: i * blockSize, End: i*blockSize + blockSize}
+// channels holds the intervals waiting to be read and written.
+// It is shared by all the copies of a Pump, so that Grow can replace the channels.
+type channels struct {
+	toRead  chan Interval
+	toWrite chan Interval
+}
+
+// head holds the interval taken out of toRead by Peek.
+// Readers take it before anything in toRead.
+type head struct {
+	mu    sync.Mutex
+	b     Interval
+	ok    bool
+	ready chan struct{} // Wakes up a waiting reader once Peek fills the head.
+}
+
+// take returns the interval in the head, if there is one.
+func (h *head) take() (Interval, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	b, ok := h.b, h.ok
+	h.ok = false
+	return b, ok
+}
+
+// state is shared by all the copies of a Pump, it tracks Close, the writes in flight and the unread intervals.
+type state struct {
+	mu      sync.Mutex
+	closed  bool
+	writing int           // Number of intervals started by writers and not committed yet.
+	unread  int           // Number of intervals committed by writers and not committed by readers yet.
+	done    chan struct{} // Closed by Close.
+	drained chan struct{} // Closed once the pump is closed and there are no writes in flight.
+	idle    chan struct{} // Closed while there are no unread intervals.
+	spans   sync.Mutex    // Held by StartWriteN while it collects adjacent blocks.
+
+	counters counters     // See Stats.
+	readable atomic.Int64 // Number of elements in the intervals waiting to be read, see Readable.
+}
+
+func newState() *state {
+	idle := make(chan struct{})
+	close(idle)
+	return &state{
+		done:    make(chan struct{}),
+		drained: make(chan struct{}),
+		idle:    idle,
+	}
+}
+
+// startWrite registers a write in flight, unless the pump is closed.
+func (s *state) startWrite() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.writing++
+	return true
+}
+
+// endWrite marks a write in flight as finished.
+func (s *state) endWrite() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writing--
+	if s.closed && s.writing == 0 {
+		close(s.drained)
+	}
+}
+
+func (s *state) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
 	}
-	return Pump{
-		toRead:    make(chan Interval, numBlocks),
-		toWrite:   toWrite,
+	s.closed = true
+	close(s.done)
+	if s.writing == 0 {
+		close(s.drained)
+	}
+}
+
+// handOver registers an interval committed by a writer, before the readers can get it.
+func (s *state) handOver() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unread++
+	if s.unread == 1 {
+		s.idle = make(chan struct{})
+	}
+}
+
+// readBack marks an interval handed over to the readers as read.
+func (s *state) readBack() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unread--
+	if s.unread == 0 {
+		close(s.idle)
+	}
+}
+
+// reset reopens the pump and forgets the writes in flight, the unread intervals and the Stats.
+func (s *state) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counters.reset()
+	s.readable.Store(0)
+	s.writing = 0
+	if s.unread > 0 {
+		s.unread = 0
+		close(s.idle)
+	}
+	if s.closed {
+		s.closed = false
+		s.done = make(chan struct{})
+		s.drained = make(chan struct{})
+	}
+}
+
+// ordering releases committed intervals to the readers in the order they were started.
+type ordering struct {
+	mu      sync.Mutex
+	next    uint64               // Sequence number of the next interval to start.
+	release uint64               // Sequence number of the next interval to hand over to the readers.
+	pending map[uint64]*Interval // Commits waiting for the earlier ones, nil for empty commits.
+}
+
+// New creates a new pump with numBlocks blocks of blockSize elements each.
+// Panics if blockSize <= 0 or numBlocks <= 0.
+//
+// There are only numBlocks intervals in circulation: every interval is either free, written to,
+// waiting to be read or being read. A goroutine that holds intervals and waits for another one
+// (say, starts numBlocks+1 writes before committing any, or a reader that writes back into the same pump)
+// can deadlock the pump, so numBlocks should cover the number of intervals held at the same time.
+func New(blockSize int, numBlocks int) Pump {
+	checkSize(blockSize, numBlocks)
+	p := Pump{
+		c: &channels{
+			toRead:  make(chan Interval, numBlocks),
+			toWrite: make(chan Interval, numBlocks),
+		},
 		blockSize: blockSize,
+		s:         newState(),
+		head:      &head{ready: make(chan struct{}, 1)},
+	}
+	p.free(p.span(0, numBlocks))
+	return p
+}
+
+// checkSize panics unless both the block size and the number of blocks are positive.
+func checkSize(blockSize, numBlocks int) {
+	if blockSize <= 0 {
+		panic("pump: block size must be positive")
+	}
+	if numBlocks <= 0 {
+		panic("pump: number of blocks must be positive")
 	}
 }
 
+// Interval is a block (or a run of adjacent blocks, see StartWriteN) of the buffer: [Start, End).
+// Writers and readers may shrink it, the pump restores the full blocks when they are recycled.
 type Interval struct {
-	Start int
-	End   int
+	Start  int
+	End    int
+	block  int    // Index of the (first) block, Start and End may change, the block does not.
+	blocks int    // Number of blocks, more than one for StartWriteN.
+	seq    uint64 // Sequence number of the write, see NewOrdered.
 }
 
-func (p Pump) StartWrite() Interval {
-	return <-p.toWrite
+// NewOrdered creates a new ordered pump: readers get the intervals in the order StartWrite returned them,
+// even if they are committed in a different order.
+// Commits that come early are held back until the earlier ones are committed (or cancelled),
+// so a slow writer keeps the blocks committed after it out of circulation and stalls the readers.
+// Holding them back takes a map entry per interval.
+func NewOrdered(blockSize int, numBlocks int) Pump {
+	p := New(blockSize, numBlocks)
+	p.ord = &ordering{pending: make(map[uint64]*Interval, numBlocks)}
+	return p
 }
 
+// started tags b with a sequence number if the pump is ordered.
+func (p Pump) started(b Interval) Interval {
+	if p.ord != nil {
+		p.ord.mu.Lock()
+		b.seq = p.ord.next
+		p.ord.next++
+		p.ord.mu.Unlock()
+	}
+	p.event(WriteStarted, b)
+	return b
+}
+
+// readStarted reports that a reader got b.
+func (p Pump) readStarted(b Interval) Interval {
+	p.s.readable.Add(-int64(b.End - b.Start))
+	if p.checked != nil {
+		p.checked.start(b)
+	}
+	p.event(ReadStarted, b)
+	return b
+}
+
+// commitOrdered hands b over to the readers once all the intervals started before it are committed.
+// b is nil if nothing was written.
+func (p Pump) commitOrdered(seq uint64, b *Interval) {
+	o := p.ord
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.pending[seq] = b
+	for {
+		b, ok := o.pending[o.release]
+		if !ok {
+			return
+		}
+		delete(o.pending, o.release)
+		o.release++
+		if b != nil {
+			// There are at most numBlocks intervals, so the channel has room.
+			p.s.readable.Add(int64(b.End - b.Start))
+			p.c.toRead <- *b
+		}
+	}
+}
+
+// StartWrite waits for a free interval to write to.
+// Returns false if the pump is closed.
+func (p Pump) StartWrite() (Interval, bool) {
+	if !p.s.startWrite() {
+		return Interval{}, false
+	}
+	if p.fair != nil {
+		if !p.fair.acquire(p.s.done, nil, nil) {
+			p.s.endWrite()
+			return Interval{}, false
+		}
+		defer p.fair.release()
+	}
+	select {
+	case b := <-p.c.toWrite:
+		return p.started(b), true
+	case <-p.s.done:
+		p.s.endWrite()
+		return Interval{}, false
+	}
+}
+
+// StartWriteCtx is StartWrite that gives up when ctx is done.
+// Returns ErrClosed if the pump is closed.
 func (p Pump) StartWriteCtx(ctx context.Context) (Interval, error) {
+	if !p.s.startWrite() {
+		return Interval{}, ErrClosed
+	}
+	if p.fair != nil {
+		if !p.fair.acquire(p.s.done, ctx.Done(), nil) {
+			p.s.endWrite()
+			if err := ctx.Err(); err != nil {
+				return Interval{}, err
+			}
+			return Interval{}, ErrClosed
+		}
+		defer p.fair.release()
+	}
 	select {
 	case <-ctx.Done():
+		p.s.endWrite()
 		return Interval{}, ctx.Err()
-	case b := <-p.toWrite:
-		return b, nil
+	case <-p.s.done:
+		p.s.endWrite()
+		return Interval{}, ErrClosed
+	case b := <-p.c.toWrite:
+		return p.started(b), nil
+	}
+}
+
+// StartWriteTimeout is StartWrite that waits for at most d.
+// If d <= 0, it does not wait, like TryStartWrite.
+// Returns false on timeout or if the pump is closed.
+// FreeWrites right after it tells how congested the pump is.
+func (p Pump) StartWriteTimeout(d time.Duration) (Interval, bool) {
+	if d <= 0 {
+		return p.TryStartWrite()
+	}
+	if !p.s.startWrite() {
+		return Interval{}, false
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	if p.fair != nil {
+		if !p.fair.acquire(p.s.done, nil, t.C) {
+			p.s.endWrite()
+			return Interval{}, false
+		}
+		defer p.fair.release()
+	}
+	select {
+	case b := <-p.c.toWrite:
+		return p.started(b), true
+	case <-p.s.done:
+	case <-t.C:
+	}
+	p.s.endWrite()
+	return Interval{}, false
+}
+
+// TryStartWrite is StartWrite that does not wait.
+// Returns false if there is no free interval or the pump is closed.
+func (p Pump) TryStartWrite() (Interval, bool) {
+	if !p.s.startWrite() {
+		return Interval{}, false
+	}
+	if p.fair != nil {
+		if !p.fair.tryAcquire() {
+			p.s.endWrite()
+			return Interval{}, false
+		}
+		defer p.fair.release()
+	}
+	select {
+	case b := <-p.c.toWrite:
+		return p.started(b), true
+	default:
+		p.s.endWrite()
+		return Interval{}, false
 	}
 }
 
+// CommitWrite hands the first written elements of b over to the readers.
+// If nothing was written, b goes back to the writers: CommitWrite(b, 0) is the same as CancelWrite(b).
 func (p Pump) CommitWrite(b Interval, written int) {
 	if written == 0 {
-		p.toWrite <- b
+		p.CancelWrite(b)
 		return
 	}
+	defer p.s.endWrite()
 	b.End = b.Start + written
-	p.toRead <- b
+	b, tail := p.trim(b)
+	if p.latency != nil {
+		p.latency.write(b)
+	}
+	p.s.handOver()
+	if p.ord != nil {
+		p.commitOrdered(b.seq, &b)
+	} else {
+		p.s.readable.Add(int64(b.End - b.Start))
+		p.c.toRead <- b
+	}
+	p.free(tail)
+	p.event(WriteCommitted, b)
 }
 
-func (p Pump) StartRead() Interval {
-	return <-p.toRead
+// CommitWriteCtx is CommitWrite that gives up when ctx is done.
+// The intervals never outnumber the room in the pump, so a commit only waits if the pump is misused.
+// If ctx is done first, b is still owned by the caller: it has to be committed (or cancelled) later.
+// Commits to an ordered pump do not wait, see NewOrdered.
+func (p Pump) CommitWriteCtx(ctx context.Context, b Interval, written int) error {
+	if p.ord != nil {
+		p.CommitWrite(b, written)
+		return nil
+	}
+	if written == 0 {
+		if err := p.freeCtx(ctx, b); err != nil {
+			return err
+		}
+		p.s.endWrite()
+		p.event(WriteCancelled, b)
+		return nil
+	}
+	b.End = b.Start + written
+	b, tail := p.trim(b)
+	if p.latency != nil {
+		p.latency.write(b)
+	}
+	p.s.handOver()
+	p.s.readable.Add(int64(b.End - b.Start))
+	select {
+	case <-ctx.Done():
+		p.s.readable.Add(-int64(b.End - b.Start))
+		p.s.readBack()
+		return ctx.Err()
+	case p.c.toRead <- b:
+	}
+	p.free(tail)
+	p.s.endWrite()
+	p.event(WriteCommitted, b)
+	return nil
 }
 
+// StartRead waits for a written interval to read from.
+// Once the pump is closed, returns the intervals that are still to be read,
+// including the ones being written at the time of Close, then returns false.
+func (p Pump) StartRead() (Interval, bool) {
+	for {
+		if b, ok := p.head.take(); ok {
+			return p.readStarted(b), true
+		}
+		select {
+		case b := <-p.c.toRead:
+			return p.readStarted(b), true
+		case <-p.head.ready:
+		case <-p.s.drained:
+			return p.TryStartRead()
+		}
+	}
+}
+
+// StartReadCtx is StartRead that gives up when ctx is done.
+// Returns ErrClosed if the pump is closed and there is nothing left to read.
 func (p Pump) StartReadCtx(ctx context.Context) (Interval, error) {
+	for {
+		if b, ok := p.head.take(); ok {
+			return p.readStarted(b), nil
+		}
+		select {
+		case <-ctx.Done():
+			return Interval{}, ctx.Err()
+		case b := <-p.c.toRead:
+			return p.readStarted(b), nil
+		case <-p.head.ready:
+		case <-p.s.drained:
+			if b, ok := p.TryStartRead(); ok {
+				return b, nil
+			}
+			return Interval{}, ErrClosed
+		}
+	}
+}
+
+// TryStartRead is StartRead that does not wait.
+// Returns false if there is no written interval ready.
+func (p Pump) TryStartRead() (Interval, bool) {
+	b, ok := p.tryTake()
+	if ok {
+		p.readStarted(b)
+	}
+	return b, ok
+}
+
+// tryTake takes the head or the next interval in toRead, if there is one.
+func (p Pump) tryTake() (Interval, bool) {
+	// Holds the lock so that Peek can not move the last interval into the head in between.
+	p.head.mu.Lock()
+	defer p.head.mu.Unlock()
+	if p.head.ok {
+		p.head.ok = false
+		return p.head.b, true
+	}
 	select {
-	case <-ctx.Done():
-		return Interval{}, ctx.Err()
-	case b := <-p.toRead:
-		return b, nil
+	case b := <-p.c.toRead:
+		return b, true
+	default:
+		return Interval{}, false
+	}
+}
+
+// StartReadN waits for a written interval, like StartRead, then takes up to n-1 more without waiting.
+// Returns the intervals in the order StartRead would have, or nil once StartRead would return false.
+func (p Pump) StartReadN(n int) []Interval {
+	b, ok := p.StartRead()
+	if !ok {
+		return nil
+	}
+	bs := []Interval{b}
+	for len(bs) < n {
+		b, ok := p.TryStartRead()
+		if !ok {
+			break
+		}
+		bs = append(bs, b)
+	}
+	return bs
+}
+
+// Peek returns the interval the next StartRead would return, without taking it.
+// Returns false if there is no written interval ready.
+// Under concurrent use another reader may take the interval right after Peek returns.
+func (p Pump) Peek() (Interval, bool) {
+	h := p.head
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.ok {
+		return h.b, true
+	}
+	select {
+	case h.b = <-p.c.toRead:
+	default:
+		return Interval{}, false
+	}
+	h.ok = true
+	select {
+	case h.ready <- struct{}{}:
+	default: // A reader is already about to wake up.
 	}
+	return h.b, true
 }
 
+// CommitRead returns b to the writers.
+// b must be an interval returned by StartRead, committed once: NewChecked catches the misuse.
 func (p Pump) CommitRead(b Interval) {
-	b.End = b.Start + p.blockSize
-	p.toWrite <- b
+	if p.checked != nil {
+		p.checked.commit(b)
+	}
+	if p.latency != nil {
+		p.latency.read(b)
+	}
+	p.free(b)
+	p.s.readBack()
+	p.event(ReadCommitted, b)
+}
+
+// CommitReadN returns all the intervals in bs to the writers.
+func (p Pump) CommitReadN(bs []Interval) {
+	for _, b := range bs {
+		p.CommitRead(b)
+	}
+}
+
+// ReadAll reads the remaining intervals, passing each to fn and committing it, meant for draining at shutdown.
+// Once the pump is closed, it returns after the writes in flight are committed and everything is read, see StartRead.
+// On a pump that is not closed it does not wait: it returns as soon as there is no written interval ready.
+func (p Pump) ReadAll(fn func(Interval)) {
+	for {
+		b, ok := p.TryStartRead()
+		if !ok {
+			select {
+			case <-p.s.done:
+				b, ok = p.StartRead()
+			default:
+			}
+		}
+		if !ok {
+			return
+		}
+		fn(b)
+		p.CommitRead(b)
+	}
+}
+
+// CommitReadCtx is CommitRead that gives up when ctx is done, see CommitWriteCtx.
+// If ctx is done first, b is still owned by the caller.
+func (p Pump) CommitReadCtx(ctx context.Context, b Interval) error {
+	if p.checked != nil {
+		p.checked.commit(b)
+	}
+	if p.latency != nil {
+		p.latency.read(b)
+	}
+	if err := p.freeCtx(ctx, b); err != nil {
+		if p.checked != nil {
+			// b is still being read.
+			p.checked.start(b)
+		}
+		return err
+	}
+	p.s.readBack()
+	p.event(ReadCommitted, b)
+	return nil
 }
 
+// CancelWrite returns b to the writers without handing anything over to the readers.
+// It is the way to abandon an interval returned by StartWrite, say, when the writer fails to fill it:
+// the full blocks of b are free again, even if b was shrunk.
 func (p Pump) CancelWrite(b Interval) {
-	b.End = b.Start + p.blockSize
-	p.toWrite <- b
+	defer p.s.endWrite()
+	p.free(b)
+	if p.ord != nil {
+		p.commitOrdered(b.seq, nil)
+	}
+	p.event(WriteCancelled, b)
+}
+
+// span returns the interval of n blocks starting at block.
+func (p Pump) span(block, n int) Interval {
+	return Interval{Start: block * p.blockSize, End: (block + n) * p.blockSize, block: block, blocks: n}
+}
+
+// free returns the blocks of b to the writers, one by one.
+func (p Pump) free(b Interval) {
+	for i := b.block; i < b.block+b.blocks; i++ {
+		p.c.toWrite <- p.span(i, 1)
+	}
+}
+
+// freeCtx is free that gives up when ctx is done.
+// Only waits for the first block: the pump only runs out of room if it is misused, see CommitWriteCtx.
+func (p Pump) freeCtx(ctx context.Context, b Interval) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case p.c.toWrite <- p.span(b.block, 1):
+	}
+	p.free(p.span(b.block+1, b.blocks-1))
+	return nil
+}
+
+// trim splits b into the blocks up to b.End and the rest.
+func (p Pump) trim(b Interval) (Interval, Interval) {
+	n := min(max((b.End-b.block*p.blockSize+p.blockSize-1)/p.blockSize, 1), b.blocks)
+	tail := p.span(b.block+n, b.blocks-n)
+	b.blocks = n
+	return b, tail
+}
+
+// PendingReads returns the number of written intervals waiting to be read.
+// Under concurrent use it is a point-in-time snapshot, it may be stale by the time it is returned.
+func (p Pump) PendingReads() int {
+	p.head.mu.Lock()
+	defer p.head.mu.Unlock()
+	n := len(p.c.toRead)
+	if p.head.ok {
+		n++
+	}
+	return n
+}
+
+// Readable returns the number of elements in the written intervals waiting to be read:
+// the total length of the intervals PendingReads counts.
+// It is updated atomically as the intervals are committed and taken, so it is a consistent snapshot,
+// see PendingReads. Commits held back by an ordered pump are not counted until they are released.
+func (p Pump) Readable() int {
+	return int(p.s.readable.Load())
+}
+
+// FreeWrites returns the number of free intervals waiting to be written to, see PendingReads.
+func (p Pump) FreeWrites() int {
+	return len(p.c.toWrite)
+}
+
+// Capacity returns the total number of intervals (numBlocks passed to New).
+// Intervals being written or read are counted neither by PendingReads nor by FreeWrites.
+func (p Pump) Capacity() int {
+	return cap(p.c.toWrite)
+}
+
+// Reset returns the pump to its initial state, as returned by New: all the blocks are free and the pump is open.
+// Reset does not wait for the operations in flight. It must not be called concurrently with other methods,
+// and the intervals started before Reset must not be committed (or cancelled) after it.
+func (p Pump) Reset() {
+	p.head.mu.Lock()
+	p.head.ok = false
+	select {
+	case <-p.head.ready:
+	default:
+	}
+	p.head.mu.Unlock()
+	for len(p.c.toRead) > 0 {
+		<-p.c.toRead
+	}
+	for len(p.c.toWrite) > 0 {
+		<-p.c.toWrite
+	}
+	p.free(p.span(0, cap(p.c.toWrite)))
+	if p.ord != nil {
+		p.ord.mu.Lock()
+		p.ord.next, p.ord.release = 0, 0
+		clear(p.ord.pending)
+		p.ord.mu.Unlock()
+	}
+	if p.checked != nil {
+		p.checked.reset()
+	}
+	if p.latency != nil {
+		p.latency.reset()
+	}
+	p.s.reset()
+}
+
+// Drain waits until all the intervals committed by the writers are read and committed by the readers,
+// or until ctx is done.
+// Drain does not stop the writers, it returns the first time the readers catch up with them,
+// the writes in flight are not waited for.
+// To flush the pump before shutting it down, stop the writers (or Close the pump) first.
+func (p Pump) Drain(ctx context.Context) error {
+	p.s.mu.Lock()
+	idle := p.s.idle
+	p.s.mu.Unlock()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-idle:
+		return nil
+	}
+}
+
+// Grow adds n free blocks to the pump, after the existing ones: the buffer the intervals point into
+// has to grow to (Capacity()+n)*blockSize elements before the writers get the new blocks.
+// The intervals in flight stay valid, the written ones are read in the same order.
+// Grow replaces the channels of the pump: it must not be called concurrently with other methods,
+// and no goroutine may be waiting in the pump (say, in StartWrite on a full pump), it would keep waiting forever.
+// Panics if n < 0.
+func (p Pump) Grow(n int) {
+	if n < 0 {
+		panic("pump: Grow by a negative number of blocks")
+	}
+	old := cap(p.c.toWrite)
+	c := channels{
+		toRead:  make(chan Interval, old+n),
+		toWrite: make(chan Interval, old+n),
+	}
+	for len(p.c.toRead) > 0 {
+		c.toRead <- <-p.c.toRead
+	}
+	for len(p.c.toWrite) > 0 {
+		c.toWrite <- <-p.c.toWrite
+	}
+	*p.c = c
+	p.free(p.span(old, n))
+}
+
+// Close marks the pump as closed: StartWrite returns false from now on, waiting writers are unblocked.
+// Readers get the intervals already written and the ones being written, then StartRead returns false.
+// Writes in flight should still be committed (or cancelled).
+// Close is idempotent and is safe to call concurrently with other methods.
+func (p Pump) Close() {
+	p.s.close()
 }
@@ -1,9 +1,20 @@
 package pump
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"math/rand"
 	"runtime"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	lfc "github.com/PurpureGecko/go-lfc"
 )
@@ -13,6 +24,1314 @@ const n = 16
 var blockSize = 1024 * 16
 var numBlocks = 128 / 4
 
+func TestNewInvalid(t *testing.T) {
+	for _, tc := range [][2]int{{0, 4}, {-1, 4}, {4, 0}, {4, -1}, {0, 0}} {
+		for name, f := range map[string]func(){
+			"New":          func() { New(tc[0], tc[1]) },
+			"NewOrdered":   func() { NewOrdered(tc[0], tc[1]) },
+			"NewSlicePump": func() { NewSlicePump[byte](tc[0], tc[1]) },
+		} {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Fatalf("expected %s(%d, %d) to panic", name, tc[0], tc[1])
+					}
+				}()
+				f()
+			}()
+		}
+	}
+}
+
+func TestClose(t *testing.T) {
+	const writers, readers = 4, 3
+	p := New(16, 8)
+	arr := make([]int, 16*8)
+	var written, read atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := 1; ; k++ {
+				b, ok := p.StartWrite()
+				if !ok {
+					return
+				}
+				n := k % (b.End - b.Start + 1)
+				for u := b.Start; u < b.Start+n; u++ {
+					arr[u] = 1
+				}
+				written.Add(int64(n))
+				p.CommitWrite(b, n)
+			}
+		}()
+	}
+	var rwg sync.WaitGroup
+	for i := 0; i < readers; i++ {
+		rwg.Add(1)
+		go func() {
+			defer rwg.Done()
+			for {
+				b, ok := p.StartRead()
+				if !ok {
+					return
+				}
+				for u := b.Start; u < b.End; u++ {
+					read.Add(int64(arr[u]))
+					arr[u] = 0
+				}
+				p.CommitRead(b)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	p.Close()
+	p.Close()
+	wg.Wait()
+	rwg.Wait()
+	if written.Load() == 0 || written.Load() != read.Load() {
+		t.Fatalf("expected everything written to be read, wrote %d, read %d", written.Load(), read.Load())
+	}
+	if _, ok := p.StartWrite(); ok {
+		t.Fatalf("expected StartWrite to fail after Close")
+	}
+	if _, err := p.StartReadCtx(context.Background()); err != ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestCloseUnblocks(t *testing.T) {
+	p := New(4, 1)
+	b, _ := p.StartWrite()
+	writers := make(chan bool)
+	go func() {
+		_, ok := p.StartWrite() // No free blocks.
+		writers <- !ok
+	}()
+	go func() {
+		_, err := p.StartWriteCtx(context.Background())
+		writers <- err == ErrClosed
+	}()
+	reader := make(chan bool)
+	go func() {
+		b, ok := p.StartRead()
+		reader <- ok && b.End-b.Start == 3
+	}()
+	time.Sleep(time.Millisecond)
+	p.Close()
+	if !<-writers || !<-writers {
+		t.Fatalf("expected writers to be unblocked by Close")
+	}
+	select {
+	case <-reader:
+		t.Fatalf("expected the reader to wait for the write in flight")
+	case <-time.After(time.Millisecond):
+	}
+	p.CommitWrite(b, 3)
+	if !<-reader {
+		t.Fatalf("expected the reader to get the interval written after Close")
+	}
+	if _, ok := p.StartRead(); ok {
+		t.Fatalf("expected StartRead to fail after the pump is drained")
+	}
+}
+
+func TestTryStart(t *testing.T) {
+	p := New(4, 2)
+	if _, ok := p.TryStartRead(); ok {
+		t.Fatalf("expected nothing to read from an empty pump")
+	}
+	b1, ok1 := p.TryStartWrite()
+	b2, ok2 := p.TryStartWrite()
+	if !ok1 || !ok2 {
+		t.Fatalf("expected two free intervals")
+	}
+	if _, ok := p.TryStartWrite(); ok {
+		t.Fatalf("expected no free intervals")
+	}
+	p.CommitWrite(b1, 2)
+	b, ok := p.TryStartRead()
+	if !ok || b.Start != b1.Start || b.End != b1.Start+2 {
+		t.Fatalf("expected %v, got %v, %t", b1, b, ok)
+	}
+	p.CommitRead(b)
+	p.CancelWrite(b2)
+	p.Close()
+	if _, ok := p.TryStartWrite(); ok {
+		t.Fatalf("expected TryStartWrite to fail after Close")
+	}
+}
+
+func TestCancelWrite(t *testing.T) {
+	p := New(4, 1)
+	b, _ := p.StartWrite()
+	b.End--
+	p.CancelWrite(b)
+	if _, ok := p.TryStartRead(); ok {
+		t.Fatalf("expected a cancelled interval not to be read")
+	}
+	if p.PendingReads() != 0 || p.FreeWrites() != 1 {
+		t.Fatalf("expected the block to be free, got %d pending reads, %d free writes", p.PendingReads(), p.FreeWrites())
+	}
+	b, ok := p.TryStartWrite()
+	if !ok || b.Start != 0 || b.End != 4 {
+		t.Fatalf("expected the full block back, got %v, %t", b, ok)
+	}
+	p.CancelWrite(b)
+	// Cancelled writes do not hold Close back.
+	p.Close()
+	if _, ok := p.StartRead(); ok {
+		t.Fatalf("expected nothing to read after Close")
+	}
+}
+
+func TestStartWriteTimeout(t *testing.T) {
+	p := New(4, 1)
+	b, ok := p.StartWriteTimeout(0)
+	if !ok {
+		t.Fatalf("expected a free interval")
+	}
+	if _, ok := p.StartWriteTimeout(0); ok {
+		t.Fatalf("expected StartWriteTimeout(0) not to wait")
+	}
+	start := time.Now()
+	if _, ok := p.StartWriteTimeout(10 * time.Millisecond); ok {
+		t.Fatalf("expected a timeout on a saturated pump")
+	}
+	if d := time.Since(start); d < 10*time.Millisecond {
+		t.Fatalf("expected to wait for 10ms, waited %v", d)
+	}
+	// The block comes back well before the timeout.
+	go func() {
+		time.Sleep(time.Millisecond)
+		p.CancelWrite(b)
+	}()
+	b, ok = p.StartWriteTimeout(time.Minute)
+	if !ok || b.End-b.Start != 4 {
+		t.Fatalf("expected the full block, got %v, %t", b, ok)
+	}
+	p.CancelWrite(b)
+	// Timeouts do not leak blocks.
+	if p.FreeWrites() != 1 {
+		t.Fatalf("expected 1 free block, got %d", p.FreeWrites())
+	}
+	p.Close()
+	if _, ok := p.StartWriteTimeout(time.Minute); ok {
+		t.Fatalf("expected StartWriteTimeout to fail after Close")
+	}
+}
+
+func TestTryStartConcurrent(t *testing.T) {
+	const writers, readers, perWriter = 4, 4, 1000
+	p := New(8, 4)
+	var written, read atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(try bool) {
+			defer wg.Done()
+			for k := 0; k < perWriter; k++ {
+				var b Interval
+				if try {
+					var ok bool
+					for b, ok = p.TryStartWrite(); !ok; b, ok = p.TryStartWrite() {
+						runtime.Gosched()
+					}
+				} else {
+					b, _ = p.StartWrite()
+				}
+				written.Add(1)
+				p.CommitWrite(b, 1)
+			}
+		}(i%2 == 0)
+	}
+	var rwg sync.WaitGroup
+	for i := 0; i < readers; i++ {
+		rwg.Add(1)
+		go func() {
+			defer rwg.Done()
+			for {
+				b, ok := p.TryStartRead()
+				if !ok {
+					if b, ok = p.StartRead(); !ok {
+						return
+					}
+				}
+				read.Add(int64(b.End - b.Start))
+				p.CommitRead(b)
+			}
+		}()
+	}
+	wg.Wait()
+	p.Close()
+	rwg.Wait()
+	if written.Load() != writers*perWriter || read.Load() != written.Load() {
+		t.Fatalf("expected %d intervals to be written and read, wrote %d, read %d", writers*perWriter, written.Load(), read.Load())
+	}
+}
+
+func TestPeek(t *testing.T) {
+	p := New(4, 3)
+	if _, ok := p.Peek(); ok {
+		t.Fatalf("expected nothing to peek at in an empty pump")
+	}
+	for i := 1; i <= 2; i++ {
+		b, _ := p.StartWrite()
+		p.CommitWrite(b, i)
+	}
+	h, ok := p.Peek()
+	if !ok || h.End-h.Start != 1 {
+		t.Fatalf("expected the first interval, got %v, %t", h, ok)
+	}
+	if h2, _ := p.Peek(); h2 != h {
+		t.Fatalf("expected Peek to return %v again, got %v", h, h2)
+	}
+	if p.PendingReads() != 2 {
+		t.Fatalf("expected 2 pending reads, got %d", p.PendingReads())
+	}
+	if b, _ := p.StartRead(); b != h {
+		t.Fatalf("expected StartRead to return %v, got %v", h, b)
+	}
+	if b, _ := p.Peek(); b.End-b.Start != 2 {
+		t.Fatalf("expected the second interval, got %v", b)
+	}
+	if b, _ := p.TryStartRead(); b.End-b.Start != 2 {
+		t.Fatalf("expected TryStartRead to return the second interval, got %v", b)
+	}
+	if _, ok := p.Peek(); ok {
+		t.Fatalf("expected nothing to peek at")
+	}
+}
+
+func TestReadable(t *testing.T) {
+	p := New(4, 8)
+	for _, n := range []int{1, 4, 2, 4} {
+		b, _ := p.StartWrite()
+		p.CommitWrite(b, n)
+	}
+	b, _ := p.StartWriteN(6)
+	p.CommitWrite(b, 5) // Spans two blocks.
+	if p.Readable() != 16 {
+		t.Fatalf("expected 16 elements to read, got %d", p.Readable())
+	}
+	p.Peek()
+	if p.Readable() != 16 {
+		t.Fatalf("expected Peek not to change Readable, got %d", p.Readable())
+	}
+	b, _ = p.StartRead()
+	b.End = b.Start // Shrinking the interval does not matter.
+	p.CommitRead(b)
+	if p.Readable() != 15 {
+		t.Fatalf("expected 15 elements to read, got %d", p.Readable())
+	}
+	p.Reset()
+	if p.Readable() != 0 {
+		t.Fatalf("expected Reset to clear Readable, got %d", p.Readable())
+	}
+
+	o := NewOrdered(4, 2)
+	first, _ := o.StartWrite()
+	second, _ := o.StartWrite()
+	o.CommitWrite(second, 3)
+	if o.Readable() != 0 {
+		t.Fatalf("expected the held back commit not to be readable, got %d", o.Readable())
+	}
+	o.CommitWrite(first, 2)
+	if o.Readable() != 5 {
+		t.Fatalf("expected 5 elements to read, got %d", o.Readable())
+	}
+}
+
+func TestReadableConcurrent(t *testing.T) {
+	const writers, k = 4, 1000
+	p := New(4, 4)
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < k; j++ {
+				b, _ := p.StartWrite()
+				p.CommitWrite(b, 1+j%4)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		p.Close()
+	}()
+	for b, ok := p.StartRead(); ok; b, ok = p.StartRead() {
+		if n := p.Readable(); n < 0 || n > 4*4 {
+			t.Errorf("expected Readable within [0, 16], got %d", n)
+		}
+		p.CommitRead(b)
+	}
+	if p.Readable() != 0 {
+		t.Fatalf("expected nothing to read, got %d", p.Readable())
+	}
+}
+
+func TestPeekConcurrent(t *testing.T) {
+	const writers, readers, perWriter = 4, 2, 1000
+	p := New(1, 4)
+	buf := make([]int, 4)
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for k := 0; k < perWriter; k++ {
+				b, _ := p.StartWrite()
+				buf[b.Start] = i*perWriter + k
+				p.CommitWrite(b, 1)
+			}
+		}(i)
+	}
+	stop := make(chan struct{})
+	peeked := make(chan struct{})
+	go func() {
+		defer close(peeked)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				p.Peek()
+			}
+		}
+	}()
+	var mu sync.Mutex
+	seen := make([]int, writers*perWriter)
+	var rwg sync.WaitGroup
+	for i := 0; i < readers; i++ {
+		rwg.Add(1)
+		go func(ctx bool) {
+			defer rwg.Done()
+			for {
+				var b Interval
+				if ctx {
+					var err error
+					if b, err = p.StartReadCtx(context.Background()); err != nil {
+						return
+					}
+				} else {
+					var ok bool
+					if b, ok = p.StartRead(); !ok {
+						return
+					}
+				}
+				mu.Lock()
+				seen[buf[b.Start]]++
+				mu.Unlock()
+				p.CommitRead(b)
+			}
+		}(i%2 == 0)
+	}
+	wg.Wait()
+	p.Close()
+	rwg.Wait()
+	close(stop)
+	<-peeked
+	for v, n := range seen {
+		if n != 1 {
+			t.Fatalf("expected %d to be read once, read %d times", v, n)
+		}
+	}
+}
+
+func TestPartialNoOverlap(t *testing.T) {
+	const blockSize, numBlocks = 8, 4
+	r := rand.New(rand.NewSource(41))
+	p := New(blockSize, numBlocks)
+	var writing, reading []Interval
+	live := func() []Interval { return append(slices.Clone(writing), reading...) }
+	check := func(b Interval) {
+		if b.Start < 0 || b.End > blockSize*numBlocks || b.Start > b.End {
+			t.Fatalf("interval %v is out of the buffer", b)
+		}
+		for _, x := range live() {
+			if b.Start < x.End && x.Start < b.End {
+				t.Fatalf("interval %v overlaps %v", b, x)
+			}
+		}
+	}
+	take := func(xs *[]Interval) Interval {
+		i := r.Intn(len(*xs))
+		b := (*xs)[i]
+		*xs = slices.Delete(*xs, i, i+1)
+		return b
+	}
+	for i := 0; i < 100000; i++ {
+		switch r.Intn(4) {
+		case 0:
+			if b, ok := p.TryStartWrite(); ok {
+				if b.End-b.Start != blockSize {
+					t.Fatalf("expected a full block, got %v", b)
+				}
+				check(b)
+				writing = append(writing, b)
+			}
+		case 1:
+			if len(writing) > 0 {
+				// Skip a few elements and write a few.
+				b := take(&writing)
+				b.Start += r.Intn(blockSize / 2)
+				p.CommitWrite(b, r.Intn(b.End-b.Start+1))
+			}
+		case 2:
+			if b, ok := p.TryStartRead(); ok {
+				check(b)
+				reading = append(reading, b)
+			}
+		case 3:
+			if len(reading) > 0 {
+				// Read a part of the block.
+				b := take(&reading)
+				b.Start += r.Intn(b.End - b.Start + 1)
+				p.CommitRead(b)
+			}
+		}
+	}
+}
+
+func TestStartWriteNCoalesce(t *testing.T) {
+	const blockSize, numBlocks = 4, 4
+	p := New(blockSize, numBlocks)
+	b, ok := p.StartWriteN(10)
+	if !ok || b.Start != 0 || b.End != 3*blockSize {
+		t.Fatalf("expected [0, %d), got %v, %t", 3*blockSize, b, ok)
+	}
+	if p.FreeWrites() != 1 {
+		t.Fatalf("expected 1 free block, got %d", p.FreeWrites())
+	}
+	// The block past the written part is freed right away.
+	p.CommitWrite(b, blockSize+1)
+	if p.FreeWrites() != 2 {
+		t.Fatalf("expected 2 free blocks, got %d", p.FreeWrites())
+	}
+	r, _ := p.StartRead()
+	if r.Start != 0 || r.End != blockSize+1 {
+		t.Fatalf("expected [0, %d), got %v", blockSize+1, r)
+	}
+	p.CommitRead(r)
+	if p.FreeWrites() != numBlocks {
+		t.Fatalf("expected %d free blocks, got %d", numBlocks, p.FreeWrites())
+	}
+	b, _ = p.StartWriteN(numBlocks * blockSize)
+	if b.Start != 0 || b.End != numBlocks*blockSize {
+		t.Fatalf("expected the whole buffer, got %v", b)
+	}
+	p.CancelWrite(b)
+	b, _ = p.StartWriteN(1)
+	if b.End-b.Start != blockSize {
+		t.Fatalf("expected a single block, got %v", b)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("expected StartWriteN to panic on a size above the buffer")
+			}
+		}()
+		p.StartWriteN(numBlocks*blockSize + 1)
+	}()
+}
+
+func TestStartWriteNFragmented(t *testing.T) {
+	const blockSize, numBlocks = 4, 4
+	p := New(blockSize, numBlocks)
+	var bs []Interval
+	for i := 0; i < numBlocks; i++ {
+		b, _ := p.StartWrite()
+		bs = append(bs, b)
+	}
+	// Free blocks 0 and 2: there are 2 free blocks, but they are not adjacent.
+	p.CancelWrite(bs[0])
+	p.CancelWrite(bs[2])
+	got := make(chan Interval)
+	go func() {
+		b, _ := p.StartWriteN(2 * blockSize)
+		got <- b
+	}()
+	select {
+	case b := <-got:
+		t.Fatalf("expected StartWriteN to wait for adjacent blocks, got %v", b)
+	case <-time.After(10 * time.Millisecond):
+	}
+	p.CancelWrite(bs[1])
+	b := <-got
+	if b.End-b.Start != 2*blockSize || b.Start%blockSize != 0 || b.Start > blockSize {
+		t.Fatalf("expected two adjacent blocks out of the first three, got %v", b)
+	}
+	if p.FreeWrites() != 1 {
+		t.Fatalf("expected the third block back, got %d free", p.FreeWrites())
+	}
+
+	// Close unblocks StartWriteN and gives the held blocks back.
+	p.CancelWrite(b)
+	go func() {
+		b, ok := p.StartWriteN(numBlocks * blockSize)
+		if ok {
+			t.Errorf("expected StartWriteN to fail after Close, got %v", b)
+		}
+		got <- b
+	}()
+	time.Sleep(10 * time.Millisecond)
+	p.Close()
+	<-got
+	if p.FreeWrites() != numBlocks-1 {
+		t.Fatalf("expected %d free blocks, got %d", numBlocks-1, p.FreeWrites())
+	}
+}
+
+func TestStartWriteNConcurrent(t *testing.T) {
+	const blockSize, numBlocks, writers, perWriter = 4, 8, 4, 1000
+	p := New(blockSize, numBlocks)
+	buf := make([]int32, blockSize*numBlocks)
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(int64(i)))
+			for k := 0; k < perWriter; k++ {
+				b, _ := p.StartWriteN(1 + r.Intn(3*blockSize))
+				n := r.Intn(b.End - b.Start + 1)
+				for j := b.Start; j < b.Start+n; j++ {
+					buf[j] = int32(i*perWriter + k)
+				}
+				p.CommitWrite(b, n)
+			}
+		}(i)
+	}
+	var read atomic.Int64
+	var rwg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		rwg.Add(1)
+		go func() {
+			defer rwg.Done()
+			for {
+				b, ok := p.StartRead()
+				if !ok {
+					return
+				}
+				for j := b.Start; j < b.End; j++ {
+					if buf[j] != buf[b.Start] {
+						t.Errorf("interval %v was overwritten", b)
+						break
+					}
+				}
+				read.Add(1)
+				p.CommitRead(b)
+			}
+		}()
+	}
+	wg.Wait()
+	p.Close()
+	rwg.Wait()
+	if p.FreeWrites() != numBlocks {
+		t.Fatalf("expected all %d blocks to be free, got %d", numBlocks, p.FreeWrites())
+	}
+	if read.Load() == 0 {
+		t.Fatalf("expected something to be read")
+	}
+}
+
+func TestStartReadN(t *testing.T) {
+	const numBlocks = 8
+	single, batch := New(8, numBlocks), New(8, numBlocks)
+	for _, p := range []Pump{single, batch} {
+		for i := 1; i <= 5; i++ {
+			b, _ := p.StartWrite()
+			p.CommitWrite(b, i)
+		}
+		p.Close()
+	}
+	var want, got []Interval
+	for b, ok := single.StartRead(); ok; b, ok = single.StartRead() {
+		want = append(want, b)
+	}
+	for _, n := range []int{2, 1, 10} {
+		bs := batch.StartReadN(n)
+		if len(bs) > n {
+			t.Fatalf("expected at most %d intervals, got %v", n, bs)
+		}
+		got = append(got, bs...)
+		batch.CommitReadN(bs)
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if bs := batch.StartReadN(10); bs != nil {
+		t.Fatalf("expected nil after Close, got %v", bs)
+	}
+	if batch.FreeWrites() != numBlocks {
+		t.Fatalf("expected all %d blocks to be free, got %d", numBlocks, batch.FreeWrites())
+	}
+}
+
+func TestReadAll(t *testing.T) {
+	const k, blockSize = 6, 4
+	p := New(blockSize, k+2)
+	p.ReadAll(func(b Interval) { t.Fatalf("expected nothing to read, got %v", b) })
+	for i := 0; i < k; i++ {
+		b, _ := p.StartWrite()
+		p.CommitWrite(b, 1)
+	}
+	inFlight, _ := p.StartWrite()
+	p.Close()
+	go p.CommitWrite(inFlight, 1)
+	seen := map[Interval]int{}
+	p.ReadAll(func(b Interval) { seen[b]++ })
+	if len(seen) != k+1 {
+		t.Fatalf("expected %d intervals, got %v", k+1, seen)
+	}
+	for b, n := range seen {
+		if n != 1 {
+			t.Fatalf("%v: expected to be read once, read %d times", b, n)
+		}
+	}
+	if p.FreeWrites() != k+2 {
+		t.Fatalf("expected all %d blocks to be free, got %d", k+2, p.FreeWrites())
+	}
+}
+
+func TestOrdered(t *testing.T) {
+	const writers, total = 5, 10000
+	p := NewOrdered(1, 8)
+	arr := make([]int, 8)
+	var mu sync.Mutex
+	next, starts := 0, 0
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				// Take the interval and the next value of the sequence atomically,
+				// so the value order is the start order.
+				mu.Lock()
+				b, ok := p.StartWrite()
+				if !ok {
+					mu.Unlock()
+					return
+				}
+				starts++
+				skip := starts%7 == 0
+				v := next
+				if !skip {
+					next++
+				}
+				mu.Unlock()
+				if v == total {
+					p.CancelWrite(b)
+					p.Close()
+					return
+				}
+				runtime.Gosched()
+				if skip {
+					p.CommitWrite(b, 0) // Nothing written, the next start gets the same value.
+					continue
+				}
+				arr[b.Start] = v
+				p.CommitWrite(b, 1)
+			}
+		}()
+	}
+	want := 0
+	for {
+		b, ok := p.StartRead()
+		if !ok {
+			break
+		}
+		if arr[b.Start] != want {
+			t.Fatalf("expected %d, got %d", want, arr[b.Start])
+		}
+		want++
+		p.CommitRead(b)
+	}
+	wg.Wait()
+	if want != total {
+		t.Fatalf("expected to read %d values, got %d", total, want)
+	}
+}
+
+func TestReset(t *testing.T) {
+	const blockSize, numBlocks = 4, 4
+	for _, ordered := range []bool{false, true} {
+		p, fresh := New(blockSize, numBlocks), New(blockSize, numBlocks)
+		if ordered {
+			p, fresh = NewOrdered(blockSize, numBlocks), NewOrdered(blockSize, numBlocks)
+		}
+		// Leave the pump in a mess: committed, peeked, in-flight and out-of-order intervals.
+		var bs []Interval
+		for i := 0; i < numBlocks; i++ {
+			b, _ := p.StartWrite()
+			bs = append(bs, b)
+		}
+		p.CommitWrite(bs[2], 1)
+		p.CommitWrite(bs[0], 2)
+		p.StartRead()
+		p.Peek()
+		p.Close()
+
+		p.Reset()
+		if p.PendingReads() != 0 || p.FreeWrites() != numBlocks {
+			t.Fatalf("expected an empty pump, got %d pending, %d free", p.PendingReads(), p.FreeWrites())
+		}
+		if _, ok := p.TryStartRead(); ok {
+			t.Fatalf("expected nothing to read after Reset")
+		}
+		for i := 0; i < numBlocks; i++ {
+			b, ok := p.StartWrite()
+			want, _ := fresh.StartWrite()
+			if !ok || b != want {
+				t.Fatalf("expected %v, got %v, %t", want, b, ok)
+			}
+			bs[i] = b
+		}
+		for i := numBlocks - 1; i >= 0; i-- {
+			p.CommitWrite(bs[i], i+1)
+		}
+		p.Close()
+		for i := 0; i < numBlocks; i++ {
+			b, ok := p.StartRead()
+			if !ok {
+				t.Fatalf("expected %d intervals to read, got %d", numBlocks, i)
+			}
+			if ordered && b.End-b.Start != i+1 {
+				t.Fatalf("expected interval %d to have length %d, got %v", i, i+1, b)
+			}
+			p.CommitRead(b)
+		}
+		if _, ok := p.StartRead(); ok {
+			t.Fatalf("expected StartRead to fail after Close")
+		}
+	}
+}
+
+func TestCommitCtx(t *testing.T) {
+	p := New(4, 1)
+	b, _ := p.StartWrite()
+	// A stalled reader: the pump is misused so that there is no room for the commit.
+	p.c.toRead <- Interval{}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.CommitWriteCtx(ctx, b, 2); err != context.DeadlineExceeded {
+		t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	<-p.c.toRead
+	// b is still ours.
+	if err := p.CommitWriteCtx(context.Background(), b, 2); err != nil {
+		t.Fatalf("expected the commit to succeed, got %v", err)
+	}
+	r, _ := p.StartRead()
+	if r.End-r.Start != 2 {
+		t.Fatalf("expected an interval of length 2, got %v", r)
+	}
+
+	// A stalled writer.
+	p.c.toWrite <- Interval{}
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.CommitReadCtx(ctx, r); err != context.DeadlineExceeded {
+		t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	<-p.c.toWrite
+	if err := p.CommitReadCtx(context.Background(), r); err != nil {
+		t.Fatalf("expected the commit to succeed, got %v", err)
+	}
+	if b, ok := p.TryStartWrite(); !ok || b.End-b.Start != 4 {
+		t.Fatalf("expected the full block, got %v, %t", b, ok)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	const k = 10
+	p := New(4, k)
+	if err := p.Drain(context.Background()); err != nil {
+		t.Fatalf("expected an empty pump to be drained, got %v", err)
+	}
+	for i := 0; i < k; i++ {
+		b, _ := p.StartWrite()
+		p.CommitWrite(b, 1)
+	}
+	p.Close()
+	var read atomic.Int64
+	go func() {
+		for {
+			b, ok := p.StartRead()
+			if !ok {
+				return
+			}
+			time.Sleep(time.Millisecond)
+			read.Add(1)
+			p.CommitRead(b)
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if err := p.Drain(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if err := p.Drain(context.Background()); err != nil {
+		t.Fatalf("expected Drain to succeed, got %v", err)
+	}
+	if read.Load() != k {
+		t.Fatalf("expected all %d intervals to be read, got %d", k, read.Load())
+	}
+}
+
+func TestFair(t *testing.T) {
+	const writers, rounds = 8, 20
+	p := NewFair(1, 1)
+	// Hold the only block while the writers queue up one by one, so the order of arrival is known.
+	first, _ := p.StartWrite()
+	var mu sync.Mutex
+	var order []int
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				b, ok := p.StartWrite()
+				if !ok {
+					return
+				}
+				mu.Lock()
+				order = append(order, i)
+				mu.Unlock()
+				p.CommitWrite(b, 1)
+			}
+		}()
+		for p.fair.queued() < i+1 {
+			runtime.Gosched()
+		}
+	}
+	p.CancelWrite(first)
+	for i := 0; i < writers*rounds; i++ {
+		b, _ := p.StartRead()
+		// The block goes back once the writer that filled it is in the queue again:
+		// then every writer is waiting, and the queue alone decides who is next.
+		for p.fair.queued() < writers {
+			runtime.Gosched()
+		}
+		p.CommitRead(b)
+	}
+	p.Close()
+	wg.Wait()
+	mu.Lock()
+	defer mu.Unlock()
+	for i, w := range order {
+		if w != i%writers {
+			t.Fatalf("expected the writers to take turns, got %v", order)
+		}
+	}
+	// The block freed by the last CommitRead may go to the next writer before Close.
+	if n := len(order); n != writers*rounds && n != writers*rounds+1 {
+		t.Fatalf("expected %d grants, got %d", writers*rounds, n)
+	}
+}
+
+func TestFairCancel(t *testing.T) {
+	p := NewFair(1, 1)
+	b, _ := p.StartWrite()
+	if _, ok := p.TryStartWrite(); ok {
+		t.Fatalf("expected no free intervals")
+	}
+	// A writer waits for the turn, the one after it gives up.
+	got := make(chan Interval)
+	go func() {
+		b, _ := p.StartWrite()
+		got <- b
+	}()
+	for p.fair.queued() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, ok := p.StartWriteTimeout(time.Millisecond); ok {
+		t.Fatalf("expected a timeout")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.StartWriteCtx(ctx); err != context.Canceled {
+		t.Fatalf("expected %v, got %v", context.Canceled, err)
+	}
+	p.CancelWrite(b)
+	b = <-got
+	p.CancelWrite(b)
+	if b, ok := p.TryStartWrite(); !ok {
+		t.Fatalf("expected a free interval")
+	} else {
+		p.CancelWrite(b)
+	}
+	p.Close()
+	if _, ok := p.StartWrite(); ok {
+		t.Fatalf("expected StartWrite to fail after Close")
+	}
+}
+
+// expectPanic fails the test unless f panics.
+func expectPanic(t *testing.T, name string, f func()) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("%s: expected a panic", name)
+		}
+	}()
+	f()
+}
+
+func TestChecked(t *testing.T) {
+	p := NewChecked(4, 2)
+	for i := 0; i < 2; i++ {
+		b, _ := p.StartWrite()
+		p.CommitWrite(b, 4)
+	}
+	b1, _ := p.StartRead()
+	b2, _ := p.StartRead()
+	p.CommitRead(b1)
+	expectPanic(t, "double CommitRead", func() { p.CommitRead(b1) })
+	w, _ := p.StartWrite()
+	expectPanic(t, "CommitRead of a write", func() { p.CommitRead(w) })
+	expectPanic(t, "CommitRead of a made up interval", func() { p.CommitRead(Interval{Start: 0, End: 4}) })
+	// Shrinking the interval is fine.
+	b2.End--
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p.CommitWrite(w, 4)
+	if err := p.CommitReadCtx(ctx, b2); err != nil {
+		// The block fits into the pump, CommitReadCtx may or may not notice ctx.
+		p.CommitRead(b2)
+	}
+	if p.FreeWrites() != 1 || p.PendingReads() != 1 {
+		t.Fatalf("expected the failed commits not to change the pump, got %d free, %d pending", p.FreeWrites(), p.PendingReads())
+	}
+}
+
+func TestStats(t *testing.T) {
+	const writers, k = 4, 256
+	p := New(4, 8)
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < k; j++ {
+				b, _ := p.StartWrite()
+				// Writes of 1, 2, 3 elements, and a cancelled one.
+				p.CommitWrite(b, j%4)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		p.Close()
+	}()
+	for {
+		b, ok := p.StartRead()
+		if !ok {
+			break
+		}
+		p.CommitRead(b)
+	}
+	want := Stats{Written: writers * k / 4 * 6, WrittenBlocks: writers * k / 4 * 3, ReadBlocks: writers * k / 4 * 3, Fill: 0.5}
+	if got := p.Stats(); got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+	p.Reset()
+	if got := p.Stats(); got != (Stats{}) {
+		t.Fatalf("expected Reset to clear the stats, got %+v", got)
+	}
+}
+
+func TestLatency(t *testing.T) {
+	const deadline, slow = 20 * time.Millisecond, 50 * time.Millisecond
+	var late []time.Duration
+	lt := NewLatencyTracker(deadline, func(b Interval, d time.Duration) { late = append(late, d) })
+	p := New(4, 4).WithLatency(lt)
+	for _, sleep := range []time.Duration{0, slow} {
+		b, _ := p.StartWrite()
+		p.CommitWrite(b, 1)
+		b, _ = p.StartRead()
+		time.Sleep(sleep)
+		if err := p.CommitReadCtx(context.Background(), b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(late) != 1 || late[0] < slow || lt.Over() != 1 {
+		t.Fatalf("expected a single read later than %v, got %v", slow, late)
+	}
+	hist := lt.Histogram()
+	var fast int64
+	for _, c := range hist[:bits.Len64(uint64(deadline.Microseconds()))] {
+		fast += c
+	}
+	if fast != 1 || hist[bits.Len64(uint64(late[0].Microseconds()))] != 1 {
+		t.Fatalf("expected a fast read and a slow one, got %v", hist)
+	}
+	p.Reset()
+	if lt.Over() != 0 || slices.Max(lt.Histogram()) != 0 {
+		t.Fatalf("expected Reset to clear the tracker, got %v", lt.Histogram())
+	}
+}
+
+func TestObserver(t *testing.T) {
+	var evs []Event
+	p := New(4, 2).WithObserver(func(ev Event) { evs = append(evs, ev) })
+	w, _ := p.StartWrite()
+	p.CommitWrite(w, 3)
+	r, _ := p.StartRead()
+	p.CommitRead(r)
+	c, _ := p.TryStartWrite()
+	p.CancelWrite(c)
+
+	w3 := w
+	w3.End = w.Start + 3
+	want := []Event{
+		{WriteStarted, w, 4},
+		{WriteCommitted, w3, 3},
+		{ReadStarted, w3, 3},
+		{ReadCommitted, w3, 3},
+		{WriteStarted, c, 4},
+		{WriteCancelled, c, 4},
+	}
+	if !slices.Equal(evs, want) {
+		t.Fatalf("expected %v, got %v", want, evs)
+	}
+}
+
+func TestGrow(t *testing.T) {
+	const blockSize, numBlocks, more = 4, 2, 3
+	buf := make([]int, blockSize*numBlocks)
+	p := New(blockSize, numBlocks)
+	// Saturate the pump: one interval written, one being written.
+	w1, _ := p.StartWrite()
+	for i := w1.Start; i < w1.End; i++ {
+		buf[i] = 1
+	}
+	p.CommitWrite(w1, blockSize)
+	w2, _ := p.StartWrite()
+	if _, ok := p.TryStartWrite(); ok {
+		t.Fatalf("expected a saturated pump")
+	}
+
+	buf = append(buf, make([]int, blockSize*more)...)
+	p.Grow(more)
+	if p.Capacity() != numBlocks+more || p.FreeWrites() != more || p.PendingReads() != 1 {
+		t.Fatalf("expected capacity %d, %d free, 1 pending, got %d, %d, %d",
+			numBlocks+more, more, p.Capacity(), p.FreeWrites(), p.PendingReads())
+	}
+	var bs []Interval
+	for i := 0; i < more; i++ {
+		b, ok := p.TryStartWrite()
+		if !ok || b.Start < numBlocks*blockSize || b.End > len(buf) {
+			t.Fatalf("expected a new block, got %v, %t", b, ok)
+		}
+		for j := b.Start; j < b.End; j++ {
+			buf[j] = 3
+		}
+		bs = append(bs, b)
+	}
+	for i := w2.Start; i < w2.End; i++ {
+		buf[i] = 2
+	}
+	p.CommitWrite(w2, blockSize)
+	for _, b := range bs {
+		p.CommitWrite(b, blockSize)
+	}
+	p.Close()
+	var got []int
+	for b, ok := p.StartRead(); ok; b, ok = p.StartRead() {
+		got = append(got, buf[b.Start:b.End]...)
+		p.CommitRead(b)
+	}
+	want := []int{1, 1, 1, 1, 2, 2, 2, 2, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if p.FreeWrites() != numBlocks+more {
+		t.Fatalf("expected all %d blocks to be free, got %d", numBlocks+more, p.FreeWrites())
+	}
+}
+
+func TestDepth(t *testing.T) {
+	const numBlocks, k = 8, 3
+	p := New(4, numBlocks)
+	if p.Capacity() != numBlocks || p.FreeWrites() != numBlocks || p.PendingReads() != 0 {
+		t.Fatalf("expected an empty pump, got capacity %d, %d free, %d pending", p.Capacity(), p.FreeWrites(), p.PendingReads())
+	}
+	for i := 0; i < k; i++ {
+		b, _ := p.StartWrite()
+		p.CommitWrite(b, 1)
+	}
+	b, _ := p.StartWrite() // In flight, neither free nor pending.
+	if p.PendingReads() != k || p.FreeWrites() != numBlocks-k-1 {
+		t.Fatalf("expected %d pending and %d free, got %d and %d", k, numBlocks-k-1, p.PendingReads(), p.FreeWrites())
+	}
+	p.CancelWrite(b)
+	if p.FreeWrites() != numBlocks-k || p.Capacity() != numBlocks {
+		t.Fatalf("expected %d free, got %d", numBlocks-k, p.FreeWrites())
+	}
+}
+
+func TestWriterReader(t *testing.T) {
+	r := rand.New(rand.NewSource(40))
+	data := make([]byte, 1000000)
+	r.Read(data)
+	buf := make([]byte, 100*7)
+	p := New(100, 7)
+	errs := make(chan error, 1)
+	go func() {
+		w := p.Writer(buf)
+		for rest := data; len(rest) > 0; {
+			k := min(len(rest), r.Intn(500))
+			if n, err := w.Write(rest[:k]); n != k || err != nil {
+				errs <- fmt.Errorf("expected to write %d bytes, wrote %d: %v", k, n, err)
+				return
+			}
+			rest = rest[k:]
+		}
+		p.Close()
+		if _, err := w.Write([]byte{1}); err != ErrClosed {
+			errs <- fmt.Errorf("expected ErrClosed, got %v", err)
+			return
+		}
+		errs <- nil
+	}()
+	rd := p.Reader(buf)
+	got, err := io.ReadAll(iotest.OneByteReader(io.LimitReader(rd, 1000)))
+	if err != nil || !bytes.Equal(got, data[:1000]) {
+		t.Fatalf("expected to read the first 1000 bytes, got %d: %v", len(got), err)
+	}
+	rest, err := io.ReadAll(rd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(append(got, rest...), data) {
+		t.Fatalf("expected to read back what was written")
+	}
+}
+
+func TestFlushingWriter(t *testing.T) {
+	const flushAfter = 20 * time.Millisecond
+	buf := make([]byte, 100*2)
+	p := New(100, 2)
+	w := p.FlushingWriter(buf, flushAfter)
+	// A trickle: 5 bytes, then the writer goes idle without flushing.
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if n, err := w.Write([]byte{byte(i)}); n != 1 || err != nil {
+			t.Fatalf("expected to write 1 byte, wrote %d: %v", n, err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	b, ok := p.StartRead()
+	if d := time.Since(start); !ok || d < flushAfter || d > 50*flushAfter {
+		t.Fatalf("expected the partial block within about %v, got %v, %t after %v", flushAfter, b, ok, d)
+	}
+	if !bytes.Equal(buf[b.Start:b.End], []byte{0, 1, 2, 3, 4}) {
+		t.Fatalf("expected the trickle in one block, got %v", buf[b.Start:b.End])
+	}
+	p.CommitRead(b)
+	// Full blocks are committed right away, the rest on Flush.
+	data := make([]byte, 150)
+	rand.New(rand.NewSource(582)).Read(data)
+	if n, err := w.Write(data); n != len(data) || err != nil {
+		t.Fatalf("expected to write %d bytes, wrote %d: %v", len(data), n, err)
+	}
+	b, _ = p.StartRead()
+	got := slices.Clone(buf[b.Start:b.End])
+	p.CommitRead(b)
+	w.Flush()
+	b, _ = p.StartRead()
+	got = append(got, buf[b.Start:b.End]...)
+	p.CommitRead(b)
+	if !bytes.Equal(got, data) {
+		t.Fatalf("expected to read back what was written")
+	}
+	p.Close()
+	if _, err := w.Write([]byte{1}); err != ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
+
+// shortWriter writes at most n bytes per Write.
+type shortWriter struct {
+	w io.Writer
+	n int
+}
+
+func (w shortWriter) Write(data []byte) (int, error) {
+	return w.w.Write(data[:min(len(data), w.n)])
+}
+
+// cancelWriter cancels the context once it has got n bytes.
+type cancelWriter struct {
+	n      int
+	cancel context.CancelFunc
+}
+
+func (w *cancelWriter) Write(data []byte) (int, error) {
+	w.n -= len(data)
+	if w.n <= 0 {
+		w.cancel()
+	}
+	return len(data), nil
+}
+
+// zeros is an endless reader.
+type zeros struct{}
+
+func (zeros) Read(data []byte) (int, error) {
+	clear(data)
+	return len(data), nil
+}
+
+func TestCopyFrom(t *testing.T) {
+	r := rand.New(rand.NewSource(574))
+	data := make([]byte, 1000000)
+	r.Read(data)
+	p := New(100, 7)
+	var dst bytes.Buffer
+	n, err := p.CopyFrom(context.Background(), &dst, iotest.HalfReader(bytes.NewReader(data)), make([]byte, 100*7))
+	if err != nil || n != int64(len(data)) || !bytes.Equal(dst.Bytes(), data) {
+		t.Fatalf("expected to copy %d bytes, copied %d: %v", len(data), n, err)
+	}
+	if _, ok := p.StartWrite(); ok {
+		t.Fatalf("expected the pump to be closed")
+	}
+}
+
+func TestCopyFromErrors(t *testing.T) {
+	data := make([]byte, 10000)
+	rand.New(rand.NewSource(574)).Read(data)
+	errBoom := errors.New("boom")
+
+	// The data read before the error is written.
+	var dst bytes.Buffer
+	src := io.MultiReader(bytes.NewReader(data), iotest.ErrReader(errBoom))
+	n, err := New(100, 7).CopyFrom(context.Background(), &dst, src, make([]byte, 100*7))
+	if err != errBoom || n != int64(len(data)) || !bytes.Equal(dst.Bytes(), data) {
+		t.Fatalf("expected %d bytes and %v, got %d bytes and %v", len(data), errBoom, n, err)
+	}
+
+	dst.Reset()
+	n, err = New(100, 7).CopyFrom(context.Background(), shortWriter{&dst, 10}, bytes.NewReader(data), make([]byte, 100*7))
+	if err != io.ErrShortWrite || n != 10 || !bytes.Equal(dst.Bytes(), data[:10]) {
+		t.Fatalf("expected 10 bytes and %v, got %d bytes and %v", io.ErrShortWrite, n, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n, err = New(100, 7).CopyFrom(ctx, &cancelWriter{n: 5000, cancel: cancel}, zeros{}, make([]byte, 100*7))
+	if err != context.Canceled || n < 5000 {
+		t.Fatalf("expected at least 5000 bytes and %v, got %d bytes and %v", context.Canceled, n, err)
+	}
+}
+
 func BenchmarkPump(b *testing.B) {
 	p := New(blockSize, numBlocks)
 	arr := make([]int, blockSize*numBlocks)
@@ -25,7 +1344,7 @@ func BenchmarkPump(b *testing.B) {
 		go func() {
 			defer wg.Done()
 			for k := 0; k < b.N/blockSize; k++ {
-				b := p.StartWrite()
+				b, _ := p.StartWrite()
 				for u := b.Start; u < b.End; u++ {
 					arr[u]++
 				}
@@ -37,7 +1356,7 @@ func BenchmarkPump(b *testing.B) {
 			sum := 0
 			defer wg.Done()
 			for k := 0; k < b.N/blockSize; k++ {
-				b := p.StartRead()
+				b, _ := p.StartRead()
 				for u := b.Start; u < b.End; u++ {
 					sum += arr[u]
 				}
@@ -48,6 +1367,44 @@ func BenchmarkPump(b *testing.B) {
 	wg.Wait()
 }
 
+func BenchmarkPumpReadN(b *testing.B) {
+	p := New(blockSize, numBlocks)
+	arr := make([]int, blockSize*numBlocks)
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := 0; k < b.N/blockSize; k++ {
+				b, _ := p.StartWrite()
+				for u := b.Start; u < b.End; u++ {
+					arr[u]++
+				}
+				p.CommitWrite(b, b.End-b.Start)
+			}
+		}()
+		wg.Add(1)
+		go func() {
+			sum := 0
+			defer wg.Done()
+			for k := 0; k < b.N/blockSize; {
+				bs := p.StartReadN(min(numBlocks, b.N/blockSize-k))
+				for _, b := range bs {
+					for u := b.Start; u < b.End; u++ {
+						sum += arr[u]
+					}
+				}
+				p.CommitReadN(bs)
+				k += len(bs)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
 func BenchmarkChan(b *testing.B) {
 	ch := make(chan int, blockSize*numBlocks)
 	b.ResetTimer()
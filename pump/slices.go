@@ -0,0 +1,158 @@
+package pump
+
+import "context"
+
+// SlicePump is a Pump that owns its buffers: it hands out []T blocks instead of intervals
+// into a slice managed by the caller.
+// Writers fill a block and commit it, readers get the filled part of the block and commit it back.
+// A block can not be extended past its size: its capacity is blockSize.
+type SlicePump[T any] struct {
+	toRead  chan []T
+	toWrite chan []T
+	s       *state
+	zero    bool // Clear the blocks before they go back to the writers, see WithZeroOnRecycle.
+}
+
+// NewSlicePump creates a new SlicePump with numBlocks blocks of blockSize elements each.
+// Panics if blockSize <= 0 or numBlocks <= 0, see New.
+func NewSlicePump[T any](blockSize int, numBlocks int) SlicePump[T] {
+	checkSize(blockSize, numBlocks)
+	arena := make([]T, blockSize*numBlocks)
+	toWrite := make(chan []T, numBlocks)
+	for i := 0; i < numBlocks; i++ {
+		toWrite <- arena[i*blockSize : (i+1)*blockSize : (i+1)*blockSize]
+	}
+	return SlicePump[T]{
+		toRead:  make(chan []T, numBlocks),
+		toWrite: toWrite,
+		s:       newState(),
+	}
+}
+
+// WithZeroOnRecycle returns a copy of p that clears every block it returns to the writers
+// (on CommitRead, CancelWrite and empty CommitWrite), so whatever was written to a block,
+// say, keys or personal data, does not linger in the arena and can not reach the next writer or a reader.
+// Clearing costs an extra pass over the whole block on every recycle, even if only a part of it was written.
+// Next to the copying in Bytes.Writer and Bytes.Reader it is a few percent, see BenchmarkBytesZeroOnRecycle.
+// The readers and the writers have to use the returned copy (or its copies): p itself does not clear.
+func (p SlicePump[T]) WithZeroOnRecycle() SlicePump[T] {
+	p.zero = true
+	return p
+}
+
+// recycle returns b to the writers.
+func (p SlicePump[T]) recycle(b []T) {
+	b = b[:cap(b)]
+	if p.zero {
+		clear(b)
+	}
+	p.toWrite <- b
+}
+
+// StartWrite waits for a free block to write to.
+// Returns false if the pump is closed.
+func (p SlicePump[T]) StartWrite() ([]T, bool) {
+	if !p.s.startWrite() {
+		return nil, false
+	}
+	select {
+	case b := <-p.toWrite:
+		return b, true
+	case <-p.s.done:
+		p.s.endWrite()
+		return nil, false
+	}
+}
+
+// StartWriteCtx is StartWrite that gives up when ctx is done.
+// Returns ErrClosed if the pump is closed.
+func (p SlicePump[T]) StartWriteCtx(ctx context.Context) ([]T, error) {
+	if !p.s.startWrite() {
+		return nil, ErrClosed
+	}
+	select {
+	case <-ctx.Done():
+		p.s.endWrite()
+		return nil, ctx.Err()
+	case <-p.s.done:
+		p.s.endWrite()
+		return nil, ErrClosed
+	case b := <-p.toWrite:
+		return b, nil
+	}
+}
+
+// CommitWrite hands the first written elements of b over to the readers.
+// b must be a block returned by StartWrite.
+// If nothing was written, b goes back to the writers.
+func (p SlicePump[T]) CommitWrite(b []T, written int) {
+	defer p.s.endWrite()
+	if written == 0 {
+		p.recycle(b)
+		return
+	}
+	p.s.readable.Add(int64(written))
+	p.toRead <- b[:written]
+}
+
+// CancelWrite returns b to the writers without handing anything over to the readers.
+func (p SlicePump[T]) CancelWrite(b []T) {
+	defer p.s.endWrite()
+	p.recycle(b)
+}
+
+// StartRead waits for a written block to read from, see Pump.StartRead.
+func (p SlicePump[T]) StartRead() ([]T, bool) {
+	select {
+	case b := <-p.toRead:
+		return p.readStarted(b), true
+	case <-p.s.drained:
+		select {
+		case b := <-p.toRead:
+			return p.readStarted(b), true
+		default:
+			return nil, false
+		}
+	}
+}
+
+// StartReadCtx is StartRead that gives up when ctx is done.
+// Returns ErrClosed if the pump is closed and there is nothing left to read.
+func (p SlicePump[T]) StartReadCtx(ctx context.Context) ([]T, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case b := <-p.toRead:
+		return p.readStarted(b), nil
+	case <-p.s.drained:
+		select {
+		case b := <-p.toRead:
+			return p.readStarted(b), nil
+		default:
+			return nil, ErrClosed
+		}
+	}
+}
+
+// readStarted takes b out of Readable.
+func (p SlicePump[T]) readStarted(b []T) []T {
+	p.s.readable.Add(-int64(len(b)))
+	return b
+}
+
+// Readable returns the number of elements in the written blocks waiting to be read, see Pump.Readable.
+// For Bytes, it is the number of bytes available.
+func (p SlicePump[T]) Readable() int {
+	return int(p.s.readable.Load())
+}
+
+// CommitRead returns b to the writers.
+// b must be a block returned by StartRead.
+func (p SlicePump[T]) CommitRead(b []T) {
+	p.recycle(b)
+}
+
+// Close marks the pump as closed, see Pump.Close.
+func (p SlicePump[T]) Close() {
+	p.s.close()
+}
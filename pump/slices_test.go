@@ -0,0 +1,145 @@
+package pump
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestSlicePumpBytes(t *testing.T) {
+	r := rand.New(rand.NewSource(39))
+	data := make([]byte, 100000)
+	r.Read(data)
+	p := NewSlicePump[byte](64, 4)
+	go func() {
+		for rest := data; len(rest) > 0; {
+			b, _ := p.StartWrite()
+			if cap(b) != 64 {
+				panic("unexpected block capacity")
+			}
+			n := copy(b, rest[:min(len(rest), 1+r.Intn(len(b)))])
+			rest = rest[n:]
+			p.CommitWrite(b, n)
+		}
+		p.Close()
+	}()
+	var got []byte
+	for {
+		b, ok := p.StartRead()
+		if !ok {
+			break
+		}
+		got = append(got, b...)
+		p.CommitRead(b)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("expected to read back what was written")
+	}
+}
+
+type point struct {
+	x, y int
+	name string
+}
+
+func TestSlicePumpStruct(t *testing.T) {
+	const writers, perWriter = 4, 1000
+	p := NewSlicePump[point](8, 4)
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := 0; k < perWriter; {
+				b, err := p.StartWriteCtx(context.Background())
+				if err != nil {
+					panic(err)
+				}
+				n := 0
+				for ; n < len(b) && k < perWriter; n, k = n+1, k+1 {
+					b[n] = point{i, k, "p"}
+				}
+				p.CommitWrite(b, n)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		p.Close()
+	}()
+	seen := map[point]bool{}
+	for {
+		b, err := p.StartReadCtx(context.Background())
+		if err == ErrClosed {
+			break
+		}
+		for _, x := range b {
+			seen[x] = true
+		}
+		p.CommitRead(b)
+	}
+	if len(seen) != writers*perWriter {
+		t.Fatalf("expected %d distinct points, got %d", writers*perWriter, len(seen))
+	}
+}
+
+func TestSlicePumpRecycle(t *testing.T) {
+	p := NewSlicePump[int](4, 1)
+	b, _ := p.StartWrite()
+	p.CommitWrite(b, 0)
+	b, _ = p.StartWrite()
+	if len(b) != 4 {
+		t.Fatalf("expected a full block after an empty commit, got %d", len(b))
+	}
+	p.CommitWrite(b, 1)
+	b, _ = p.StartRead()
+	if len(b) != 1 {
+		t.Fatalf("expected 1 element, got %d", len(b))
+	}
+	p.CommitRead(b)
+	b, _ = p.StartWrite()
+	if len(b) != 4 {
+		t.Fatalf("expected a full block after a read, got %d", len(b))
+	}
+	p.CancelWrite(b)
+	p.Close()
+	if _, ok := p.StartWrite(); ok {
+		t.Fatalf("expected StartWrite to fail after Close")
+	}
+}
+
+func BenchmarkSlicePump(b *testing.B) {
+	p := NewSlicePump[int](blockSize, numBlocks)
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := 0; k < b.N/blockSize; k++ {
+				b, _ := p.StartWrite()
+				for u := range b {
+					b[u]++
+				}
+				p.CommitWrite(b, len(b))
+			}
+		}()
+		wg.Add(1)
+		go func() {
+			sum := 0
+			defer wg.Done()
+			for k := 0; k < b.N/blockSize; k++ {
+				b, _ := p.StartRead()
+				for _, x := range b {
+					sum += x
+				}
+				p.CommitRead(b)
+			}
+		}()
+	}
+	wg.Wait()
+}
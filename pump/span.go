@@ -0,0 +1,56 @@
+package pump
+
+// StartWriteN waits for an interval of at least minSize elements to write to.
+// Intervals larger than the block size are made of adjacent free blocks,
+// on CommitWrite the blocks past the written part go back to the writers right away.
+// Returns false if the pump is closed.
+// Panics if minSize is larger than the whole buffer.
+//
+// While waiting, StartWriteN holds on to the free blocks it gets until enough of them are adjacent,
+// so the other writers wait too. The blocks held by the readers and the other writers
+// have to be committed before a large interval can be carved out of a fragmented buffer.
+func (p Pump) StartWriteN(minSize int) (Interval, bool) {
+	if minSize <= p.blockSize {
+		return p.StartWrite()
+	}
+	numBlocks := cap(p.c.toWrite)
+	n := (minSize + p.blockSize - 1) / p.blockSize
+	if n > numBlocks {
+		panic("pump: StartWriteN size exceeds the buffer")
+	}
+	if !p.s.startWrite() {
+		return Interval{}, false
+	}
+	p.s.spans.Lock()
+	defer p.s.spans.Unlock()
+	held := make([]bool, numBlocks)
+	// release returns all the held blocks outside of [lo, hi) to the writers.
+	release := func(lo, hi int) {
+		for i, h := range held {
+			if h && (i < lo || i >= hi) {
+				p.c.toWrite <- p.span(i, 1)
+			}
+		}
+	}
+	for {
+		select {
+		case b := <-p.c.toWrite:
+			held[b.block] = true
+			lo, hi := b.block, b.block+1
+			for lo > 0 && held[lo-1] {
+				lo--
+			}
+			for hi < numBlocks && held[hi] {
+				hi++
+			}
+			if hi-lo >= n {
+				release(lo, lo+n)
+				return p.started(p.span(lo, n)), true
+			}
+		case <-p.s.done:
+			release(0, 0)
+			p.s.endWrite()
+			return Interval{}, false
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package pump
+
+import "sync/atomic"
+
+// Stats are the lifetime totals of a pump, see Pump.Stats.
+type Stats struct {
+	Written       int64   // Elements committed by the writers.
+	WrittenBlocks int64   // Blocks committed by the writers, a committed interval takes the blocks it spans.
+	ReadBlocks    int64   // Blocks committed by the readers.
+	Fill          float64 // Average fill of the committed blocks: Written / (WrittenBlocks*blockSize), 0 if nothing was committed.
+}
+
+// counters back Stats. They are updated with atomics, once per commit.
+type counters struct {
+	written       atomic.Int64
+	writtenBlocks atomic.Int64
+	readBlocks    atomic.Int64
+}
+
+// count updates the counters with an event.
+func (c *counters) count(op Op, b Interval) {
+	switch op {
+	case WriteCommitted:
+		c.written.Add(int64(b.End - b.Start))
+		c.writtenBlocks.Add(int64(b.blocks))
+	case ReadCommitted:
+		c.readBlocks.Add(int64(b.blocks))
+	}
+}
+
+func (c *counters) reset() {
+	c.written.Store(0)
+	c.writtenBlocks.Store(0)
+	c.readBlocks.Store(0)
+}
+
+// Stats returns the totals since New (or Reset).
+// Cancelled writes and empty commits are not counted.
+// Under concurrent use the counters are read one by one, so they may be off by the commits in flight.
+func (p Pump) Stats() Stats {
+	c := &p.s.counters
+	st := Stats{
+		Written:       c.written.Load(),
+		WrittenBlocks: c.writtenBlocks.Load(),
+		ReadBlocks:    c.readBlocks.Load(),
+	}
+	if st.WrittenBlocks != 0 {
+		st.Fill = float64(st.Written) / float64(st.WrittenBlocks*int64(p.blockSize))
+	}
+	return st
+}
@@ -0,0 +1,73 @@
+package sum
+
+import (
+	"math"
+	"math/rand/v2"
+)
+
+// Magnitudes is a distribution of the magnitudes of the values generated by BenchmarkData.
+type Magnitudes int
+
+const (
+	// NarrowMagnitudes are values in ±[1, 2): they all land in the same bin of a Sum.
+	NarrowMagnitudes Magnitudes = iota
+	// WideMagnitudes are values with the exponents uniform in [-300, 300]:
+	// they spread over ~600 bins of a Sum.
+	WideMagnitudes
+	// CancellingMagnitudes are WideMagnitudes values, each paired with its negation,
+	// with values in ±[2^-60, 2^-59) in between, shuffled:
+	// the exact sum is far below the partial sums, which throws off float64 summation.
+	CancellingMagnitudes
+)
+
+// String implements fmt.Stringer.
+func (m Magnitudes) String() string {
+	switch m {
+	case NarrowMagnitudes:
+		return "narrow"
+	case WideMagnitudes:
+		return "wide"
+	case CancellingMagnitudes:
+		return "cancelling"
+	}
+	return "unknown"
+}
+
+// BenchmarkData returns n values with the magnitudes distributed as m, to track the throughput of the summers on
+// realistic workloads. The values are random, but the same for the same seed.
+// Panics if m is unknown.
+func BenchmarkData(m Magnitudes, n int, seed uint64) []float64 {
+	r := rand.New(rand.NewPCG(seed, seed))
+	xs := make([]float64, n)
+	value := func(minExp, maxExp int) float64 {
+		// A random mantissa with the exponent of 1: 1+r.Float64() may round up to 2.
+		x := math.Ldexp(math.Float64frombits(0x3ff0000000000000|r.Uint64()>>12), minExp+r.IntN(maxExp-minExp+1))
+		if r.IntN(2) == 0 {
+			return -x
+		}
+		return x
+	}
+	switch m {
+	case NarrowMagnitudes:
+		for i := range xs {
+			xs[i] = value(0, 0)
+		}
+	case WideMagnitudes:
+		for i := range xs {
+			xs[i] = value(-300, 300)
+		}
+	case CancellingMagnitudes:
+		i := 0
+		for ; i+3 <= n; i += 3 {
+			x := value(-300, 300)
+			xs[i], xs[i+1], xs[i+2] = x, -x, value(-60, -60)
+		}
+		for ; i < n; i++ {
+			xs[i] = value(-60, -60)
+		}
+		r.Shuffle(n, func(i, j int) { xs[i], xs[j] = xs[j], xs[i] })
+	default:
+		panic("sum: unknown Magnitudes")
+	}
+	return xs
+}
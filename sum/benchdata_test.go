@@ -0,0 +1,81 @@
+package sum
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestBenchmarkData(t *testing.T) {
+	const n = 10000
+	for _, m := range []Magnitudes{NarrowMagnitudes, WideMagnitudes, CancellingMagnitudes} {
+		xs := BenchmarkData(m, n, 1)
+		if len(xs) != n || !slices.Equal(xs, BenchmarkData(m, n, 1)) {
+			t.Fatalf("%v: expected the same %d values for the same seed", m, n)
+		}
+		if slices.Equal(xs, BenchmarkData(m, n, 2)) {
+			t.Fatalf("%v: expected different values for a different seed", m)
+		}
+		exps := map[int]bool{}
+		for _, x := range xs {
+			_, e := math.Frexp(x)
+			exps[e] = true
+			if m == NarrowMagnitudes && !(math.Abs(x) >= 1 && math.Abs(x) < 2) {
+				t.Fatalf("%v: expected values in ±[1, 2), got %v", m, x)
+			}
+		}
+		switch m {
+		case NarrowMagnitudes:
+			if len(exps) != 1 {
+				t.Fatalf("%v: expected a single exponent, got %d", m, len(exps))
+			}
+		case WideMagnitudes:
+			if len(exps) < 500 {
+				t.Fatalf("%v: expected ~600 exponents, got %d", m, len(exps))
+			}
+		}
+	}
+	// The large values cancel out.
+	xs := BenchmarkData(CancellingMagnitudes, n+1, 1)
+	var a, small Sum
+	a.AddAll(xs)
+	for _, x := range xs {
+		if math.Abs(x) < 1 {
+			small.Add(x)
+		}
+	}
+	if a.scaledInt().Cmp(small.scaledInt()) != 0 {
+		t.Fatalf("expected the sum %v to be the sum of the small values %v", &a, &small)
+	}
+	var d Dumb
+	for _, x := range xs {
+		d.Add(x)
+	}
+	if d.Val() == a.Val() {
+		t.Fatalf("expected float64 summation to be off")
+	}
+}
+
+// BenchmarkMagnitudes compares the summers on the values of different magnitudes:
+// narrow values hit a single bin of Sum, wide ones spread over many, which costs cache misses and mispredicted carries.
+func BenchmarkMagnitudes(b *testing.B) {
+	for _, m := range []Magnitudes{NarrowMagnitudes, WideMagnitudes, CancellingMagnitudes} {
+		xs := BenchmarkData(m, 1<<16, 1)
+		b.Run("Sum/"+m.String(), func(b *testing.B) { benchmarkMagnitudes[Sum](b, xs) })
+		b.Run("Kahan/"+m.String(), func(b *testing.B) { benchmarkMagnitudes[Kahan](b, xs) })
+		b.Run("Neumaier/"+m.String(), func(b *testing.B) { benchmarkMagnitudes[Neumaier](b, xs) })
+	}
+}
+
+func benchmarkMagnitudes[T any, P interface {
+	*T
+	Add(float64)
+}](b *testing.B, xs []float64) {
+	b.SetBytes(8 * int64(len(xs)))
+	a := P(new(T))
+	for i := 0; i < b.N; i++ {
+		for _, x := range xs {
+			a.Add(x)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package sum
+
+import "math/big"
+
+// binHeadroom is the number of extra bits in BigSum bins.
+// A bin stays exact for up to 2^binHeadroom summands.
+const binHeadroom = 64
+
+// BigSum sums big.Float numbers up, using exponent binning.
+// Every summand is added to the bin for its exponent, the bins are exact,
+// so cancellation does not lose small summands: BigSum of (x, tiny, -x) is tiny, however large x is.
+// Summands are rounded to the working precision, see SetPrec.
+// The zero value is ready to use, its working precision is the precision of the first non-zero summand.
+// Like big.Float, panics if both +Inf and -Inf are added.
+type BigSum struct {
+	prec   uint
+	nonneg []*big.Float // bin == exponent
+	neg    []*big.Float // bin == -exponent+1
+	inf    big.Float    // Sum of infs, zero if there were none.
+}
+
+// SetPrec sets the working precision to prec bits and returns b.
+// Should be called before adding values, otherwise the accumulated bins are rounded to the new precision.
+// If prec == 0, the precision of the next non-zero summand is used.
+func (b *BigSum) SetPrec(prec uint) *BigSum {
+	b.prec = prec
+	if prec != 0 {
+		for _, x := range b.nonneg {
+			x.SetPrec(prec + binHeadroom)
+		}
+		for _, x := range b.neg {
+			x.SetPrec(prec + binHeadroom)
+		}
+	}
+	return b
+}
+
+// Prec returns the working precision in bits.
+func (b *BigSum) Prec() uint {
+	return b.prec
+}
+
+// Add v to the sum.
+// v is rounded to the working precision (to nearest even).
+func (b *BigSum) Add(v *big.Float) {
+	if v.Sign() == 0 {
+		return
+	}
+	if v.IsInf() {
+		b.inf.Add(&b.inf, v) // Panics on (+Inf) + (-Inf).
+		return
+	}
+	if b.prec == 0 {
+		b.SetPrec(v.Prec())
+	}
+	if v.Prec() > b.prec {
+		v = new(big.Float).SetPrec(b.prec).Set(v)
+	}
+	exp := v.MantExp(nil)
+	p := &b.nonneg
+	bin := exp
+	if exp < 0 {
+		p = &b.neg
+		bin = -bin + 1
+	}
+	for len(*p) < bin+1 {
+		*p = append(*p, new(big.Float).SetPrec(b.prec+binHeadroom))
+	}
+	// Every summand in the bin is a multiple of 2^(exp-prec) below 2^exp,
+	// so the sum of up to 2^binHeadroom of them fits into the bin.
+	a := *p
+	a[bin].Add(a[bin], v)
+}
+
+// BigVal returns the current sum, rounded to the working precision.
+// The bins are exact, the only error comes from adding them up (compensated) at the end.
+func (b *BigSum) BigVal() *big.Float {
+	if b.inf.IsInf() {
+		return new(big.Float).Set(&b.inf)
+	}
+	var sum bigKahan
+	sum.s.SetPrec(b.prec + binHeadroom)
+	sum.c.SetPrec(b.prec + binHeadroom)
+	for i := range b.nonneg {
+		sum.Add(b.nonneg[len(b.nonneg)-i-1])
+	}
+	for _, x := range b.neg {
+		sum.Add(x)
+	}
+	return new(big.Float).SetPrec(b.prec).Set(sum.BigVal())
+}
+
+// bigKahan: kahan using big.Float.
+type bigKahan struct {
+	s, c big.Float
+}
+
+// Add v to the sum.
+func (k *bigKahan) Add(v *big.Float) {
+	y := &big.Float{}
+	y.Sub(v, &k.c)
+	t := &big.Float{}
+	t.Add(&k.s, y)
+	k.c.Sub(t, &k.s)
+	k.c.Sub(&k.c, y)
+	k.s = *t
+}
+
+// Val return the current sum.
+func (k bigKahan) BigVal() *big.Float {
+	return &k.s
+}
@@ -0,0 +1,79 @@
+package sum
+
+import (
+	"math"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+func TestBigSumResidual(t *testing.T) {
+	const prec = 200
+	r := rand.New(rand.NewSource(29))
+	one := new(big.Float).SetPrec(prec).SetInt64(1)
+	residual := new(big.Float).SetPrec(prec).SetMantExp(one, -150)
+	var xs []*big.Float
+	for i := 0; i < 1000; i++ {
+		x := new(big.Float).SetPrec(prec).SetFloat64(r.Float64())
+		x.Add(x, new(big.Float).SetMantExp(big.NewFloat(r.Float64()), -60))
+		x.Add(x, new(big.Float).SetMantExp(big.NewFloat(r.Float64()), -120))
+		x.SetMantExp(x, r.Intn(200))
+		xs = append(xs, x, new(big.Float).Neg(x))
+	}
+	r.Shuffle(len(xs), func(i, j int) { xs[i], xs[j] = xs[j], xs[i] })
+	xs = append(xs[:len(xs)/2], append([]*big.Float{residual}, xs[len(xs)/2:]...)...)
+
+	var a BigSum
+	naive := new(big.Float).SetPrec(prec)
+	for _, x := range xs {
+		a.Add(x)
+		naive.Add(naive, x)
+	}
+	if a.Prec() != prec {
+		t.Fatalf("expected precision %d, got %d", prec, a.Prec())
+	}
+	if got := a.BigVal(); got.Cmp(residual) != 0 {
+		t.Fatalf("expected %s, got %s", residual.String(), got.String())
+	}
+	if naive.Cmp(residual) == 0 {
+		t.Fatalf("expected the naive sum to lose the residual")
+	}
+}
+
+func TestBigSumPrec(t *testing.T) {
+	// 1 + 2^-100 does not fit into 64 bits, but fits into 128.
+	v := new(big.Float).SetPrec(128).SetInt64(1)
+	v.Add(v, new(big.Float).SetMantExp(big.NewFloat(1), -100))
+	for _, tc := range []struct {
+		prec uint
+		want *big.Float
+	}{
+		{64, big.NewFloat(3)},
+		{128, new(big.Float).Add(v, new(big.Float).Add(v, big.NewFloat(1)))},
+	} {
+		var a BigSum
+		a.SetPrec(tc.prec)
+		a.Add(v)
+		a.Add(v)
+		a.Add(big.NewFloat(1))
+		if got := a.BigVal(); got.Cmp(tc.want) != 0 {
+			t.Fatalf("prec %d: expected %s, got %s", tc.prec, tc.want.Text('p', 0), got.Text('p', 0))
+		}
+	}
+}
+
+func TestBigSumInfs(t *testing.T) {
+	var a BigSum
+	a.Add(big.NewFloat(1))
+	a.Add(big.NewFloat(math.Inf(1)))
+	a.Add(big.NewFloat(math.Inf(1)))
+	if got := a.BigVal(); !got.IsInf() || got.Sign() != 1 {
+		t.Fatalf("expected +Inf, got %s", got.String())
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected a panic")
+		}
+	}()
+	a.Add(big.NewFloat(math.Inf(-1)))
+}
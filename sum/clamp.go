@@ -0,0 +1,61 @@
+package sum
+
+import (
+	"math"
+	"math/big"
+)
+
+// ClampSum is a Sum that does not follow IEEE 754, on purpose: one bad sample should not ruin the whole sum,
+// e.g. in telemetry aggregation.
+// Infs and NaNs are not added, only counted, see Skipped.
+// The sum is exact like Sum, Val saturates to ±math.MaxFloat64 instead of overflowing to ±Inf.
+// So Val is always finite. Size is ~24Kb.
+type ClampSum struct {
+	s       Sum
+	skipped int
+}
+
+// Add a float64 value to the sum, skipping infs and NaNs.
+func (c *ClampSum) Add(v float64) {
+	if math.IsInf(v, 0) || math.IsNaN(v) {
+		c.skipped++
+		return
+	}
+	c.s.AddTracked(v)
+}
+
+// Val returns the current sum as float64, clamped to [-math.MaxFloat64, math.MaxFloat64].
+func (c *ClampSum) Val() float64 {
+	v := c.s.Val()
+	if math.IsInf(v, 0) {
+		return math.Copysign(math.MaxFloat64, v)
+	}
+	return v
+}
+
+// BigVal returns the current sum, see BigSummer. It is exact, and never clamped or NaN.
+func (c *ClampSum) BigVal() (*big.Float, bool) {
+	return c.s.BigVal()
+}
+
+// Count returns the number of summands that were added, not counting the skipped ones.
+func (c *ClampSum) Count() int {
+	return c.s.Count()
+}
+
+// Skipped returns the number of infs and NaNs that were skipped.
+func (c *ClampSum) Skipped() int {
+	return c.skipped
+}
+
+// Merge adds all the values accumulated in b to c, see Sum.Merge.
+func (c *ClampSum) Merge(b *ClampSum) {
+	c.s.Merge(&b.s)
+	c.skipped += b.skipped
+}
+
+// Reset sets the sum to zero and forgets the skipped values.
+func (c *ClampSum) Reset() {
+	c.s.Reset()
+	c.skipped = 0
+}
@@ -0,0 +1,43 @@
+package sum
+
+import (
+	"math"
+	"testing"
+)
+
+func TestClampSum(t *testing.T) {
+	var c ClampSum
+	for _, x := range []float64{0.1, math.NaN(), 0.2, math.Inf(1), math.Inf(-1), 0.3} {
+		c.Add(x)
+	}
+	var want Sum
+	want.AddAll([]float64{0.1, 0.2, 0.3})
+	if c.Val() != want.Val() || c.Count() != 3 || c.Skipped() != 3 {
+		t.Fatalf("expected %g from 3 summands, 3 skipped, got %g, %d, %d", want.Val(), c.Val(), c.Count(), c.Skipped())
+	}
+
+	// Overflow clamps.
+	c.Reset()
+	c.Add(math.MaxFloat64)
+	c.Add(math.MaxFloat64)
+	if c.Val() != math.MaxFloat64 {
+		t.Fatalf("expected %g, got %g", math.MaxFloat64, c.Val())
+	}
+	// The exact sum is kept: it comes back into range.
+	c.Add(-math.MaxFloat64)
+	if c.Val() != math.MaxFloat64 || c.Skipped() != 0 {
+		t.Fatalf("expected %g, got %g", math.MaxFloat64, c.Val())
+	}
+	var d ClampSum
+	d.Add(-math.MaxFloat64)
+	d.Add(-math.MaxFloat64)
+	d.Add(-math.MaxFloat64)
+	d.Add(math.NaN())
+	c.Merge(&d)
+	if c.Val() != -math.MaxFloat64 || c.Count() != 6 || c.Skipped() != 1 {
+		t.Fatalf("expected %g from 6 summands, 1 skipped, got %g, %d, %d", -math.MaxFloat64, c.Val(), c.Count(), c.Skipped())
+	}
+	if v, nan := c.BigVal(); nan || v.Sign() >= 0 || v.IsInf() {
+		t.Fatalf("expected the exact negative sum, got %v, %t", v, nan)
+	}
+}
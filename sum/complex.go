@@ -0,0 +1,51 @@
+package sum
+
+import (
+	"math"
+	"math/big"
+)
+
+// Complex sums complex128 numbers up, accumulating the real and the imaginary parts with Sum.
+// The parts are independent: a NaN or an inf in one of them does not affect the other one.
+// Size is ~48Kb.
+type Complex struct {
+	re, im Sum
+}
+
+// Add a complex128 value to the sum.
+func (c *Complex) Add(v complex128) {
+	c.re.Add(real(v))
+	c.im.Add(imag(v))
+}
+
+// AddRealImag adds complex(re, im) to the sum.
+func (c *Complex) AddRealImag(re, im float64) {
+	c.re.Add(re)
+	c.im.Add(im)
+}
+
+// Val returns the current sum as complex128.
+func (c *Complex) Val() complex128 {
+	return complex(c.re.Val(), c.im.Val())
+}
+
+// Abs returns the absolute value of the sum.
+// It is computed from the exact parts, so it is accurate even if the parts
+// would lose precision when rounded to float64.
+// Like math.Hypot, returns +Inf if either part is infinite, otherwise NaN if either part is NaN.
+func (c *Complex) Abs() float64 {
+	switch {
+	case c.re.IsInf(0) || c.im.IsInf(0):
+		return math.Inf(1)
+	case c.re.IsNaN() || c.im.IsNaN():
+		return math.NaN()
+	}
+	// The sum of squares is exact, it is rounded twice: to 128 bits by Sqrt and to float64.
+	re, im := c.re.scaledInt(), c.im.scaledInt()
+	re.Mul(re, re)
+	re.Add(re, im.Mul(im, im))
+	sq := new(big.Float).SetInt(re)
+	sq.SetMantExp(sq, 2*minExp)
+	f, _ := new(big.Float).SetPrec(128).Sqrt(sq).Float64()
+	return f
+}
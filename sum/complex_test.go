@@ -0,0 +1,75 @@
+package sum
+
+import (
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+)
+
+func TestComplexCancellation(t *testing.T) {
+	r := rand.New(rand.NewSource(27))
+	xs := randomFloats(r, 1000)
+	ys := randomFloats(r, 1000)
+	var c Complex
+	for i := range xs {
+		c.Add(complex(xs[i], ys[i]))
+	}
+	c.AddRealImag(1, -2)
+	for i := range xs {
+		c.AddRealImag(-xs[i], -ys[i])
+	}
+	if got := c.Val(); got != complex(1, -2) {
+		t.Fatalf("expected %g, got %g", complex(1, -2), got)
+	}
+	c.Add(complex(-1, 2))
+	if got := c.Val(); real(got) != 0 || imag(got) != 0 {
+		t.Fatalf("expected 0, got %g", got)
+	}
+	if c.Abs() != 0 {
+		t.Fatalf("expected 0, got %g", c.Abs())
+	}
+}
+
+func TestComplexAbs(t *testing.T) {
+	for _, tc := range []struct {
+		in   []complex128
+		want float64
+	}{
+		{nil, 0},
+		{[]complex128{3, 4i}, 5},
+		{[]complex128{complex(-3, 4)}, 5},
+		{[]complex128{3e300, 4e300i}, 5e300},
+		{[]complex128{3e-320, 4e-320i}, 5e-320},
+		// The real part is 1 + 2^-53 - 2^-70, it rounds to 1 as float64 and hypot(1, 2^-30) rounds to 1,
+		// but the exact absolute value is above the tie.
+		{[]complex128{1, 0x1p-53, -0x1p-70, 0x1p-30i}, 1 + 0x1p-52},
+		{[]complex128{1e308, 1e308, 1e308i}, math.Inf(1)},
+		{[]complex128{complex(math.Inf(-1), math.NaN())}, math.Inf(1)},
+		{[]complex128{complex(math.NaN(), math.Inf(1))}, math.Inf(1)},
+		{[]complex128{complex(math.NaN(), 1)}, math.NaN()},
+		{[]complex128{complex(math.Inf(1), 1), complex(math.Inf(-1), 1)}, math.NaN()},
+	} {
+		var c Complex
+		for _, x := range tc.in {
+			c.Add(x)
+		}
+		got := c.Abs()
+		if got != tc.want && !(math.IsNaN(got) && math.IsNaN(tc.want)) {
+			t.Fatalf("%v: expected %g, got %g", tc.in, tc.want, got)
+		}
+		if v := cmplx.Abs(c.Val()); !math.IsInf(v, 0) && !math.IsNaN(v) && math.Abs(v-got) > 2*math.Abs(v)*0x1p-52 {
+			t.Fatalf("%v: expected %g to be close to %g", tc.in, got, v)
+		}
+	}
+}
+
+func TestComplexSpecial(t *testing.T) {
+	var c Complex
+	c.Add(complex(math.Inf(1), 1))
+	c.Add(complex(2, math.NaN()))
+	got := c.Val()
+	if !math.IsInf(real(got), 1) || !math.IsNaN(imag(got)) {
+		t.Fatalf("expected (+Inf+NaNi), got %g", got)
+	}
+}
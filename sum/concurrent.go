@@ -0,0 +1,89 @@
+package sum
+
+import (
+	"math/big"
+	"math/rand/v2"
+	"runtime"
+	"sync"
+)
+
+// ConcurrentSum is a Sum safe for concurrent use.
+// It keeps several shards, each a Sum with its own lock, so concurrent Adds rarely contend.
+// Val and BigVal merge all the shards. They are safe to call concurrently with Add,
+// but see a consistent snapshot only if there are no Adds in flight.
+// The zero value is ready to use, with runtime.GOMAXPROCS(0) shards, see NewConcurrentSum.
+// Size is ~24Kb per shard.
+type ConcurrentSum struct {
+	once   sync.Once
+	shards []shard
+}
+
+type shard struct {
+	mu sync.Mutex
+	s  Sum
+}
+
+// NewConcurrentSum creates a new ConcurrentSum with n shards.
+// If n <= 0, uses runtime.GOMAXPROCS(0) shards.
+func NewConcurrentSum(n int) *ConcurrentSum {
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	return &ConcurrentSum{shards: make([]shard, n)}
+}
+
+// Add a float64 value to the sum.
+func (c *ConcurrentSum) Add(v float64) {
+	shards := c.init()
+	// Start at a random shard, take the first one that is not busy.
+	i := rand.IntN(len(shards))
+	for range shards {
+		sh := &shards[i]
+		if sh.mu.TryLock() {
+			sh.s.Add(v)
+			sh.mu.Unlock()
+			return
+		}
+		i++
+		if i == len(shards) {
+			i = 0
+		}
+	}
+	sh := &shards[i]
+	sh.mu.Lock()
+	sh.s.Add(v)
+	sh.mu.Unlock()
+}
+
+// Val returns the current sum as float64, see Sum.Val.
+func (c *ConcurrentSum) Val() float64 {
+	return c.merged().Val()
+}
+
+// BigVal returns the current sum as (sum *big.Float, isNan bool) pair, see Sum.BigVal.
+func (c *ConcurrentSum) BigVal() (*big.Float, bool) {
+	return c.merged().BigVal()
+}
+
+// init creates the shards of a zero ConcurrentSum and returns the shards.
+func (c *ConcurrentSum) init() []shard {
+	c.once.Do(func() {
+		if c.shards == nil {
+			c.shards = make([]shard, runtime.GOMAXPROCS(0))
+		}
+	})
+	return c.shards
+}
+
+// merged returns all the shards merged into one Sum.
+func (c *ConcurrentSum) merged() *Sum {
+	a := &Sum{}
+	shards := c.init()
+	for i := range shards {
+		sh := &shards[i]
+		sh.mu.Lock()
+		a.Merge(&sh.s)
+		sh.mu.Unlock()
+	}
+	return a
+}
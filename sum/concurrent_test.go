@@ -0,0 +1,83 @@
+package sum
+
+import (
+	"math"
+	"math/big"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentSum(t *testing.T) {
+	const goroutines = 32
+	const adds = 10000
+	c := NewConcurrentSum(0)
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < adds; i++ {
+				c.Add(1)
+				c.Add(1e100)
+				c.Add(-1e100)
+				if i%1000 == 0 {
+					c.Val() // Concurrent reads are fine.
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if got := c.Val(); got != goroutines*adds {
+		t.Fatalf("expected %d, got %g", goroutines*adds, got)
+	}
+	if v, nan := c.BigVal(); nan || v.Cmp(big.NewFloat(goroutines*adds)) != 0 {
+		t.Fatalf("expected %d, got %v", goroutines*adds, v)
+	}
+}
+
+func TestConcurrentSumInfs(t *testing.T) {
+	c := NewConcurrentSum(4)
+	for i := 0; i < 100; i++ {
+		c.Add(math.Inf(1))
+	}
+	if !math.IsInf(c.Val(), 1) {
+		t.Fatalf("expected +Inf, got %g", c.Val())
+	}
+	c.Add(math.Inf(-1))
+	if !math.IsNaN(c.Val()) {
+		t.Fatalf("expected NaN, got %g", c.Val())
+	}
+}
+
+func TestConcurrentSumZero(t *testing.T) {
+	var c ConcurrentSum
+	if c.Val() != 0 {
+		t.Fatalf("expected 0, got %g", c.Val())
+	}
+	var d ConcurrentSum
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				d.Add(0.5)
+			}
+		}()
+	}
+	wg.Wait()
+	if d.Val() != 4000 || len(d.shards) != runtime.GOMAXPROCS(0) {
+		t.Fatalf("expected 4000 in %d shards, got %g in %d", runtime.GOMAXPROCS(0), d.Val(), len(d.shards))
+	}
+}
+
+func BenchmarkConcurrentSum(b *testing.B) {
+	b.SetBytes(8)
+	c := NewConcurrentSum(0)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c.Add(-1e-10)
+		}
+	})
+}
@@ -0,0 +1,119 @@
+package sum
+
+import (
+	"math"
+	"math/big"
+)
+
+// Covariance computes the means, covariance and correlation of pairs of float64 numbers.
+// Like Variance, keeps exact sums of the values, of their squares and of their products,
+// so it does not suffer from cancellation even if the means are large relative to the spread,
+// where the textbook E[xy]-E[x]E[y] falls apart.
+// The products are exact as long as they do not overflow or underflow float64, see AddWeighted.
+// Products that overflow (|x*y| > ~1.8e308) make the covariance ±Inf (NaN if they overflow both ways),
+// and the correlation NaN.
+// A pair with an inf or a NaN makes the covariance and the correlation NaN.
+type Covariance struct {
+	x, y       Mean
+	xy, xx, yy Sum
+	n          int // Number of finite pairs.
+}
+
+// Add a pair of float64 values.
+func (c *Covariance) Add(x, y float64) {
+	c.x.Add(x)
+	c.y.Add(y)
+	if math.IsInf(x, 0) || math.IsNaN(x) || math.IsInf(y, 0) || math.IsNaN(y) {
+		return
+	}
+	c.xy.AddWeighted(x, y)
+	c.xx.AddSquared(x)
+	c.yy.AddSquared(y)
+	c.n++
+}
+
+// Count returns the number of pairs of finite values added.
+func (c *Covariance) Count() int {
+	return c.n
+}
+
+// MeanX returns the mean of the first values, see Mean.Val.
+func (c *Covariance) MeanX() float64 {
+	return c.x.Val()
+}
+
+// MeanY returns the mean of the second values, see Mean.Val.
+func (c *Covariance) MeanY() float64 {
+	return c.y.Val()
+}
+
+// Covariance returns the population covariance.
+// Returns NaN if no pairs were added.
+func (c *Covariance) Covariance() float64 {
+	return c.moment(0)
+}
+
+// SampleCovariance returns the sample (unbiased) covariance.
+// Returns NaN if less than two pairs were added.
+func (c *Covariance) SampleCovariance() float64 {
+	return c.moment(1)
+}
+
+// Correlation returns the Pearson correlation coefficient.
+// Returns NaN if less than two pairs were added, or if either of the values does not vary.
+func (c *Covariance) Correlation() float64 {
+	if !c.finite() || c.n < 2 {
+		return math.NaN()
+	}
+	for _, p := range []*Sum{&c.xy, &c.xx, &c.yy} {
+		if _, over := overflowed(p); over {
+			return math.NaN()
+		}
+	}
+	sx, sy := &c.x.s, &c.y.s
+	vx, vy := comoment(c.n, sx, sx, &c.xx), comoment(c.n, sy, sy, &c.yy)
+	if vx.Sign() == 0 || vy.Sign() == 0 {
+		return math.NaN()
+	}
+	// The scale and the n*n in the denominators cancel out.
+	const prec = 128
+	d := new(big.Float).SetPrec(prec).SetInt(vx)
+	d.Mul(d, new(big.Float).SetInt(vy))
+	d.Sqrt(d)
+	r := new(big.Float).SetPrec(prec).SetInt(comoment(c.n, sx, sy, &c.xy))
+	f, _ := r.Quo(r, d).Float64()
+	return math.Max(-1, math.Min(1, f))
+}
+
+// overflowed returns the value of a sum of products that overflowed float64: ±Inf, or NaN if they overflowed both ways.
+// Returns false if none of the products overflowed.
+func overflowed(p *Sum) (float64, bool) {
+	switch {
+	case p.nans != 0 || p.plusInfs != 0 && p.minusInfs != 0:
+		return math.NaN(), true
+	case p.plusInfs != 0:
+		return math.Inf(1), true
+	case p.minusInfs != 0:
+		return math.Inf(-1), true
+	}
+	return 0, false
+}
+
+// finite reports whether all the values added are finite.
+func (c *Covariance) finite() bool {
+	sx, sy := &c.x.s, &c.y.s
+	return sx.nans == 0 && sx.plusInfs == 0 && sx.minusInfs == 0 &&
+		sy.nans == 0 && sy.plusInfs == 0 && sy.minusInfs == 0
+}
+
+// moment returns (n*sum(xy) - sum(x)*sum(y)) / (n*(n-ddof)).
+func (c *Covariance) moment(ddof int) float64 {
+	if !c.finite() || c.n-ddof <= 0 {
+		return math.NaN()
+	}
+	if f, over := overflowed(&c.xy); over {
+		// The bins do not have the products that overflowed.
+		return f
+	}
+	return scaledQuo(comoment(c.n, &c.x.s, &c.y.s, &c.xy), c.n, c.n-ddof)
+}
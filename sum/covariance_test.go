@@ -0,0 +1,126 @@
+package sum
+
+import (
+	"math"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+func TestCovarianceLargeOffset(t *testing.T) {
+	r := rand.New(rand.NewSource(7))
+	const n = 100000
+	xs, ys := make([]float64, n), make([]float64, n)
+	var c Covariance
+	mx, my := new(big.Float).SetPrec(4096), new(big.Float).SetPrec(4096)
+	for i := range xs {
+		d := (r.Float64()*2 - 1) * 1e-3
+		xs[i] = 1e9 + d
+		ys[i] = -3e8 + 2*d + (r.Float64()*2-1)*1e-3
+		c.Add(xs[i], ys[i])
+		mx.Add(mx, big.NewFloat(xs[i]))
+		my.Add(my, big.NewFloat(ys[i]))
+	}
+	mx.Quo(mx, big.NewFloat(n))
+	my.Quo(my, big.NewFloat(n))
+	cxy, cxx, cyy := new(big.Float).SetPrec(4096), new(big.Float).SetPrec(4096), new(big.Float).SetPrec(4096)
+	for i := range xs {
+		dx := new(big.Float).SetPrec(4096).Sub(big.NewFloat(xs[i]), mx)
+		dy := new(big.Float).SetPrec(4096).Sub(big.NewFloat(ys[i]), my)
+		cxy.Add(cxy, new(big.Float).Mul(dx, dy))
+		cxx.Add(cxx, new(big.Float).Mul(dx, dx))
+		cyy.Add(cyy, new(big.Float).Mul(dy, dy))
+	}
+	want, _ := new(big.Float).Quo(cxy, big.NewFloat(n)).Float64()
+	wantSample, _ := new(big.Float).Quo(cxy, big.NewFloat(n-1)).Float64()
+	cxx.Mul(cxx, cyy)
+	wantCorr, _ := cxy.Quo(cxy, cxx.Sqrt(cxx)).Float64()
+	wantX, _ := mx.Float64()
+	wantY, _ := my.Float64()
+
+	if c.MeanX() != wantX || c.MeanY() != wantY {
+		t.Fatalf("expected means %g and %g, got %g and %g", wantX, wantY, c.MeanX(), c.MeanY())
+	}
+	if got := c.Covariance(); math.Abs(got-want) > want*1e-15 {
+		t.Fatalf("expected covariance %g, got %g", want, got)
+	}
+	if got := c.SampleCovariance(); math.Abs(got-wantSample) > wantSample*1e-15 {
+		t.Fatalf("expected sample covariance %g, got %g", wantSample, got)
+	}
+	if got := c.Correlation(); math.Abs(got-wantCorr) > 1e-15 {
+		t.Fatalf("expected correlation %g, got %g", wantCorr, got)
+	}
+
+	// The textbook formula falls apart.
+	var sx, sy, sxy Dumb
+	for i, x := range xs {
+		sx.Add(x)
+		sy.Add(ys[i])
+		sxy.Add(x * ys[i])
+	}
+	naive := sxy.Val()/n - (sx.Val()/n)*(sy.Val()/n)
+	if math.Abs(naive-want) < math.Abs(want) {
+		t.Fatalf("expected naive covariance %g to be far from %g", naive, want)
+	}
+}
+
+func TestCovarianceEdgeCases(t *testing.T) {
+	var c Covariance
+	if !math.IsNaN(c.Covariance()) || !math.IsNaN(c.SampleCovariance()) || !math.IsNaN(c.Correlation()) ||
+		!math.IsNaN(c.MeanX()) || !math.IsNaN(c.MeanY()) {
+		t.Fatalf("expected nans for empty Covariance")
+	}
+	c.Add(3, 4)
+	if c.Covariance() != 0 {
+		t.Fatalf("expected zero covariance of a single pair, got %g", c.Covariance())
+	}
+	if !math.IsNaN(c.SampleCovariance()) || !math.IsNaN(c.Correlation()) {
+		t.Fatalf("expected nan sample covariance and correlation of a single pair, got %g and %g", c.SampleCovariance(), c.Correlation())
+	}
+	c.Add(5, 0)
+	if c.Covariance() != -2 || c.SampleCovariance() != -4 || c.Correlation() != -1 {
+		t.Fatalf("expected covariance -2, sample covariance -4 and correlation -1, got %g, %g and %g",
+			c.Covariance(), c.SampleCovariance(), c.Correlation())
+	}
+	var flat Covariance
+	flat.Add(1, 2)
+	flat.Add(1, 3)
+	if flat.Covariance() != 0 || !math.IsNaN(flat.Correlation()) {
+		t.Fatalf("expected zero covariance and nan correlation, got %g and %g", flat.Covariance(), flat.Correlation())
+	}
+	c.Add(1, math.Inf(1))
+	if c.Count() != 2 || !math.IsNaN(c.Covariance()) || !math.IsNaN(c.Correlation()) {
+		t.Fatalf("expected 2 pairs and nans, got %d, %g and %g", c.Count(), c.Covariance(), c.Correlation())
+	}
+}
+
+func TestCovarianceOverflow(t *testing.T) {
+	for _, tc := range []struct {
+		xs, ys []float64
+		want   float64
+	}{
+		{[]float64{1e200, 2e200, 3e200}, []float64{1e200, 2e200, 3e200}, math.Inf(1)},
+		{[]float64{1e200, 2e200, 3e200}, []float64{-1e200, -2e200, -3e200}, math.Inf(-1)},
+		{[]float64{1e200, -1e200}, []float64{1e200, 1e200}, math.NaN()},
+	} {
+		var c Covariance
+		for i, x := range tc.xs {
+			c.Add(x, tc.ys[i])
+		}
+		got := c.Covariance()
+		if math.IsNaN(tc.want) != math.IsNaN(got) || !math.IsNaN(got) && got != tc.want {
+			t.Fatalf("%v, %v: expected %g, got %g", tc.xs, tc.ys, tc.want, got)
+		}
+		if !math.IsNaN(c.Correlation()) {
+			t.Fatalf("%v, %v: expected nan correlation, got %g", tc.xs, tc.ys, c.Correlation())
+		}
+	}
+	// Below the overflow the products are exact.
+	var c Covariance
+	for _, x := range []float64{1e150, 2e150, 3e150} {
+		c.Add(x, -x)
+	}
+	if want := -2e300 / 3; math.Abs(c.Covariance()-want) > -want*1e-15 || c.Correlation() != -1 {
+		t.Fatalf("expected %g and correlation -1, got %g and %g", want, c.Covariance(), c.Correlation())
+	}
+}
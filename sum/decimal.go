@@ -0,0 +1,67 @@
+package sum
+
+import (
+	"errors"
+	"math/big"
+)
+
+var errDecimal = errors.New("sum: decimal summands can not be encoded, see AddDecimal")
+
+// AddDecimal adds units*10^-scale to the sum exactly, e.g. AddDecimal(-1999, 2) adds -19.99.
+// Decimal fractions such as currency amounts are not float64 numbers: Add(0.10) adds the nearest binary
+// approximation, while AddDecimal(10, 2) adds exactly 10*10^-2, so 0.10 + 0.20 == 0.30 exactly.
+// A negative scale multiplies by a power of 10: AddDecimal(3, -6) adds 3,000,000.
+// Counts as a single summand, min, max and the condition see it rounded to float64.
+//
+// The decimal summands are kept apart from the bins, in a big.Int allocated on the first call,
+// so a Sum that does not use AddDecimal does not pay for it. The units are kept at the largest scale seen,
+// ~3.3 bits per digit of it, so the scale should be reasonable.
+// Val and ExactRat include the decimal summands, so do Count, Merge, Equal, Clone and Reset.
+// The other methods work on the bins and see only the binary summands;
+// MarshalBinary and MarshalText return an error instead of dropping the decimal ones.
+func (a *Sum) AddDecimal(units int64, scale int) {
+	u := big.NewInt(units)
+	if scale < 0 {
+		u.Mul(u, pow10(-scale))
+		scale = 0
+	}
+	if a.TrackMinMax || a.TrackCondition {
+		f, _ := new(big.Rat).SetFrac(u, pow10(scale)).Float64()
+		a.track(f)
+	}
+	a.count++
+	a.addDecimal(u, scale)
+}
+
+// addDecimal adds u*10^-scale to the decimal summands, scale >= 0. Modifies u.
+func (a *Sum) addDecimal(u *big.Int, scale int) {
+	if a.dec == nil {
+		a.dec = new(big.Int)
+	}
+	switch {
+	case scale > a.decScale:
+		a.dec.Mul(a.dec, pow10(scale-a.decScale))
+		a.decScale = scale
+	case scale < a.decScale:
+		u.Mul(u, pow10(a.decScale-scale))
+	}
+	a.dec.Add(a.dec, u)
+}
+
+// pow10 returns 10^n.
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// hasDecimal reports whether the sum has non-zero decimal summands.
+func (a *Sum) hasDecimal() bool {
+	return a.dec != nil && a.dec.Sign() != 0
+}
+
+// decimal returns the decimal summands as big.Rat.
+func (a *Sum) decimal() *big.Rat {
+	if a.dec == nil {
+		return new(big.Rat)
+	}
+	return new(big.Rat).SetFrac(new(big.Int).Set(a.dec), pow10(a.decScale))
+}
@@ -0,0 +1,109 @@
+package sum
+
+import (
+	"math"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+func TestAddDecimal(t *testing.T) {
+	var a Sum
+	a.AddDecimal(10, 2)
+	a.AddDecimal(20, 2)
+	if r, _ := a.ExactRat(); r.Cmp(big.NewRat(3, 10)) != 0 || a.Val() != 0.3 {
+		t.Fatalf("expected exactly 0.3, got %v", r)
+	}
+
+	r := rand.New(rand.NewSource(600))
+	a.Reset()
+	var b Sum
+	want := new(big.Rat)
+	for i := 0; i < 100000; i++ {
+		cents := r.Int63n(2000000) - 1000000
+		a.AddDecimal(cents, 2)
+		b.AddTracked(float64(cents) / 100)
+		want.Add(want, big.NewRat(cents, 100))
+	}
+	got, _ := a.ExactRat()
+	if got.Cmp(want) != 0 {
+		t.Fatalf("expected %s, got %s", want.FloatString(2), got.FloatString(2))
+	}
+	if binary, _ := b.ExactRat(); binary.Cmp(want) == 0 {
+		t.Fatalf("expected the float64 amounts to be off")
+	}
+	if w, _ := want.Float64(); a.Val() != w || a.Count() != 100000 {
+		t.Fatalf("expected %g of 100000 summands, got %g of %d", w, a.Val(), a.Count())
+	}
+}
+
+func TestAddDecimalMixed(t *testing.T) {
+	a := Sum{TrackMinMax: true}
+	var b Sum
+	a.AddDecimal(1, 3) // 0.001
+	a.AddDecimal(5, 0) // 5
+	a.AddTracked(0.5)  // Binary.
+	if a.Min() != 0.001 || a.Max() != 5 {
+		t.Fatalf("expected min 0.001 and max 5, got %g and %g", a.Min(), a.Max())
+	}
+	b.AddDecimal(-25, 1) // -2.5
+	b.AddDecimal(2, -3)  // 2000
+	b.AddDecimal(7, 5)   // 0.00007
+	a.Merge(&b)
+	want := big.NewRat(200300107, 100000) // 0.001 + 5 + 0.5 - 2.5 + 2000 + 0.00007.
+	if got, nan := a.ExactRat(); nan || got.Cmp(want) != 0 {
+		t.Fatalf("expected %s, got %v", want.FloatString(5), got)
+	}
+	if w, _ := want.Float64(); a.Val() != w || a.Count() != 6 {
+		t.Fatalf("expected %g of 6 summands, got %g of %d", w, a.Val(), a.Count())
+	}
+	if _, err := a.MarshalBinary(); err == nil {
+		t.Fatalf("expected MarshalBinary to refuse the decimal summands")
+	}
+	if _, err := a.MarshalText(); err == nil {
+		t.Fatalf("expected MarshalText to refuse the decimal summands")
+	}
+	a.Add(math.Inf(-1))
+	if r, nan := a.ExactRat(); r != nil || nan || !math.IsInf(a.Val(), -1) {
+		t.Fatalf("expected -Inf, got %g", a.Val())
+	}
+	a.Add(math.NaN())
+	if _, nan := a.ExactRat(); !nan || !math.IsNaN(a.Val()) {
+		t.Fatalf("expected NaN, got %g", a.Val())
+	}
+	a.Reset()
+	if r, _ := a.ExactRat(); r.Sign() != 0 || a.Count() != 0 || a.hasDecimal() {
+		t.Fatalf("expected zero, got %v", r)
+	}
+}
+
+func TestAddDecimalCloneEqual(t *testing.T) {
+	var a Sum
+	a.AddDecimal(199, 2)
+	a.AddTracked(0.5)
+	c := a.Clone()
+	if !c.Equal(&a) {
+		t.Fatalf("expected the clone to be equal")
+	}
+	c.AddDecimal(1, 2)
+	c.AddDecimal(1, 3)
+	if r, _ := a.ExactRat(); r.Cmp(big.NewRat(249, 100)) != 0 || a.Count() != 2 {
+		t.Fatalf("expected the original to stay 2.49 of 2 summands, got %v of %d", r, a.Count())
+	}
+	if r, _ := c.ExactRat(); r.Cmp(big.NewRat(2501, 1000)) != 0 || c.Count() != 4 || c.Equal(&a) {
+		t.Fatalf("expected 2.501 of 4 summands, got %v of %d", r, c.Count())
+	}
+	// Equal compares the decimal summands by value, whatever their scale.
+	var d, e Sum
+	d.AddDecimal(1, 1)
+	e.AddDecimal(100, 3)
+	if !d.Equal(&e) {
+		t.Fatalf("expected 0.1 == 0.100")
+	}
+	// No decimal summands, no allocation.
+	var f Sum
+	f.AddAll([]float64{1, 2})
+	if f.dec != nil {
+		t.Fatalf("expected no decimal total without AddDecimal")
+	}
+}
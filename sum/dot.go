@@ -0,0 +1,47 @@
+package sum
+
+import (
+	"math"
+	"math/big"
+)
+
+// Dot returns the dot product of xs and ys as float64.
+// Each product is split into hi+lo exactly (see TwoProduct), and both parts are summed up with Sum,
+// so the result is the exact dot product rounded to float64,
+// unless some products overflow or underflow.
+// Panics if xs and ys have different lengths.
+func Dot(xs, ys []float64) float64 {
+	v, nan := DotBig(xs, ys)
+	if nan {
+		return math.NaN()
+	}
+	f, _ := v.Float64()
+	return f
+}
+
+// DotBig returns the dot product of xs and ys as (sum *big.Float, isNan bool) pair, see Dot.
+// Unless some products overflow or underflow, the result is exact.
+func DotBig(xs, ys []float64) (*big.Float, bool) {
+	a := dot(xs, ys)
+	defer putSum(a)
+	if a.class() != classFinite {
+		return a.BigVal()
+	}
+	return a.exact(), false
+}
+
+// dot returns a Sum from the pool with the products of xs and ys added.
+func dot(xs, ys []float64) *Sum {
+	if len(xs) != len(ys) {
+		panic("sum: Dot of slices with different lengths")
+	}
+	a := sums.Get().(*Sum)
+	for i, x := range xs {
+		hi, lo := TwoProduct(x, ys[i])
+		a.Add(hi)
+		if !math.IsInf(hi, 0) && !math.IsNaN(hi) {
+			a.Add(lo)
+		}
+	}
+	return a
+}
@@ -0,0 +1,65 @@
+package sum
+
+import (
+	"math"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+func TestDotIllConditioned(t *testing.T) {
+	r := rand.New(rand.NewSource(21))
+	for k := 0; k < 20; k++ {
+		// A row of a Hilbert matrix multiplied by a vector with huge cancelling entries.
+		var xs, ys []float64
+		for j := 0; j < 50; j++ {
+			h := 1 / float64(k+j+1)
+			y := math.Ldexp(r.Float64(), 60)
+			xs = append(xs, h, h, h)
+			ys = append(ys, y, -y, r.Float64())
+		}
+		want := new(big.Rat)
+		var naive float64
+		for i := range xs {
+			want.Add(want, new(big.Rat).Mul(new(big.Rat).SetFloat64(xs[i]), new(big.Rat).SetFloat64(ys[i])))
+			naive += xs[i] * ys[i]
+		}
+		w, _ := want.Float64()
+		if got := Dot(xs, ys); got != w {
+			t.Fatalf("expected %g, got %g (naive %g)", w, got, naive)
+		}
+		got, nan := DotBig(xs, ys)
+		if g, _ := got.Rat(nil); nan || g.Cmp(want) != 0 {
+			t.Fatalf("expected %s, got %v", want.String(), got)
+		}
+	}
+}
+
+func TestDotSpecial(t *testing.T) {
+	for _, tc := range []struct {
+		xs, ys []float64
+		want   float64
+	}{
+		{nil, nil, 0},
+		{[]float64{1, 2}, []float64{3, 4}, 11},
+		{[]float64{math.Inf(1), 2}, []float64{2, 4}, math.Inf(1)},
+		{[]float64{math.Inf(1), 2}, []float64{-2, 4}, math.Inf(-1)},
+		{[]float64{math.Inf(1), 2}, []float64{0, 4}, math.NaN()},
+		{[]float64{math.NaN(), 2}, []float64{1, 4}, math.NaN()},
+		{[]float64{math.Inf(1), math.Inf(1)}, []float64{1, -1}, math.NaN()},
+	} {
+		got := Dot(tc.xs, tc.ys)
+		if math.IsNaN(tc.want) != math.IsNaN(got) || !math.IsNaN(got) && got != tc.want {
+			t.Fatalf("%v . %v: expected %g, got %g", tc.xs, tc.ys, tc.want, got)
+		}
+	}
+}
+
+func TestDotLengthMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected a panic")
+		}
+	}()
+	Dot([]float64{1}, []float64{1, 2})
+}
@@ -0,0 +1,33 @@
+package sum
+
+import "math"
+
+// Error-free transformations: the result of a floating point operation together with its exact rounding error.
+// They are the building blocks of compensated algorithms such as Kahan and Neumaier summation.
+
+// TwoSum returns hi = fl(a+b) and lo, such that hi+lo == a+b exactly, unless a+b overflows.
+// lo is the rounding error of hi.
+func TwoSum(a, b float64) (hi, lo float64) {
+	hi = a + b
+	bb := hi - a
+	lo = (a - (hi - bb)) + (b - bb)
+	return hi, lo
+}
+
+// FastTwoSum is TwoSum, requiring |a| >= |b| (or a == 0).
+// It takes three floating point operations instead of six.
+// If |a| < |b|, lo is not exact.
+func FastTwoSum(a, b float64) (hi, lo float64) {
+	hi = a + b
+	lo = b - (hi - a)
+	return hi, lo
+}
+
+// TwoProduct returns hi = fl(a*b) and lo, such that hi+lo == a*b exactly,
+// unless the product overflows or lo underflows (that is possible if |a*b| < 2^-969).
+// lo is the rounding error of hi.
+func TwoProduct(a, b float64) (hi, lo float64) {
+	hi = a * b
+	lo = math.FMA(a, b, -hi)
+	return hi, lo
+}
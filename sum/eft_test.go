@@ -0,0 +1,69 @@
+package sum
+
+import (
+	"math"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+func exactSum(a, b float64) *big.Float {
+	return new(big.Float).SetPrec(4096).Add(big.NewFloat(a), big.NewFloat(b))
+}
+
+func TestTwoSum(t *testing.T) {
+	r := rand.New(rand.NewSource(22))
+	xs := randomFloats(r, 200000)
+	xs = append(xs, 0, math.SmallestNonzeroFloat64, -math.SmallestNonzeroFloat64, 1, -1, math.MaxFloat64)
+	for i := 0; i+1 < len(xs); i++ {
+		a, b := xs[i], xs[i+1]
+		if i%3 == 0 {
+			b = a * math.Ldexp(1+r.Float64(), -r.Intn(60)) // Close magnitudes.
+		}
+		hi, lo := TwoSum(a, b)
+		if math.IsInf(hi, 0) {
+			continue
+		}
+		if hi != a+b {
+			t.Fatalf("TwoSum(%g, %g): expected hi %g, got %g", a, b, a+b, hi)
+		}
+		if exactSum(hi, lo).Cmp(exactSum(a, b)) != 0 {
+			t.Fatalf("TwoSum(%g, %g) = %g, %g is not exact", a, b, hi, lo)
+		}
+		if math.Abs(a) < math.Abs(b) {
+			a, b = b, a
+		}
+		fhi, flo := FastTwoSum(a, b)
+		if fhi != hi || flo != lo {
+			t.Fatalf("FastTwoSum(%g, %g): expected %g, %g, got %g, %g", a, b, hi, lo, fhi, flo)
+		}
+	}
+}
+
+func TestTwoProduct(t *testing.T) {
+	r := rand.New(rand.NewSource(22))
+	xs := randomFloats(r, 200000)
+	xs = append(xs, 0, math.SmallestNonzeroFloat64, -math.SmallestNonzeroFloat64, 1, -1, math.MaxFloat64)
+	checked := 0
+	for i := 0; i+1 < len(xs); i++ {
+		a, b := xs[i], xs[i+1]
+		if i%2 == 0 && a != 0 && b != 0 {
+			b = math.Ldexp(b, -math.Ilogb(a)-math.Ilogb(b)+r.Intn(100)-50) // Avoid overflow.
+		}
+		hi, lo := TwoProduct(a, b)
+		if hi != a*b {
+			t.Fatalf("TwoProduct(%g, %g): expected hi %g, got %g", a, b, a*b, hi)
+		}
+		if math.IsInf(hi, 0) || math.Abs(hi) < 0x1p-969 {
+			continue
+		}
+		checked++
+		want := new(big.Float).SetPrec(4096).Mul(big.NewFloat(a), big.NewFloat(b))
+		if exactSum(hi, lo).Cmp(want) != 0 {
+			t.Fatalf("TwoProduct(%g, %g) = %g, %g is not exact", a, b, hi, lo)
+		}
+	}
+	if checked < len(xs)/3 {
+		t.Fatalf("expected at least %d exact products to be checked, got %d", len(xs)/3, checked)
+	}
+}
@@ -0,0 +1,28 @@
+package sum
+
+import "math"
+
+// Horner returns the value of the polynomial coeffs[0] + coeffs[1]*x + ... + coeffs[n-1]*x^(n-1)
+// using the compensated Horner scheme: the rounding errors of every step are computed exactly
+// with TwoProduct and TwoSum and evaluated alongside, as a polynomial of their own.
+// The result is as accurate as if Horner's rule was computed in twice the working precision, then rounded to float64,
+// so it stays accurate close to the (multiple) roots, where naive evaluation loses most of the digits.
+// Returns 0 for no coefficients.
+// If the naive evaluation overflows, or there are infs or NaNs among the coefficients, returns the result of the naive one.
+func Horner(x float64, coeffs []float64) float64 {
+	if len(coeffs) == 0 {
+		return 0
+	}
+	s := coeffs[len(coeffs)-1]
+	c := 0.0
+	for i := len(coeffs) - 2; i >= 0; i-- {
+		p, pe := TwoProduct(s, x)
+		var se float64
+		s, se = TwoSum(p, coeffs[i])
+		c = c*x + (pe + se)
+	}
+	if math.IsInf(s, 0) || math.IsNaN(s) {
+		return s
+	}
+	return s + c
+}
@@ -0,0 +1,61 @@
+package sum
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+// hornerBig evaluates the polynomial exactly, see Horner.
+func hornerBig(x float64, coeffs []float64) *big.Rat {
+	v := new(big.Rat)
+	bx := new(big.Rat).SetFloat64(x)
+	for i := len(coeffs) - 1; i >= 0; i-- {
+		v.Mul(v, bx)
+		v.Add(v, new(big.Rat).SetFloat64(coeffs[i]))
+	}
+	return v
+}
+
+func TestHornerMultipleRoot(t *testing.T) {
+	// (x-1)^7, expanded: the coefficients are exact, evaluating it close to 1 cancels almost everything.
+	coeffs := []float64{-1, 7, -21, 35, -35, 21, -7, 1}
+	for _, x := range []float64{1.005, 0.997, 1.0013, 0.9991} {
+		w, _ := hornerBig(x, coeffs).Float64()
+		var naive float64
+		for i := len(coeffs) - 1; i >= 0; i-- {
+			naive = naive*x + coeffs[i]
+		}
+		got := Horner(x, coeffs)
+		errNaive, err := math.Abs((naive-w)/w), math.Abs((got-w)/w)
+		if err > 1e-9 || errNaive < 1e-3 {
+			t.Fatalf("%g: expected compensated Horner to beat the naive one by many digits, got relative errors %g (naive %g)", x, err, errNaive)
+		}
+	}
+}
+
+func TestHornerSpecial(t *testing.T) {
+	inf, nan := math.Inf(1), math.NaN()
+	for _, tc := range []struct {
+		x      float64
+		coeffs []float64
+		want   float64
+	}{
+		{2, nil, 0},
+		{2, []float64{3}, 3},
+		{nan, []float64{3}, 3},
+		{2, []float64{1, 2, 3}, 17},
+		{0.1, []float64{0, 1}, 0.1},
+		{2, []float64{inf, 1}, inf},
+		{2, []float64{1, -inf}, -inf},
+		{2, []float64{inf, -inf}, nan},
+		{2, []float64{1, nan}, nan},
+		{inf, []float64{1, 1}, inf},
+		{1e300, []float64{1, 1e300}, inf},
+	} {
+		got := Horner(tc.x, tc.coeffs)
+		if math.IsNaN(tc.want) != math.IsNaN(got) || !math.IsNaN(got) && got != tc.want {
+			t.Fatalf("%g, %v: expected %g, got %g", tc.x, tc.coeffs, tc.want, got)
+		}
+	}
+}
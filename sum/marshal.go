@@ -0,0 +1,432 @@
+package sum
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Binary encoding version.
+const binaryVersion = 1
+
+// Flags in the binary encoding.
+const (
+	flagPreserveSignedZero = 1 << iota
+	flagTrackMinMax
+	flagTrackCondition
+)
+
+var (
+	errBinaryVersion = errors.New("sum: unsupported binary encoding version")
+	errTruncated     = errors.New("sum: truncated binary encoding")
+	errBadCounter    = errors.New("sum: counter out of range")
+	errBadFlags      = errors.New("sum: unknown flags")
+	errBadExponent   = errors.New("sum: exponent out of range")
+	errTrailing      = errors.New("sum: trailing data after binary encoding")
+	errJSONString    = errors.New(`sum: JSON string is not "NaN", "+Inf" or "-Inf"`)
+	errText          = errors.New("sum: malformed text encoding")
+	errTextRange     = errors.New("sum: text encoding out of range")
+)
+
+// textPrefix starts the text encoding.
+const textPrefix = "sum:exact:"
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+// Only non-empty bins are stored, so the encoding is compact for typical inputs.
+// Returns an error if the sum has decimal summands, see AddDecimal.
+func (a Sum) MarshalBinary() ([]byte, error) {
+	if a.hasDecimal() {
+		return nil, errDecimal
+	}
+	bins := 0
+	for i := range a.mantissaLo {
+		if a.mantissaLo[i] != 0 || a.mantissaHi[i] != 0 {
+			bins++
+		}
+	}
+	b := make([]byte, 0, 2+6*binary.MaxVarintLen64+24+bins*(2+binary.MaxVarintLen32+binary.MaxVarintLen64))
+	b = append(b, binaryVersion)
+	var flags byte
+	if a.PreserveSignedZero {
+		flags |= flagPreserveSignedZero
+	}
+	if a.TrackMinMax {
+		flags |= flagTrackMinMax
+	}
+	if a.TrackCondition {
+		flags |= flagTrackCondition
+	}
+	b = append(b, flags)
+	b = binary.AppendUvarint(b, uint64(a.count))
+	b = binary.AppendUvarint(b, uint64(a.plusInfs))
+	b = binary.AppendUvarint(b, uint64(a.minusInfs))
+	b = binary.AppendUvarint(b, uint64(a.nans))
+	b = binary.AppendUvarint(b, uint64(a.negZeros))
+	b = binary.AppendUvarint(b, a.nanBits)
+	if a.TrackMinMax {
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(a.min))
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(a.max))
+	}
+	if a.TrackCondition {
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(a.abs))
+	}
+	b = binary.AppendUvarint(b, uint64(bins))
+	for i := range a.mantissaLo {
+		if a.mantissaLo[i] == 0 && a.mantissaHi[i] == 0 {
+			continue
+		}
+		b = binary.AppendUvarint(b, uint64(i))
+		b = binary.AppendVarint(b, int64(a.mantissaHi[i]))
+		b = binary.AppendUvarint(b, a.mantissaLo[i])
+	}
+	return b, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+// Restores the accumulator encoded with MarshalBinary, including the options.
+// On error a is left unchanged.
+func (a *Sum) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 {
+		return errTruncated
+	}
+	if data[0] != binaryVersion {
+		return errBinaryVersion
+	}
+	if len(data) == 1 {
+		return errTruncated
+	}
+	flags := data[1]
+	if flags&^(flagPreserveSignedZero|flagTrackMinMax|flagTrackCondition) != 0 {
+		return errBadFlags
+	}
+	d := decoder{data: data[2:]}
+	var s Sum
+	s.PreserveSignedZero = flags&flagPreserveSignedZero != 0
+	s.TrackMinMax = flags&flagTrackMinMax != 0
+	s.TrackCondition = flags&flagTrackCondition != 0
+	s.count = d.counter()
+	s.plusInfs = d.counter()
+	s.minusInfs = d.counter()
+	s.nans = d.counter()
+	s.negZeros = d.counter()
+	s.nanBits = d.uvarint()
+	if s.TrackMinMax {
+		s.min = math.Float64frombits(d.fixed64())
+		s.max = math.Float64frombits(d.fixed64())
+	}
+	if s.TrackCondition {
+		s.abs = math.Float64frombits(d.fixed64())
+	}
+	bins := d.counter()
+	next := 0 // Bins are stored in increasing order.
+	for i := 0; i < bins && d.err == nil; i++ {
+		exp := d.uvarint()
+		if exp < uint64(next) || exp >= 1<<exponentBits-1 {
+			d.fail(errBadExponent)
+			break
+		}
+		hi := d.varint()
+		if hi < math.MinInt32 || hi > math.MaxInt32 {
+			d.fail(errBadCounter)
+			break
+		}
+		s.mantissaHi[exp] = int32(hi)
+		s.mantissaLo[exp] = d.uvarint()
+		next = int(exp) + 1
+	}
+	if d.err != nil {
+		return d.err
+	}
+	if len(d.data) != 0 {
+		return errTrailing
+	}
+	*a = s
+	return nil
+}
+
+// GobEncode implements gob.GobEncoder, using the encoding of MarshalBinary.
+// gob would pick MarshalBinary on its own, GobEncode makes the choice explicit,
+// so a Sum (or a *Sum) can be a field of a gob-encoded struct.
+func (a Sum) GobEncode() ([]byte, error) {
+	return a.MarshalBinary()
+}
+
+// GobDecode implements gob.GobDecoder, see UnmarshalBinary.
+func (a *Sum) GobDecode(data []byte) error {
+	return a.UnmarshalBinary(data)
+}
+
+// MarshalJSON implements json.Marshaler.
+// The sum is encoded as its Val, a JSON number, so the encoding is compact and readable, but lossy:
+// it does not keep the exact sum, the counters or the options, use MarshalBinary for that.
+// JSON has no infs and NaNs, those are encoded as strings: "+Inf", "-Inf" and "NaN".
+// Like the other marshalers, it has a value receiver, so a Sum that is not addressable,
+// e.g. a map value or a field of a struct passed by value, is encoded as well.
+func (a Sum) MarshalJSON() ([]byte, error) {
+	v := a.Val()
+	switch {
+	case math.IsNaN(v):
+		return []byte(`"NaN"`), nil
+	case math.IsInf(v, 1):
+		return []byte(`"+Inf"`), nil
+	case math.IsInf(v, -1):
+		return []byte(`"-Inf"`), nil
+	}
+	return strconv.AppendFloat(nil, v, 'g', -1, 64), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+// Resets a and adds the value encoded with MarshalJSON. Keeps the options.
+// Like the standard types, null leaves a unchanged. On error a is left unchanged.
+func (a *Sum) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var v float64
+	if err := json.Unmarshal(data, &v); err != nil {
+		var s string
+		if json.Unmarshal(data, &s) != nil {
+			return err
+		}
+		switch s {
+		case "NaN":
+			v = math.NaN()
+		case "+Inf":
+			v = math.Inf(1)
+		case "-Inf":
+			v = math.Inf(-1)
+		default:
+			return errJSONString
+		}
+	}
+	a.Reset()
+	a.AddTracked(v)
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+// The encoding is the exact finite part of the sum as a fraction, followed by the counters,
+// then the options that are set and the values they track, e.g.
+//
+//	sum:exact:3/4 count=3 +inf=0 -inf=0 nan=0 -0=0 min=0.25 max=0.25
+//
+// It is exact and canonical: Sums with the same value, counters and options have the same encoding,
+// whatever their bins are, so it suits golden files and diffs. MarshalBinary is more compact.
+// Returns an error if the sum has decimal summands, see AddDecimal.
+func (a Sum) MarshalText() ([]byte, error) {
+	if a.hasDecimal() {
+		return nil, errDecimal
+	}
+	b := []byte(textPrefix)
+	r := new(big.Rat).SetFrac(a.scaledInt(), new(big.Int).Lsh(big.NewInt(1), -minExp))
+	b = append(b, r.String()...)
+	for _, c := range []struct {
+		name string
+		v    int
+	}{{" count=", a.count}, {" +inf=", a.plusInfs}, {" -inf=", a.minusInfs}, {" nan=", a.nans}, {" -0=", a.negZeros}} {
+		b = append(b, c.name...)
+		b = strconv.AppendInt(b, int64(c.v), 10)
+	}
+	if a.nans != 0 {
+		b = append(b, " nanbits=0x"...)
+		b = strconv.AppendUint(b, a.nanBits, 16)
+	}
+	if a.PreserveSignedZero {
+		b = append(b, " signedzero"...)
+	}
+	if a.TrackMinMax {
+		b = append(b, " min="...)
+		b = strconv.AppendFloat(b, a.min, 'g', -1, 64)
+		b = append(b, " max="...)
+		b = strconv.AppendFloat(b, a.max, 'g', -1, 64)
+	}
+	if a.TrackCondition {
+		b = append(b, " abs="...)
+		b = strconv.AppendFloat(b, a.abs, 'g', -1, 64)
+	}
+	return b, nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+// Restores the sum encoded with MarshalText, including the options.
+// The bins may differ from the encoded Sum, but the exact value (see ExactRat) is the same.
+// On error a is left unchanged.
+func (a *Sum) UnmarshalText(text []byte) error {
+	fields := strings.Fields(string(text))
+	if len(fields) < 6 || !strings.HasPrefix(fields[0], textPrefix) {
+		return errText
+	}
+	r, ok := parseExact(strings.TrimPrefix(fields[0], textPrefix))
+	if !ok {
+		return errText
+	}
+	// The finite part is a multiple of 2^minExp.
+	x, rem := new(big.Int).QuoRem(new(big.Int).Lsh(r.Num(), -minExp), r.Denom(), new(big.Int))
+	if rem.Sign() != 0 || x.BitLen() >= maxScaledBits {
+		return errTextRange
+	}
+	var s Sum
+	for i, p := range []*int{&s.count, &s.plusInfs, &s.minusInfs, &s.nans, &s.negZeros} {
+		v, ok := textField(fields[i+1], [...]string{"count", "+inf", "-inf", "nan", "-0"}[i])
+		n, err := strconv.ParseUint(v, 10, 0)
+		if !ok || err != nil || n > math.MaxInt {
+			return errText
+		}
+		*p = int(n)
+	}
+	rest := fields[6:]
+	next := func(name string) (string, bool) {
+		if len(rest) == 0 {
+			return "", false
+		}
+		v, ok := textField(rest[0], name)
+		if ok {
+			rest = rest[1:]
+		}
+		return v, ok
+	}
+	var err error
+	float := func(name string) float64 {
+		v, ok := next(name)
+		if !ok {
+			err = errText
+			return 0
+		}
+		f, e := strconv.ParseFloat(v, 64)
+		if e != nil {
+			err = errText
+		}
+		return f
+	}
+	if s.nans != 0 {
+		v, ok := next("nanbits")
+		if !ok || !strings.HasPrefix(v, "0x") {
+			return errText
+		}
+		if s.nanBits, err = strconv.ParseUint(v[2:], 16, 64); err != nil {
+			return errText
+		}
+	}
+	if len(rest) != 0 && rest[0] == "signedzero" {
+		s.PreserveSignedZero = true
+		rest = rest[1:]
+	}
+	if len(rest) != 0 && strings.HasPrefix(rest[0], "min=") {
+		s.TrackMinMax = true
+		s.min = float("min")
+		s.max = float("max")
+	}
+	if len(rest) != 0 && strings.HasPrefix(rest[0], "abs=") {
+		s.TrackCondition = true
+		s.abs = float("abs")
+	}
+	if err != nil || len(rest) != 0 {
+		return errText
+	}
+	s.setScaledInt(x)
+	*a = s
+	return nil
+}
+
+// maxExactDigits bounds the numerator and the denominator of the exact part of the text encoding:
+// 2^maxScaledBits has 645 digits, 2^-minExp has 324.
+const maxExactDigits = 650
+
+// parseExact parses the exact part of the text encoding, an integer or a fraction as written by big.Rat.String.
+// Unlike big.Rat.SetString, accepts no exponents, so hostile input cannot make it allocate a huge number.
+func parseExact(v string) (*big.Rat, bool) {
+	num, den, frac := strings.Cut(v, "/")
+	n, ok := parseDigits(strings.TrimPrefix(num, "-"))
+	if !ok {
+		return nil, false
+	}
+	if strings.HasPrefix(num, "-") {
+		n.Neg(n)
+	}
+	d := big.NewInt(1)
+	if frac {
+		if d, ok = parseDigits(den); !ok || d.Sign() == 0 {
+			return nil, false
+		}
+	}
+	return new(big.Rat).SetFrac(n, d), true
+}
+
+// parseDigits parses a non-empty string of at most maxExactDigits decimal digits.
+func parseDigits(v string) (*big.Int, bool) {
+	if len(v) == 0 || len(v) > maxExactDigits || strings.Trim(v, "0123456789") != "" {
+		return nil, false
+	}
+	return new(big.Int).SetString(v, 10)
+}
+
+// textField returns the value of a name=value field of the text encoding.
+func textField(f, name string) (string, bool) {
+	v, ok := strings.CutPrefix(f, name+"=")
+	return v, ok
+}
+
+// decoder reads varints, remembering the first error.
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.fail(errTruncated)
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.data)
+	if n <= 0 {
+		d.fail(errTruncated)
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *decoder) fixed64() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	if len(d.data) < 8 {
+		d.fail(errTruncated)
+		return 0
+	}
+	v := binary.LittleEndian.Uint64(d.data)
+	d.data = d.data[8:]
+	return v
+}
+
+// counter reads a non-negative int.
+func (d *decoder) counter() int {
+	v := d.uvarint()
+	if v > math.MaxInt {
+		d.fail(errBadCounter)
+		return 0
+	}
+	return int(v)
+}
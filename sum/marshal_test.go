@@ -0,0 +1,337 @@
+package sum
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/gob"
+	"encoding/json"
+	"math"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var (
+	_ encoding.BinaryMarshaler   = &Sum{}
+	_ encoding.BinaryUnmarshaler = &Sum{}
+	_ encoding.TextMarshaler     = &Sum{}
+	_ encoding.TextUnmarshaler   = &Sum{}
+	_ gob.GobEncoder             = &Sum{}
+	_ gob.GobDecoder             = &Sum{}
+	_ json.Marshaler             = Sum{}
+	_ encoding.BinaryMarshaler   = Sum{}
+	_ encoding.TextMarshaler     = Sum{}
+	_ gob.GobEncoder             = Sum{}
+)
+
+// randomSum returns an accumulator with random finite values and occasional infs and nans.
+func randomSum(r *rand.Rand) *Sum {
+	a := Sum{PreserveSignedZero: r.Intn(2) == 0, TrackMinMax: r.Intn(2) == 0, TrackCondition: r.Intn(2) == 0}
+	for _, x := range randomFloats(r, r.Intn(1000)) {
+		a.Add(x)
+	}
+	for _, x := range []float64{math.Inf(1), math.Inf(-1), math.NaN()} {
+		if r.Intn(5) == 0 {
+			a.Add(x)
+		}
+	}
+	return &a
+}
+
+func TestMarshalBinary(t *testing.T) {
+	r := rand.New(rand.NewSource(9))
+	for k := 0; k < 200; k++ {
+		a := randomSum(r)
+		data, err := a.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b Sum
+		b.Add(42) // Should be overwritten.
+		if err := b.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		if *a != b {
+			t.Fatalf("expected identical accumulators after round trip")
+		}
+		av, anan := a.BigVal()
+		bv, bnan := b.BigVal()
+		if anan != bnan || !anan && av.Cmp(bv) != 0 {
+			t.Fatalf("expected %v, got %v", av, bv)
+		}
+	}
+}
+
+func TestMarshalBinaryEmpty(t *testing.T) {
+	var a Sum
+	data, err := a.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 9 {
+		t.Fatalf("expected 9 bytes, got %d", len(data))
+	}
+}
+
+func TestUnmarshalBinaryErrors(t *testing.T) {
+	var a Sum
+	a.Add(1)
+	a.Add(-1e300)
+	a.Add(math.SmallestNonzeroFloat64)
+	data, _ := a.MarshalBinary()
+	for i := 0; i < len(data); i++ {
+		var b Sum
+		if err := b.UnmarshalBinary(data[:i]); err == nil {
+			t.Fatalf("expected an error for truncated data %v", data[:i])
+		}
+		if b != (Sum{}) {
+			t.Fatalf("expected failed UnmarshalBinary to leave the accumulator unchanged")
+		}
+	}
+	// Encoding of an empty Sum up to the number of bins.
+	empty, _ := (&Sum{}).MarshalBinary()
+	header := empty[:len(empty)-1]
+	bins := func(b ...byte) []byte {
+		return append(slices.Clone(header), b...)
+	}
+	for _, tc := range []struct {
+		data []byte
+		err  error
+	}{
+		{[]byte{}, errTruncated},
+		{[]byte{1}, errTruncated},
+		{[]byte{2, 0, 0, 0, 0, 0, 0, 0, 0}, errBinaryVersion},
+		{[]byte{1, flagTrackCondition << 1, 0, 0, 0, 0, 0, 0, 0}, errBadFlags},
+		{[]byte{1, flagTrackMinMax, 0, 0, 0, 0, 0, 0, 1, 2, 3, 4, 5, 6, 7, 8, 1, 2, 3}, errTruncated},
+		{bins(1, 0xff, 0x0f, 0, 1), errBadExponent},                  // exponent 2047.
+		{bins(2, 5, 0, 1, 3, 0, 1), errBadExponent},                  // decreasing exponents.
+		{bins(1, 5, 0x80, 0x80, 0x80, 0x80, 0x20, 1), errBadCounter}, // hi overflows int32.
+		{bins(0, 0), errTrailing},
+	} {
+		var b Sum
+		if err := b.UnmarshalBinary(tc.data); err != tc.err {
+			t.Fatalf("%v: expected error %v, got %v", tc.data, tc.err, err)
+		}
+	}
+}
+
+func TestMarshalText(t *testing.T) {
+	r := rand.New(rand.NewSource(577))
+	for k := 0; k < 200; k++ {
+		a := randomSum(r)
+		for i := 0; i < r.Intn(10); i++ {
+			a.Add(math.Ldexp(r.Float64()-0.5, -1074+r.Intn(60))) // Subnormals.
+		}
+		data, err := a.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b Sum
+		b.Add(42) // Should be overwritten.
+		if err := b.UnmarshalText(data); err != nil {
+			t.Fatalf("%s: %v", data, err)
+		}
+		if b.count != a.count || b.plusInfs != a.plusInfs || b.minusInfs != a.minusInfs ||
+			b.nans != a.nans || b.negZeros != a.negZeros || b.nanBits != a.nanBits ||
+			b.PreserveSignedZero != a.PreserveSignedZero || b.TrackMinMax != a.TrackMinMax || b.TrackCondition != a.TrackCondition ||
+			b.min != a.min || b.max != a.max || b.abs != a.abs {
+			t.Fatalf("%s: expected the same counters and options after round trip", data)
+		}
+		ar, anan := a.ExactRat()
+		br, bnan := b.ExactRat()
+		if anan != bnan || (ar == nil) != (br == nil) || ar != nil && ar.Cmp(br) != 0 || a.scaledInt().Cmp(b.scaledInt()) != 0 {
+			t.Fatalf("%s: expected %v, got %v", data, ar, br)
+		}
+		again, _ := b.MarshalText()
+		if string(again) != string(data) {
+			t.Fatalf("expected a canonical encoding, got %s and %s", data, again)
+		}
+	}
+}
+
+func TestMarshalTextFormat(t *testing.T) {
+	a := Sum{TrackMinMax: true}
+	a.AddAll([]float64{0.5, 0.25, -0.5, math.Copysign(0, -1)})
+	data, _ := a.MarshalText()
+	if want := "sum:exact:1/4 count=4 +inf=0 -inf=0 nan=0 -0=1 min=-0.5 max=0.5"; string(data) != want {
+		t.Fatalf("expected %s, got %s", want, data)
+	}
+	for _, bad := range []string{
+		"",
+		"sum:exact:1/4",
+		"sum:exact:1/3 count=1 +inf=0 -inf=0 nan=0 -0=0",
+		"sum:exact:x count=1 +inf=0 -inf=0 nan=0 -0=0",
+		"sum:exact:1 count=-1 +inf=0 -inf=0 nan=0 -0=0",
+		"sum:exact:1 count=1 -inf=0 +inf=0 nan=0 -0=0",
+		"sum:exact:1 count=1 +inf=0 -inf=0 nan=1 -0=0",
+		"sum:exact:1 count=1 +inf=0 -inf=0 nan=0 -0=0 min=1",
+		"sum:exact:1 count=1 +inf=0 -inf=0 nan=0 -0=0 abs=1 signedzero",
+		"sum:exact:1 count=1 +inf=0 -inf=0 nan=0 -0=0 extra",
+		"sum:exact:1" + strings.Repeat("0", 400) + " count=1 +inf=0 -inf=0 nan=0 -0=0",
+		// Hostile input must fail fast instead of building a huge number.
+		"sum:exact:1e999999999 count=1 +inf=0 -inf=0 nan=0 -0=0",
+		"sum:exact:1/1e999999999 count=1 +inf=0 -inf=0 nan=0 -0=0",
+		"sum:exact:0x1p999999999 count=1 +inf=0 -inf=0 nan=0 -0=0",
+		"sum:exact:1" + strings.Repeat("0", 100000) + " count=1 +inf=0 -inf=0 nan=0 -0=0",
+		"sum:exact:1/" + strings.Repeat("3", 100000) + " count=1 +inf=0 -inf=0 nan=0 -0=0",
+		"sum:exact:1.5 count=1 +inf=0 -inf=0 nan=0 -0=0",
+		"sum:exact:1/0 count=1 +inf=0 -inf=0 nan=0 -0=0",
+		"sum:exact:--1 count=1 +inf=0 -inf=0 nan=0 -0=0",
+		"sum:exact:+1 count=1 +inf=0 -inf=0 nan=0 -0=0",
+	} {
+		b := Sum{}
+		b.AddTracked(1)
+		if err := b.UnmarshalText([]byte(bad)); err == nil {
+			t.Fatalf("%q: expected an error", bad)
+		}
+		if b.Val() != 1 || b.Count() != 1 {
+			t.Fatalf("%q: expected the sum to be unchanged on error", bad)
+		}
+	}
+}
+
+func TestGob(t *testing.T) {
+	type message struct {
+		Name  string
+		Total *Sum
+		Parts []Sum
+	}
+	r := rand.New(rand.NewSource(578))
+	in := message{Name: "partial", Total: randomSum(r), Parts: []Sum{*randomSum(r), {}}}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(in); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() > 100000 {
+		t.Fatalf("expected the sparse encoding, got %d bytes", buf.Len())
+	}
+	var out message
+	if err := gob.NewDecoder(&buf).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Name != in.Name || *out.Total != *in.Total || len(out.Parts) != 2 || out.Parts[0] != in.Parts[0] || out.Parts[1] != in.Parts[1] {
+		t.Fatalf("expected identical accumulators after round trip")
+	}
+
+	var a Sum
+	if err := a.GobDecode([]byte{binaryVersion, 0, 1}); err == nil {
+		t.Fatalf("expected an error on malformed data")
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	for _, tc := range []struct {
+		in   []float64
+		want string
+	}{
+		{nil, "0"},
+		{[]float64{0.1, 0.2}, "0.30000000000000004"},
+		{[]float64{1e300, 1, -1e300}, "1"},
+		{[]float64{-2.5e-300}, "-2.5e-300"},
+		{[]float64{1e308, 1e308}, `"+Inf"`},
+		{[]float64{1, math.Inf(-1)}, `"-Inf"`},
+		{[]float64{math.Inf(1), math.Inf(-1)}, `"NaN"`},
+	} {
+		var a Sum
+		a.AddAll(tc.in)
+		data, err := json.Marshal(&a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tc.want {
+			t.Fatalf("%v: expected %s, got %s", tc.in, tc.want, data)
+		}
+		b := Sum{TrackMinMax: true}
+		b.Add(42) // Should be reset.
+		if err := json.Unmarshal(data, &b); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := b.Val(), a.Val(); got != want && !(math.IsNaN(got) && math.IsNaN(want)) {
+			t.Fatalf("%s: expected %g, got %g", data, want, got)
+		}
+		if !b.TrackMinMax {
+			t.Fatalf("expected UnmarshalJSON to keep the options")
+		}
+	}
+	// Inside a struct, the sum is a plain number.
+	var a Sum
+	a.Add(1.5)
+	data, err := json.Marshal(struct {
+		Total *Sum `json:"total"`
+	}{&a})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"total":1.5}` {
+		t.Fatalf("expected a plain number, got %s", data)
+	}
+}
+
+func TestMarshalByValue(t *testing.T) {
+	type message struct {
+		S Sum
+	}
+	var in message
+	in.S.AddAll([]float64{1e300, 0.5, -1e300})
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"S":0.5}` {
+		t.Fatalf("expected the sum as a plain number, got %s", data)
+	}
+	var out message
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.S.Val() != 0.5 || out.S.Count() != 1 {
+		t.Fatalf("expected 0.5 of 1 summand, got %g of %d", out.S.Val(), out.S.Count())
+	}
+	// Map values are not addressable.
+	data, err = json.Marshal(map[string]Sum{"total": in.S})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"total":0.5}` {
+		t.Fatalf("expected the sum as a plain number, got %s", data)
+	}
+	// Exact round trips.
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(in); err != nil {
+		t.Fatal(err)
+	}
+	out = message{}
+	if err := gob.NewDecoder(&buf).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !out.S.Equal(&in.S) {
+		t.Fatalf("expected gob to round trip the sum, got %v", &out.S)
+	}
+	text, err := in.S.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = message{}
+	if err := out.S.UnmarshalText(text); err != nil || out.S.Cmp(&in.S) != 0 || out.S.Count() != in.S.Count() {
+		t.Fatalf("expected the text encoding to round trip the sum, got %v", &out.S)
+	}
+}
+
+func TestUnmarshalJSONErrors(t *testing.T) {
+	for _, in := range []string{`"inf"`, `"1"`, `[1]`, `{}`, `1e400`, `true`} {
+		var a Sum
+		a.Add(3)
+		if err := a.UnmarshalJSON([]byte(in)); err == nil {
+			t.Fatalf("%s: expected an error", in)
+		}
+		if a.Val() != 3 {
+			t.Fatalf("%s: expected the sum to be left unchanged, got %g", in, a.Val())
+		}
+	}
+	var a Sum
+	a.Add(3)
+	if err := json.Unmarshal([]byte("null"), &a); err != nil || a.Val() != 3 {
+		t.Fatalf("expected null to leave the sum unchanged, got %g, %v", a.Val(), err)
+	}
+}
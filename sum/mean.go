@@ -0,0 +1,40 @@
+package sum
+
+import (
+	"math"
+	"math/big"
+)
+
+// Mean computes the arithmetic mean of float64 numbers using Sum.
+// Like Sum, it deals with catastrophic cancellation.
+// Infs and NaNs are not counted, but they affect the result as they would affect the sum:
+// mean of (1, +Inf) is +Inf.
+type Mean struct {
+	s Sum
+}
+
+// Add a float64 value to the mean.
+func (m *Mean) Add(v float64) {
+	m.s.AddTracked(v)
+}
+
+// Count returns the number of finite values added.
+func (m *Mean) Count() int {
+	return m.s.Count()
+}
+
+// Val returns the current mean as float64.
+// Returns NaN if no values were added.
+// Like Sum, does not preserve signed zeroes: mean of single (-0) is +0.
+func (m *Mean) Val() float64 {
+	if m.s.nans != 0 || m.s.plusInfs != 0 || m.s.minusInfs != 0 {
+		return m.s.Val()
+	}
+	if m.s.count == 0 {
+		return math.NaN()
+	}
+	v := m.s.exact()
+	v.Quo(v, new(big.Float).SetInt64(int64(m.s.count)))
+	f, _ := v.Float64()
+	return f
+}
@@ -0,0 +1,68 @@
+package sum
+
+import (
+	"math"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+func TestMeanEmpty(t *testing.T) {
+	var m Mean
+	if !math.IsNaN(m.Val()) {
+		t.Fatalf("expected nan, got %g", m.Val())
+	}
+}
+
+func TestMeanEdgeCases(t *testing.T) {
+	for _, tc := range []struct {
+		in    []float64
+		want  float64
+		count int
+	}{
+		{[]float64{math.Copysign(0, -1)}, 0, 1},
+		{[]float64{1, 2}, 1.5, 2},
+		{[]float64{1e308, 1e308, -1e308}, 1e308 / 3, 3},
+		{[]float64{1, math.Inf(1)}, math.Inf(1), 1},
+		{[]float64{math.Inf(-1)}, math.Inf(-1), 0},
+		{[]float64{math.Inf(-1), math.Inf(1), 2}, math.NaN(), 1},
+		{[]float64{math.NaN(), 2}, math.NaN(), 1},
+	} {
+		var m Mean
+		for _, x := range tc.in {
+			m.Add(x)
+		}
+		got := m.Val()
+		if m.Count() != tc.count {
+			t.Fatalf("%v: expected count %d, got %d", tc.in, tc.count, m.Count())
+		}
+		if math.IsNaN(tc.want) {
+			if !math.IsNaN(got) {
+				t.Fatalf("%v: expected nan, got %g", tc.in, got)
+			}
+			continue
+		}
+		if math.Float64bits(got) != math.Float64bits(tc.want) {
+			t.Fatalf("%v: expected %g, got %g", tc.in, tc.want, got)
+		}
+	}
+}
+
+func TestMeanRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	for k := 0; k < 10; k++ {
+		var m Mean
+		want := new(big.Float).SetPrec(4096)
+		n := 1 + r.Intn(10000)
+		for i := 0; i < n; i++ {
+			x := r.NormFloat64()*1e6 + 1e9
+			m.Add(x)
+			want.Add(want, big.NewFloat(x))
+		}
+		want.Quo(want, big.NewFloat(float64(n)))
+		w, _ := want.Float64()
+		if got := m.Val(); math.Abs(got-w) > math.Abs(w)*1e-15 {
+			t.Fatalf("expected %g, got %g", w, got)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package sum
+
+import (
+	"math"
+	"math/big"
+)
+
+// Norm2 returns the Euclidean norm of xs: sqrt(sum(x*x)).
+// The values are scaled by a power of two so that the largest one is below 1, and the squares are summed up
+// exactly (see AddSquared), so there is no overflow or underflow, unless the result itself is out of the float64 range.
+// The only rounding is in the square root: the result is within an ulp of the exact norm.
+// Like math.Hypot, returns +Inf if any value is infinite, otherwise NaN if any value is NaN.
+func Norm2(xs []float64) float64 {
+	m := NormInf(xs)
+	if m == 0 || math.IsInf(m, 0) || math.IsNaN(m) {
+		return m
+	}
+	_, e := math.Frexp(m)
+	a := sums.Get().(*Sum)
+	defer putSum(a)
+	for _, x := range xs {
+		// Exact, unless the scaled value is subnormal: it is then less than 2^-1022 of the largest one,
+		// and its square is far below the precision of the result.
+		a.AddSquared(math.Ldexp(x, -e))
+	}
+	// The squares are rounded twice: to 128 bits by Sqrt and to float64.
+	f := new(big.Float).SetPrec(128).Sqrt(a.exact())
+	v, _ := f.SetMantExp(f, e).Float64()
+	return v
+}
+
+// Norm1 returns the sum of the absolute values of xs, summed up with Sum, so it is correctly rounded.
+// Returns +Inf if any value is infinite and none is NaN, NaN if any value is NaN.
+func Norm1(xs []float64) float64 {
+	a := sums.Get().(*Sum)
+	defer putSum(a)
+	for _, x := range xs {
+		a.Add(math.Abs(x))
+	}
+	return a.Val()
+}
+
+// NormInf returns the largest absolute value in xs, 0 if xs is empty.
+// Like math.Max, returns +Inf if any value is infinite, otherwise NaN if any value is NaN.
+func NormInf(xs []float64) float64 {
+	m := 0.0
+	for _, x := range xs {
+		m = math.Max(m, math.Abs(x))
+	}
+	return m
+}
@@ -0,0 +1,76 @@
+package sum
+
+import (
+	"math"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+func TestNorm2(t *testing.T) {
+	r := rand.New(rand.NewSource(583))
+	for k := 0; k < 50; k++ {
+		// Elements spanning 1e-150 to 1e150: the squares overflow and underflow float64.
+		var xs []float64
+		for i := 0; i < 100; i++ {
+			xs = append(xs, (r.Float64()-0.5)*math.Pow(10, float64(r.Intn(301)-150)))
+		}
+		if k%2 == 0 {
+			xs = append(xs, 1e150, -1e150)
+		}
+		sq := new(big.Float).SetPrec(4000)
+		for _, x := range xs {
+			bx := new(big.Float).SetFloat64(x)
+			sq.Add(sq, bx.Mul(bx, bx))
+		}
+		want, _ := new(big.Float).SetPrec(200).Sqrt(sq).Float64()
+		got := Norm2(xs)
+		if math.Abs(got-want) > math.Abs(math.Nextafter(want, 0)-want) {
+			t.Fatalf("expected %g, got %g", want, got)
+		}
+	}
+}
+
+func TestNorm2Special(t *testing.T) {
+	inf, nan := math.Inf(1), math.NaN()
+	for _, tc := range []struct {
+		xs   []float64
+		want float64
+	}{
+		{nil, 0},
+		{[]float64{0, math.Copysign(0, -1)}, 0},
+		{[]float64{3, -4}, 5},
+		{[]float64{3 * 0x1p600, 4 * 0x1p600}, 5 * 0x1p600},
+		{[]float64{3 * 0x1p-600, -4 * 0x1p-600}, 5 * 0x1p-600},
+		{[]float64{math.SmallestNonzeroFloat64}, math.SmallestNonzeroFloat64},
+		{[]float64{math.MaxFloat64, math.MaxFloat64}, inf},
+		{[]float64{1, nan}, nan},
+		{[]float64{nan, -inf}, inf},
+	} {
+		got := Norm2(tc.xs)
+		if math.IsNaN(got) != math.IsNaN(tc.want) || !math.IsNaN(got) && got != tc.want {
+			t.Fatalf("%g: expected %g, got %g", tc.xs, tc.want, got)
+		}
+	}
+}
+
+func TestNorm1NormInf(t *testing.T) {
+	inf, nan := math.Inf(1), math.NaN()
+	for _, tc := range []struct {
+		xs         []float64
+		one, infty float64
+	}{
+		{nil, 0, 0},
+		{[]float64{1e300, -1, -1e300}, 2e300, 1e300},
+		{[]float64{0.1, -0.2, 0.3}, 0.6, 0.3},
+		{[]float64{-inf, 1}, inf, inf},
+		{[]float64{nan, 1}, nan, nan},
+		{[]float64{nan, inf}, nan, inf},
+	} {
+		one, infty := Norm1(tc.xs), NormInf(tc.xs)
+		if math.IsNaN(one) != math.IsNaN(tc.one) || !math.IsNaN(one) && one != tc.one ||
+			math.IsNaN(infty) != math.IsNaN(tc.infty) || !math.IsNaN(infty) && infty != tc.infty {
+			t.Fatalf("%g: expected %g and %g, got %g and %g", tc.xs, tc.one, tc.infty, one, infty)
+		}
+	}
+}
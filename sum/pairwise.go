@@ -0,0 +1,56 @@
+package sum
+
+import "sync"
+
+// pairwiseBase is the size of a block summed up directly by Pairwise.
+const pairwiseBase = 128
+
+// Pairwise sums xs up using pairwise (cascade) summation, see
+// https://en.wikipedia.org/wiki/Pairwise_summation
+// The error grows as O(log n), unlike O(n) for the naive summation, at about the same speed.
+// Infs and NaNs propagate as in regular float64 addition.
+func Pairwise(xs []float64) float64 {
+	return PairwiseBase(xs, pairwiseBase)
+}
+
+// PairwiseBase is Pairwise that sums blocks of up to base values directly.
+// Larger blocks are faster, the error bound grows linearly with base.
+// Panics if base <= 0.
+func PairwiseBase(xs []float64, base int) float64 {
+	if base <= 0 {
+		panic("sum: pairwise base must be positive")
+	}
+	return pairwise(xs, base)
+}
+
+func pairwise(xs []float64, base int) float64 {
+	if len(xs) <= base {
+		s := 0.0
+		for _, x := range xs {
+			s += x
+		}
+		return s
+	}
+	m := len(xs) / 2
+	return pairwise(xs[:m], base) + pairwise(xs[m:], base)
+}
+
+// PairwiseParallel is Pairwise that splits the work between up to workers goroutines.
+// The halves are split exactly as in Pairwise, so the result is the same, bit for bit.
+// workers <= 1 sums xs up in the calling goroutine.
+func PairwiseParallel(xs []float64, workers int) float64 {
+	if workers <= 1 || len(xs) <= pairwiseBase {
+		return pairwise(xs, pairwiseBase)
+	}
+	m := len(xs) / 2
+	var lo float64
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		lo = PairwiseParallel(xs[:m], workers/2)
+	}()
+	hi := PairwiseParallel(xs[m:], workers-workers/2)
+	wg.Wait()
+	return lo + hi
+}
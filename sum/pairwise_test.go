@@ -0,0 +1,127 @@
+package sum
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func TestPairwiseSmall(t *testing.T) {
+	for _, tc := range []struct {
+		in   []float64
+		want float64
+	}{
+		{nil, 0},
+		{[]float64{3}, 3},
+		{[]float64{1, 2, 3}, 6},
+		{[]float64{1, math.Inf(1)}, math.Inf(1)},
+		{[]float64{math.Inf(-1), math.Inf(1)}, math.NaN()},
+		{[]float64{math.NaN(), 1}, math.NaN()},
+	} {
+		got := Pairwise(tc.in)
+		if math.IsNaN(tc.want) != math.IsNaN(got) || !math.IsNaN(got) && got != tc.want {
+			t.Fatalf("%v: expected %g, got %g", tc.in, tc.want, got)
+		}
+	}
+}
+
+func TestPairwiseAccuracy(t *testing.T) {
+	r := rand.New(rand.NewSource(15))
+	// Large values cancel out, leaving the sum of the small ones.
+	var xs []float64
+	for i := 0; i < 300000; i++ {
+		x := r.NormFloat64() * 1e8
+		xs = append(xs, x, -x, r.Float64()*1e-3)
+	}
+	r.Shuffle(len(xs), func(i, j int) { xs[i], xs[j] = xs[j], xs[i] })
+	var s Sum
+	var d Dumb
+	for _, x := range xs {
+		s.Add(x)
+		d.Add(x)
+	}
+	want := s.Val()
+	got := Pairwise(xs)
+	if math.Abs(got-want) > math.Abs(want)*1e-6 {
+		t.Fatalf("expected %g, got %g", want, got)
+	}
+	if math.Abs(got-want)*10 > math.Abs(d.Val()-want) {
+		t.Fatalf("expected pairwise error %g to be smaller than naive error %g", got-want, d.Val()-want)
+	}
+}
+
+func TestPairwiseBase(t *testing.T) {
+	xs := randomFloats(rand.New(rand.NewSource(52)), 1000)
+	if got, want := PairwiseBase(xs, pairwiseBase), Pairwise(xs); got != want {
+		t.Fatalf("expected %g, got %g", want, got)
+	}
+	var naive float64
+	for _, x := range xs {
+		naive += x
+	}
+	if got := PairwiseBase(xs, len(xs)); got != naive {
+		t.Fatalf("expected the naive sum %g, got %g", naive, got)
+	}
+}
+
+func TestPairwiseParallel(t *testing.T) {
+	r := rand.New(rand.NewSource(52))
+	for _, n := range []int{0, 1, 100, 1000, 12345} {
+		xs := make([]float64, n)
+		for i := range xs {
+			xs[i] = r.NormFloat64() * math.Ldexp(1, r.Intn(40))
+		}
+		want := Pairwise(xs)
+		for _, workers := range []int{-1, 0, 1, 2, 3, 4, 7, 8, 100} {
+			if got := PairwiseParallel(xs, workers); got != want {
+				t.Fatalf("%d values, %d workers: expected %g, got %g", n, workers, want, got)
+			}
+		}
+	}
+}
+
+var sink float64
+
+var benchMillion = randomFloats(rand.New(rand.NewSource(15)), 1000000)
+
+func BenchmarkPairwise(b *testing.B) {
+	b.SetBytes(8 * int64(len(benchMillion)))
+	for i := 0; i < b.N; i++ {
+		sink = Pairwise(benchMillion)
+	}
+}
+
+func BenchmarkPairwiseDumb(b *testing.B) {
+	b.SetBytes(8 * int64(len(benchMillion)))
+	for i := 0; i < b.N; i++ {
+		var d Dumb
+		for _, x := range benchMillion {
+			d.Add(x)
+		}
+		sink = d.Val()
+	}
+}
+
+func BenchmarkPairwiseSum(b *testing.B) {
+	b.SetBytes(8 * int64(len(benchMillion)))
+	for i := 0; i < b.N; i++ {
+		var s Sum
+		for _, x := range benchMillion {
+			s.Add(x)
+		}
+		sink = s.Val()
+	}
+}
+
+func BenchmarkPairwiseParallel(b *testing.B) {
+	xs := randomFloats(rand.New(rand.NewSource(52)), 10000000)
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(strconv.Itoa(workers), func(b *testing.B) {
+			b.SetBytes(8 * int64(len(xs)))
+			for i := 0; i < b.N; i++ {
+				sink = PairwiseParallel(xs, workers)
+			}
+		})
+	}
+}
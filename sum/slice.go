@@ -0,0 +1,114 @@
+package sum
+
+import (
+	"context"
+	"iter"
+	"math/big"
+	"sync"
+)
+
+// sums holds reusable accumulators, so ExactSlice does not allocate ~24Kb on every call.
+var sums = sync.Pool{
+	New: func() any { return new(Sum) },
+}
+
+// ExactSlice returns the sum of xs as float64.
+// Same as adding all xs to a new Sum and calling Val.
+func ExactSlice(xs []float64) float64 {
+	a := sums.Get().(*Sum)
+	defer putSum(a)
+	a.AddAll(xs)
+	return a.Val()
+}
+
+// ExactSliceBig returns the sum of xs as (sum *big.Float, isNan bool) pair.
+// Same as adding all xs to a new Sum and calling BigVal.
+func ExactSliceBig(xs []float64) (*big.Float, bool) {
+	a := sums.Get().(*Sum)
+	defer putSum(a)
+	a.AddAll(xs)
+	return a.BigVal()
+}
+
+// parChunk is the smallest chunk ExactSlicePar gives to a goroutine: smaller ones are not worth the Merge.
+const parChunk = 1 << 14
+
+// ExactSlicePar is ExactSlice that sums xs up in parallel: the slice is split into up to workers chunks,
+// each summed up by its own goroutine into its own Sum, then the sums are merged.
+// The result is identical to ExactSlice, including the NaN payload.
+// If workers <= 1, or xs is too short to split, sums up on the calling goroutine.
+func ExactSlicePar(xs []float64, workers int) float64 {
+	workers = min(workers, (len(xs)+parChunk-1)/parChunk)
+	if workers <= 1 {
+		return ExactSlice(xs)
+	}
+	parts := make([]*Sum, workers)
+	chunk := (len(xs) + workers - 1) / workers
+	var wg sync.WaitGroup
+	for i := range parts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a := sums.Get().(*Sum)
+			a.AddAll(xs[i*chunk : min((i+1)*chunk, len(xs))])
+			parts[i] = a
+		}()
+	}
+	wg.Wait()
+	// Merging in order keeps the first NaN.
+	a := parts[0]
+	defer putSum(a)
+	for _, b := range parts[1:] {
+		a.Merge(b)
+		putSum(b)
+	}
+	return a.Val()
+}
+
+// CumSum returns the prefix sums of xs: out[i] is the sum of xs[:i+1], as ExactSlice would return it.
+// Unlike a running float64 sum, the error does not accumulate along the series: every prefix is rounded once.
+// Once an inf or a NaN comes along, it stays in the following prefixes, as in Sum.Val.
+// Calls Val for every element, so it is much slower than ExactSlice.
+func CumSum(xs []float64) []float64 {
+	a := sums.Get().(*Sum)
+	defer putSum(a)
+	out := make([]float64, len(xs))
+	for i, x := range xs {
+		a.Add(x)
+		out[i] = a.Val()
+	}
+	return out
+}
+
+// SumSeq returns the sum of the values in seq as float64, see ExactSlice.
+func SumSeq(seq iter.Seq[float64]) float64 {
+	a := sums.Get().(*Sum)
+	defer putSum(a)
+	for x := range seq {
+		a.Add(x)
+	}
+	return a.Val()
+}
+
+// SumChan returns the sum of the values received from ch as float64, see ExactSlice.
+// Sums up until ch is closed or ctx is done, in the latter case returns the sum so far and ctx.Err().
+func SumChan(ctx context.Context, ch <-chan float64) (float64, error) {
+	a := sums.Get().(*Sum)
+	defer putSum(a)
+	for {
+		select {
+		case <-ctx.Done():
+			return a.Val(), ctx.Err()
+		case x, ok := <-ch:
+			if !ok {
+				return a.Val(), nil
+			}
+			a.Add(x)
+		}
+	}
+}
+
+func putSum(a *Sum) {
+	a.Reset()
+	sums.Put(a)
+}
@@ -0,0 +1,177 @@
+package sum
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestExactSlice(t *testing.T) {
+	r := rand.New(rand.NewSource(16))
+	for _, in := range [][]float64{
+		nil,
+		{math.Inf(1)},
+		{math.NaN()},
+		{math.Inf(-1), 0},
+		{math.Inf(1), math.Inf(-1)},
+		{math.NaN(), 0, 1, 2, 3, 4},
+		{2e100, math.SmallestNonzeroFloat64, math.SmallestNonzeroFloat64, -1e100, -1e100},
+		{eps, 1000, 1000, 1000, 1000, 1000, -5000},
+		randomFloats(r, 10000),
+	} {
+		var a Sum
+		for _, x := range in {
+			a.Add(x)
+		}
+		if got, want := ExactSlice(in), a.Val(); math.Float64bits(got) != math.Float64bits(want) {
+			t.Fatalf("%v: expected %g, got %g", in, want, got)
+		}
+		got, gotNaN := ExactSliceBig(in)
+		want, wantNaN := a.BigVal()
+		if gotNaN != wantNaN || !gotNaN && got.Cmp(want) != 0 {
+			t.Fatalf("%v: expected %v, got %v", in, want, got)
+		}
+	}
+}
+
+func TestCumSum(t *testing.T) {
+	r := rand.New(rand.NewSource(598))
+	for _, in := range [][]float64{
+		{},
+		{math.Inf(1), 1},
+		{1, math.NaN(), 2, math.Inf(1)},
+		{1, math.Inf(1), math.Inf(-1), 0},
+		{2e100, math.SmallestNonzeroFloat64, math.SmallestNonzeroFloat64, -1e100, -1e100},
+		{eps, 1000, 1000, 1000, 1000, 1000, -5000},
+		randomFloats(r, 1000),
+	} {
+		out := CumSum(in)
+		if len(out) != len(in) || out == nil {
+			t.Fatalf("%v: expected %d prefix sums, got %v", in, len(in), out)
+		}
+		for i, got := range out {
+			if want := ExactSlice(in[:i+1]); math.Float64bits(got) != math.Float64bits(want) {
+				t.Fatalf("%v: expected %g at %d, got %g", in, want, i, got)
+			}
+		}
+	}
+	// A running float64 sum drifts.
+	xs := make([]float64, 100000)
+	for i := range xs {
+		xs[i] = 0.1
+	}
+	out := CumSum(xs)
+	naive := 0.0
+	for _, x := range xs {
+		naive += x
+	}
+	if out[len(out)-1] != 10000 || naive == 10000 {
+		t.Fatalf("expected 10000, got %g (naive %g)", out[len(out)-1], naive)
+	}
+}
+
+func TestSumSeq(t *testing.T) {
+	r := rand.New(rand.NewSource(63))
+	for _, in := range [][]float64{
+		nil,
+		{math.Inf(1), math.Inf(-1)},
+		{eps, 1000, 1000, 1000, 1000, 1000, -5000},
+		randomFloats(r, 10000),
+	} {
+		got, want := SumSeq(slices.Values(in)), ExactSlice(in)
+		if got != want && !(math.IsNaN(got) && math.IsNaN(want)) {
+			t.Fatalf("%.3v: expected %g, got %g", in, want, got)
+		}
+	}
+}
+
+func TestSumChan(t *testing.T) {
+	in := randomFloats(rand.New(rand.NewSource(63)), 10000)
+	ch := make(chan float64)
+	go func() {
+		for _, x := range in {
+			ch <- x
+		}
+		close(ch)
+	}()
+	got, err := SumChan(context.Background(), ch)
+	if want := ExactSlice(in); err != nil || got != want {
+		t.Fatalf("expected %g, got %g, %v", want, got, err)
+	}
+}
+
+func TestSumChanCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan float64)
+	go func() {
+		ch <- 1
+		ch <- 0x1p-60
+		cancel()
+		// Nobody receives after the cancellation.
+	}()
+	got, err := SumChan(ctx, ch)
+	if err != context.Canceled || got != 1+0x1p-60 {
+		t.Fatalf("expected the partial sum and %v, got %g, %v", context.Canceled, got, err)
+	}
+}
+
+func TestExactSlicePar(t *testing.T) {
+	r := rand.New(rand.NewSource(587))
+	xs := randomFloats(r, 100000)
+	nans := append(slices.Clone(xs), math.Float64frombits(0x7ff8000000000002))
+	nans[60000] = math.Float64frombits(0x7ff8000000000001)
+	for _, tc := range []struct {
+		name string
+		xs   []float64
+	}{
+		{"empty", nil},
+		{"tiny", xs[:10]},
+		{"random", xs},
+		{"inf", append(slices.Clone(xs), math.Inf(-1))},
+		{"infs", append([]float64{math.Inf(1)}, append(slices.Clone(xs), math.Inf(-1))...)},
+		// The first NaN wins, whichever chunk it is in.
+		{"nan", nans},
+	} {
+		want := ExactSlice(tc.xs)
+		for _, workers := range []int{-1, 0, 1, 2, 3, 8, 100} {
+			if got := ExactSlicePar(tc.xs, workers); math.Float64bits(got) != math.Float64bits(want) {
+				t.Fatalf("%s, %d workers: expected %g, got %g", tc.name, workers, want, got)
+			}
+		}
+	}
+}
+
+func BenchmarkExactSlicePar(b *testing.B) {
+	xs := randomFloats(rand.New(rand.NewSource(587)), 10000000)
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(strconv.Itoa(workers), func(b *testing.B) {
+			b.SetBytes(8 * int64(len(xs)))
+			for i := 0; i < b.N; i++ {
+				sink = ExactSlicePar(xs, workers)
+			}
+		})
+	}
+}
+
+var benchSmall = randomFloats(rand.New(rand.NewSource(16)), 100)
+
+func BenchmarkExactSlice(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sink = ExactSlice(benchSmall)
+	}
+}
+
+func BenchmarkExactSliceNoPool(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sumSink = &Sum{}
+		for _, x := range benchSmall {
+			sumSink.Add(x)
+		}
+		sink = sumSink.Val()
+	}
+}
@@ -0,0 +1,162 @@
+package sum
+
+import (
+	"math"
+	"math/big"
+	"slices"
+)
+
+// SparseSum sums float64 numbers up exactly like Sum does, but stores only the populated exponent bins.
+// Most real data touches a narrow band of exponents, so SparseSum is usually much smaller than Sum
+// (~80 bytes plus 16 bytes per populated bin instead of ~24Kb), at the cost of slower Add.
+// Produces results identical to Sum, including the first NaN's payload and, with PreserveSignedZero, -0.
+type SparseSum struct {
+	bins      []sparseBin // Sorted by exp.
+	count     int         // Number of finite summands, including zeroes.
+	plusInfs  int         // Number of +infs among summands.
+	minusInfs int         // Number of -infs among summands.
+	nans      int         // Number of NaNs among sumands.
+	negZeros  int         // Number of -0s among summands.
+	nanBits   uint64      // Bits of the first NaN among summands.
+
+	// PreserveSignedZero makes the sum follow IEEE rules for signed zeroes, see Sum.PreserveSignedZero.
+	PreserveSignedZero bool
+}
+
+// sparseBin is a single Sum bin.
+type sparseBin struct {
+	lo  uint64
+	hi  int32
+	exp uint16
+}
+
+// Add a float64 value to the sum.
+func (a *SparseSum) Add(v float64) {
+	b := math.Float64bits(v)
+	sign := b >> 63
+	b &= ^uint64(1 << 63)
+	exp := b >> mantissaBits
+	mantissa := b & (1<<mantissaBits - 1)
+	switch exp {
+	case 0:
+		// Subnormals and signed zeroes.
+		a.count++
+		if mantissa == 0 {
+			if sign != 0 {
+				a.negZeros++
+			}
+			return
+		}
+	case 1<<exponentBits - 1:
+		if mantissa != 0 {
+			if a.nans == 0 {
+				a.nanBits = math.Float64bits(v)
+			}
+			a.nans++
+			return
+		}
+		if sign == 0 {
+			a.plusInfs++
+			return
+		}
+		a.minusInfs++
+		return
+	default:
+		a.count++
+		mantissa |= 1 << mantissaBits // implicit bit.
+	}
+	bin := a.bin(uint16(exp))
+	prev := bin.lo
+	if sign == 0 {
+		bin.lo += mantissa
+		if bin.lo < prev {
+			a.addHi(uint16(exp), 1)
+		}
+		return
+	}
+	bin.lo -= mantissa
+	if bin.lo > prev {
+		a.addHi(uint16(exp), -1)
+	}
+}
+
+// addHi adds h*2^64 to the bin exp, carrying the high part to the bin exp+64 if it would overflow, see Sum.carry.
+// May insert bins, so pointers to the bins are not valid after it.
+func (a *SparseSum) addHi(exp uint16, h int64) {
+	bin := a.bin(exp)
+	hi := int64(bin.hi) + h
+	if hi == int64(int32(hi)) {
+		bin.hi = int32(hi)
+		return
+	}
+	bin.hi = 0
+	to := max(exp, 1) + 64 // Bin 0 has the same scale as bin 1.
+	if to > topBin {
+		if a.nans == 0 {
+			a.nanBits = math.Float64bits(math.NaN())
+		}
+		a.nans++
+		return
+	}
+	bin = a.bin(to)
+	prev := bin.lo
+	bin.lo += uint64(hi)
+	switch {
+	case hi > 0 && bin.lo < prev:
+		a.addHi(to, 1)
+	case hi < 0 && bin.lo > prev:
+		a.addHi(to, -1)
+	}
+}
+
+// bin returns the bin for exp, inserting an empty one if needed.
+func (a *SparseSum) bin(exp uint16) *sparseBin {
+	i, found := slices.BinarySearchFunc(a.bins, exp, func(b sparseBin, exp uint16) int {
+		return int(b.exp) - int(exp)
+	})
+	if !found {
+		a.bins = slices.Insert(a.bins, i, sparseBin{exp: exp})
+	}
+	return &a.bins[i]
+}
+
+// Count returns the number of finite values added to the sum, including zeroes.
+func (a *SparseSum) Count() int {
+	return a.count
+}
+
+// Val returns the current sum as float64, see Sum.Val.
+func (a *SparseSum) Val() float64 {
+	v, nan := a.BigVal()
+	if nan {
+		if a.nans > 0 {
+			return math.Float64frombits(a.nanBits)
+		}
+		return math.NaN()
+	}
+	f, _ := v.Float64()
+	return f
+}
+
+// BigVal returns the current sum as (sum *big.Float, isNan bool) pair, see Sum.BigVal.
+func (a *SparseSum) BigVal() (*big.Float, bool) {
+	if a.nans > 0 || a.plusInfs != 0 && a.minusInfs != 0 {
+		return nil, true
+	}
+	if a.minusInfs != 0 {
+		return big.NewFloat(math.Inf(-1)), false
+	}
+	if a.plusInfs != 0 {
+		return big.NewFloat(math.Inf(1)), false
+	}
+	if a.PreserveSignedZero && a.negZeros != 0 && a.negZeros == a.count {
+		return big.NewFloat(math.Copysign(0, -1)), false
+	}
+	v := &big.Int{}
+	var t scratch
+	for _, b := range a.bins {
+		t.addBin(v, int(b.exp), b.hi, b.lo)
+	}
+	f := new(big.Float).SetInt(v)
+	return f.SetMantExp(f, minExp), false
+}
@@ -0,0 +1,162 @@
+package sum
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"unsafe"
+)
+
+func TestSparseSum(t *testing.T) {
+	r := rand.New(rand.NewSource(10))
+	for k := 0; k < 20; k++ {
+		xs := randomFloats(r, 1000)
+		for i := 0; i < 5000; i++ {
+			xs = append(xs, math.MaxFloat64, -1.5, 1, -math.SmallestNonzeroFloat64, 0)
+		}
+		r.Shuffle(len(xs), func(i, j int) { xs[i], xs[j] = xs[j], xs[i] })
+		var a Sum
+		var s SparseSum
+		for _, x := range xs {
+			a.AddTracked(x)
+			s.Add(x)
+		}
+		want, _ := a.BigVal()
+		got, _ := s.BigVal()
+		if got.Cmp(want) != 0 || got.Prec() != want.Prec() {
+			t.Fatalf("expected %s, got %s", want.String(), got.String())
+		}
+		if s.Val() != a.Val() || s.Count() != a.Count() {
+			t.Fatalf("expected %g (%d summands), got %g (%d summands)", a.Val(), a.Count(), s.Val(), s.Count())
+		}
+	}
+}
+
+func TestSparseSumInfs(t *testing.T) {
+	for _, in := range [][]float64{
+		{math.Inf(1)},
+		{math.NaN()},
+		{math.Inf(-1), 0},
+		{math.Inf(1), math.Inf(-1)},
+		{math.NaN(), 0, 1, 2, 3, 4},
+		{math.Copysign(0, -1)},
+		{math.Copysign(0, -1), math.Copysign(0, -1)},
+		{math.Copysign(0, -1), 0},
+		{math.Float64frombits(0x7ff8000000000123), math.NaN(), 1},
+		{math.Inf(1), math.Float64frombits(0xfff0000000000001)},
+		{},
+	} {
+		for _, signed := range []bool{false, true} {
+			a := Sum{PreserveSignedZero: signed}
+			s := SparseSum{PreserveSignedZero: signed}
+			for _, x := range in {
+				a.AddTracked(x)
+				s.Add(x)
+			}
+			if math.Float64bits(a.Val()) != math.Float64bits(s.Val()) {
+				t.Fatalf("%v, PreserveSignedZero %t: expected %g, got %g", in, signed, a.Val(), s.Val())
+			}
+		}
+	}
+}
+
+func TestSparseSumBinOverflow(t *testing.T) {
+	big1 := math.Ldexp(1-0x1p-53, 100)
+	for _, tc := range []struct {
+		hi int32
+		lo uint64
+		v  float64
+	}{
+		// A bin about to overflow, as if 2^42 values were added to it.
+		{math.MaxInt32, math.MaxUint64 - 1<<52, big1},
+		{math.MinInt32, 1 << 52, -big1},
+		// Subnormals.
+		{math.MaxInt32, math.MaxUint64, math.SmallestNonzeroFloat64},
+		{math.MinInt32, 0, -math.SmallestNonzeroFloat64},
+	} {
+		exp := math.Float64bits(math.Abs(tc.v)) >> mantissaBits
+		var a Sum
+		var s SparseSum
+		a.mantissaHi[exp], a.mantissaLo[exp] = tc.hi, tc.lo
+		*s.bin(uint16(exp)) = sparseBin{lo: tc.lo, hi: tc.hi, exp: uint16(exp)}
+		// The bin it carries to overflows as well.
+		to := max(exp, 1) + 64
+		hi, lo := int32(math.MaxInt32), uint64(math.MaxUint64)
+		if tc.hi < 0 {
+			hi, lo = math.MinInt32, 0
+		}
+		a.mantissaHi[to], a.mantissaLo[to] = hi, lo
+		*s.bin(uint16(to)) = sparseBin{lo: lo, hi: hi, exp: uint16(to)}
+		a.AddTracked(tc.v)
+		s.Add(tc.v)
+		want, _ := a.BigVal()
+		got, nan := s.BigVal()
+		if nan || got.Cmp(want) != 0 {
+			t.Fatalf("%g: expected %s, got %v", tc.v, want.String(), got)
+		}
+	}
+
+	// The top bin has nowhere to carry to.
+	var s SparseSum
+	*s.bin(topBin) = sparseBin{lo: math.MaxUint64, hi: math.MaxInt32, exp: topBin}
+	s.Add(math.MaxFloat64)
+	if !math.IsNaN(s.Val()) {
+		t.Fatalf("expected NaN, got %g", s.Val())
+	}
+}
+
+func narrowFloats(r *rand.Rand, n int) []float64 {
+	xs := make([]float64, n)
+	for i := range xs {
+		xs[i] = r.Float64()*100 + 1
+	}
+	return xs
+}
+
+var benchNarrow = narrowFloats(rand.New(rand.NewSource(10)), 1<<16)
+
+func BenchmarkSparseSumAdd(b *testing.B) {
+	b.SetBytes(8 * int64(len(benchNarrow)))
+	var a SparseSum
+	for i := 0; i < b.N; i++ {
+		for _, x := range benchNarrow {
+			a.Add(x)
+		}
+	}
+}
+
+func BenchmarkSumAdd(b *testing.B) {
+	b.SetBytes(8 * int64(len(benchNarrow)))
+	var a Sum
+	for i := 0; i < b.N; i++ {
+		for _, x := range benchNarrow {
+			a.AddTracked(x)
+		}
+	}
+}
+
+var sparseSink *SparseSum
+
+// BenchmarkSparseSumMemory reports the memory used by a SparseSum with typical inputs.
+func BenchmarkSparseSumMemory(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sparseSink = &SparseSum{}
+		for _, x := range benchNarrow[:100] {
+			sparseSink.Add(x)
+		}
+	}
+	b.ReportMetric(float64(unsafe.Sizeof(*sparseSink))+float64(cap(sparseSink.bins))*float64(unsafe.Sizeof(sparseBin{})), "bytes/sum")
+}
+
+// BenchmarkSumMemory reports the memory used by a Sum.
+func BenchmarkSumMemory(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sumSink = &Sum{}
+		for _, x := range benchNarrow[:100] {
+			sumSink.Add(x)
+		}
+	}
+	b.ReportMetric(float64(unsafe.Sizeof(*sumSink)), "bytes/sum")
+}
@@ -3,30 +3,55 @@ package sum
 import (
 	"math"
 	"math/big"
+	"math/bits"
+	"strconv"
 )
 
 const exponentBits = 11
 const mantissaBits = 64 - exponentBits - 1 // Not counting the implicit one.
 const exponentBias = 1<<(exponentBits-1) - 1
+const minExp = 1 - exponentBias - mantissaBits // Exponent of the smallest subnormal.
 
 // Sum float64 numbers up, giving the result with best precision possible.
 // Handles NaNs and infs properly, deals with catastrophic cancellation.
 // With Sum D == sum((A+B..C) + D - (A+B..C)), even if A+B..C overflows float64.
 // Addition is commutative and associative (unlike regular float64 addition).
 // It is slightly faster than Kahan, with better (best) precision.
-// Does not preserve signed zeroes: summing up single (-0) would give +0.
-// Does not propagate the exact value of a NaN: if any NaNs were encountered returns math.NaN().
-// Size is ~24Kb.
+// Does not preserve signed zeroes by default: summing up single (-0) would give +0,
+// see PreserveSignedZero.
+// If any NaNs were encountered returns the first one, keeping its payload.
+// Stays exact for any number of summands, except for more than 2^42 summands above 2^907 in magnitude
+// with the same exponent: that makes the sum NaN.
+// Size is ~24Kb: pass it by pointer, copying it by value copies all the bins, see Clone.
 type Sum struct {
 	// Sum of full mantissas (including implicit bit when appopriate).
 	mantissaLo [1 << exponentBits]uint64 // unsigned, sign is stored in hi.
 	mantissaHi [1 << exponentBits]int32  //
+	count      int                       // Number of finite summands, including zeroes, see Count.
 	plusInfs   int                       // Number of +infs among summands.
 	minusInfs  int                       // Number of -infs among summands.
 	nans       int                       // Number of NaNs among sumands.
+	negZeros   int                       // Number of -0s among summands.
+	nanBits    uint64                    // Bits of the first NaN among summands.
+	min, max   float64                   // Smallest and largest non-NaN summands.
+	abs        float64                   // Sum of the absolute values of finite summands, see Condition.
+	dec        *big.Int                  // Decimal summands in units of 10^-decScale, see AddDecimal.
+	decScale   int                       //
+
+	// PreserveSignedZero makes the sum follow IEEE rules for signed zeroes:
+	// the sum is -0 if all the summands were -0, +0 otherwise.
+	// Add does not count the summands, use AddTracked.
+	PreserveSignedZero bool
+	// TrackMinMax makes the sum keep track of the smallest and the largest summands, see Min and Max.
+	// Add does not look at it, use AddTracked.
+	TrackMinMax bool
+	// TrackCondition makes the sum keep track of the sum of absolute values of the summands, see Condition.
+	// Add does not look at it, use AddTracked.
+	TrackCondition bool
 }
 
 // Add a float64 value to the sum.
+// Does not count finite values or look at the options, to keep the common path fast, see AddTracked.
 func (a *Sum) Add(v float64) {
 	b := math.Float64bits(v)
 	if b == 0 {
@@ -43,14 +68,14 @@ func (a *Sum) Add(v float64) {
 			new := prev + mantissa
 			a.mantissaLo[exp] = new
 			if new < prev {
-				a.mantissaHi[exp]++
+				a.incHi(exp)
 			}
 			return
 		}
 		new := prev - mantissa
 		a.mantissaLo[exp] = new
 		if a.mantissaLo[exp] > prev {
-			a.mantissaHi[exp]--
+			a.decHi(exp)
 		}
 		return
 	}
@@ -79,7 +104,7 @@ func (a *Sum) Add(v float64) {
 			return
 		}
 		// NaNs.
-		a.nans++
+		a.addNaN(v)
 		return
 	}
 	// Subnormals: add full mantissa.
@@ -88,28 +113,667 @@ func (a *Sum) Add(v float64) {
 		new := prev + mantissa
 		a.mantissaLo[exp] = new
 		if new < prev {
-			a.mantissaHi[exp]++
+			a.incHi(exp)
 		}
 		return
 	}
 	new := prev - mantissa
 	a.mantissaLo[exp] = new
 	if a.mantissaLo[exp] > prev {
-		a.mantissaHi[exp]--
+		a.decHi(exp)
+	}
+}
+
+// AddTracked adds a float64 value to the sum, like Add, counts it if it is finite (see Count and PreserveSignedZero),
+// and updates min, max and the sum of absolute values with it, see TrackMinMax and TrackCondition.
+// The other methods that add values (AddAll, AddWeighted, AddBig...) count and track them as well.
+func (a *Sum) AddTracked(v float64) {
+	a.track(v)
+	b := math.Float64bits(v)
+	switch b >> mantissaBits & (1<<exponentBits - 1) {
+	case 1<<exponentBits - 1:
+		// Add counts infs and NaNs.
+	case 0:
+		// Zeroes and subnormals.
+		if b == 1<<63 {
+			a.negZeros++
+		}
+		a.count++
+	default:
+		a.count++
+	}
+	a.Add(v)
+}
+
+// track updates min, max and the sum of absolute values with a summand v, see TrackMinMax and TrackCondition.
+// Must be called before v is counted.
+func (a *Sum) track(v float64) {
+	if math.IsNaN(v) {
+		return
+	}
+	if a.TrackMinMax {
+		a.minMax(v)
+	}
+	if a.TrackCondition && !math.IsInf(v, 0) {
+		a.abs += math.Abs(v)
+	}
+}
+
+// incHi increments the high part of the bin exp, carrying the bin first if the high part would overflow.
+func (a *Sum) incHi(exp uint64) {
+	if a.mantissaHi[exp] == math.MaxInt32 {
+		a.carry(exp)
+	}
+	a.mantissaHi[exp]++
+}
+
+// decHi decrements the high part of the bin exp, see incHi.
+func (a *Sum) decHi(exp uint64) {
+	if a.mantissaHi[exp] == math.MinInt32 {
+		a.carry(exp)
+	}
+	a.mantissaHi[exp]--
+}
+
+// carry moves the high part of the bin exp to the bin exp+64:
+// hi*2^64 in the bin exp is the same as hi in the bin exp+64.
+// It takes more than 2^42 summands in the same bin to get there.
+// The top 64 bins have nowhere to carry to, their overflow makes the sum NaN.
+func (a *Sum) carry(exp uint64) {
+	hi := a.mantissaHi[exp]
+	a.mantissaHi[exp] = 0
+	a.carryHi(exp, int64(hi))
+}
+
+// addHi adds h*2^64 to the bin exp, carrying the bin if the high part would overflow, see carry.
+func (a *Sum) addHi(exp uint64, h int64) {
+	hi := int64(a.mantissaHi[exp]) + h
+	if hi == int64(int32(hi)) {
+		a.mantissaHi[exp] = int32(hi)
+		return
+	}
+	a.mantissaHi[exp] = 0
+	a.carryHi(exp, hi)
+}
+
+// carryHi adds hi*2^64 in the bin exp to the bin exp+64, see carry.
+// |hi| must be at most 2^63.
+func (a *Sum) carryHi(exp uint64, hi int64) {
+	to := max(exp, 1) + 64 // Bin 0 has the same scale as bin 1.
+	if to > topBin {
+		a.addNaN(math.NaN())
+		return
+	}
+	prev := a.mantissaLo[to]
+	new := prev + uint64(hi)
+	a.mantissaLo[to] = new
+	switch {
+	case hi > 0 && new < prev:
+		a.incHi(to)
+	case hi < 0 && new > prev:
+		a.decHi(to)
+	}
+}
+
+// AddAll adds all the values in xs to the sum.
+// Same as calling AddTracked for each value, but faster.
+func (a *Sum) AddAll(xs []float64) {
+	if a.TrackMinMax || a.TrackCondition {
+		for _, v := range xs {
+			a.AddTracked(v)
+		}
+		return
+	}
+	lo := &a.mantissaLo
+	count := a.count
+	for _, v := range xs {
+		b := math.Float64bits(v)
+		exp := b >> mantissaBits & (1<<exponentBits - 1)
+		if exp == 0 || exp == 1<<exponentBits-1 {
+			// Zeroes, subnormals, infs and NaNs.
+			a.count = count
+			a.AddTracked(v)
+			count = a.count
+			continue
+		}
+		count++
+		// Add ±mantissa as a 96 bit number without branching on the sign:
+		// for negative v, it is (2^64 - mantissa) in lo and -1 in hi.
+		neg := b >> 63
+		mantissa := b&(1<<mantissaBits-1) | 1<<mantissaBits
+		new, carry := bits.Add64(lo[exp], (mantissa^-neg)+neg, 0)
+		lo[exp] = new
+		if carry != neg {
+			if carry != 0 {
+				a.incHi(exp)
+			} else {
+				a.decHi(exp)
+			}
+		}
+	}
+	a.count = count
+}
+
+// minMax updates min and max with a non-NaN summand v. Must be called before v is counted.
+func (a *Sum) minMax(v float64) {
+	if a.count+a.plusInfs+a.minusInfs == 0 {
+		a.min, a.max = v, v
+		return
+	}
+	if v < a.min {
+		a.min = v
+	}
+	if v > a.max {
+		a.max = v
+	}
+}
+
+// addNaN counts a NaN summand, remembering the first one.
+func (a *Sum) addNaN(v float64) {
+	if a.nans == 0 {
+		a.nanBits = math.Float64bits(v)
+	}
+	a.nans++
+}
+
+// AddBig adds a big.Float value to the sum.
+// Unlike Add(v.Float64()), keeps all the bits of v down to the smallest subnormal float64:
+// the lower bits are rounded to the nearest, ties away from zero.
+// Values too large for float64 (|v| >= 2^1024) are added as infs.
+func (a *Sum) AddBig(v *big.Float) {
+	if v.IsInf() {
+		a.AddTracked(math.Inf(v.Sign()))
+		return
+	}
+	if v.Sign() == 0 {
+		z := 0.0
+		if v.Signbit() {
+			z = math.Copysign(0, -1)
+		}
+		a.AddTracked(z)
+		return
+	}
+	mant := new(big.Float)
+	exp := v.MantExp(mant)
+	if exp > exponentBias+1 {
+		a.AddTracked(math.Inf(v.Sign()))
+		return
+	}
+	if a.TrackMinMax || a.TrackCondition {
+		f, _ := v.Float64()
+		if a.TrackMinMax {
+			a.minMax(f)
+		}
+		if a.TrackCondition {
+			a.abs += math.Abs(f)
+		}
+	}
+	a.count++
+	// v == m * 2^(exp-prec), where m is an integer.
+	prec := int(mant.MinPrec())
+	m, _ := mant.SetMantExp(mant, prec).Int(nil)
+	shift(m, exp-prec-minExp)
+	a.addScaledInt(m)
+}
+
+// AddN adds v to the sum k times in O(1): the same as calling AddTracked(v) k times.
+// The mantissa is multiplied by k and the 117 bit product goes to the bin, carrying as needed.
+// The counters (including infs and NaNs) grow by k, they wrap around if k does not fit into an int.
+func (a *Sum) AddN(v float64, k uint64) {
+	if k == 0 {
+		return
+	}
+	// AddTracked handles min, max and the NaN payload, the rest is k-1 more of the same.
+	a.AddTracked(v)
+	k--
+	b := math.Float64bits(v)
+	neg := b>>63 != 0
+	b &= ^uint64(1 << 63)
+	exp := b >> mantissaBits
+	mantissa := b & (1<<mantissaBits - 1)
+	switch {
+	case exp == 1<<exponentBits-1 && mantissa != 0:
+		a.nans += int(k)
+		return
+	case exp == 1<<exponentBits-1 && neg:
+		a.minusInfs += int(k)
+		return
+	case exp == 1<<exponentBits-1:
+		a.plusInfs += int(k)
+		return
+	case b == 0 && neg:
+		a.negZeros += int(k)
+	}
+	a.count += int(k)
+	if b == 0 {
+		return
+	}
+	if exp != 0 {
+		mantissa |= 1 << mantissaBits // implicit bit.
+	}
+	if a.TrackCondition {
+		a.abs += float64(k) * math.Abs(v)
 	}
+	hi, lo := bits.Mul64(mantissa, k)
+	a.addBin(int(exp), neg, hi, lo)
+}
+
+// intBin is the bin of the integers: its unit is 2^(intBin-1+minExp) == 1.
+const intBin = 1 - minExp
+
+// AddFloat32 adds a float32 value to the sum, same as AddTracked(float64(v)), but faster:
+// a normal float32 goes to the bin of the float64 it converts to directly.
+func (a *Sum) AddFloat32(v float32) {
+	b := math.Float32bits(v)
+	exp := b >> mantissaBits32 & (1<<exponentBits32 - 1)
+	if exp == 0 || exp == 1<<exponentBits32-1 || a.TrackMinMax || a.TrackCondition {
+		// Zeroes, subnormals (which are normal as float64), infs and NaNs.
+		a.AddTracked(float64(v))
+		return
+	}
+	a.count++
+	mantissa := uint64(b&(1<<mantissaBits32-1)|1<<mantissaBits32) << (mantissaBits - mantissaBits32)
+	e := uint64(exp) + exponentBias - exponentBias32
+	prev := a.mantissaLo[e]
+	if b>>31 == 0 {
+		new := prev + mantissa
+		a.mantissaLo[e] = new
+		if new < prev {
+			a.incHi(e)
+		}
+		return
+	}
+	new := prev - mantissa
+	a.mantissaLo[e] = new
+	if new > prev {
+		a.decHi(e)
+	}
+}
+
+// AddInt adds an integer to the sum exactly, unlike Add(float64(i)), which rounds |i| > 2^53.
+// The integer goes to the bins as is, so the sum is Equal to the one with Add(float64(i)) only by value, see Cmp.
+// Min and Max (see TrackMinMax) see float64(i).
+func (a *Sum) AddInt(i int64) {
+	if i < 0 {
+		a.addInt(true, -uint64(i))
+		return
+	}
+	a.addInt(false, uint64(i))
+}
+
+// AddUint adds an unsigned integer to the sum exactly, see AddInt.
+func (a *Sum) AddUint(u uint64) {
+	a.addInt(false, u)
+}
+
+// addInt adds ±u to the sum.
+func (a *Sum) addInt(neg bool, u uint64) {
+	if a.TrackMinMax {
+		v := float64(u)
+		if neg {
+			v = -v
+		}
+		a.minMax(v)
+	}
+	if a.TrackCondition {
+		a.abs += float64(u)
+	}
+	a.count++
+	a.addBin(intBin, neg, 0, u)
+}
+
+// Add2 adds hi and lo to the sum, e.g. the parts returned by TwoSum or TwoProduct.
+// Since the sum is exact, Add2(hi, lo) is the same as AddTracked(hi) followed by AddTracked(lo): both count as summands.
+// See AddWeighted to add a product as a single summand.
+func (a *Sum) Add2(hi, lo float64) {
+	a.AddTracked(hi)
+	a.AddTracked(lo)
+}
+
+// AddSquared adds v*v to the sum, see AddWeighted.
+func (a *Sum) AddSquared(v float64) {
+	a.AddWeighted(v, v)
+}
+
+// AddWeighted adds w*x to the sum.
+// The product is split with TwoProduct into hi+lo and both parts go to the bins,
+// so the sum of products is exact, unless w*x overflows (then an inf is added)
+// or it is below 2^-969, in which case the bits beyond the smallest subnormal float64 are rounded.
+// Zeros, infs and NaNs multiply as in float64: 0*Inf adds a NaN.
+// Counts as a single summand.
+func (a *Sum) AddWeighted(w, x float64) {
+	hi, lo := TwoProduct(w, x)
+	a.AddTracked(hi)
+	if lo == 0 || math.IsInf(hi, 0) || math.IsNaN(hi) {
+		return
+	}
+	// lo is a part of the same summand: it goes straight to its bin,
+	// without affecting the counters, min/max or the condition.
+	b := math.Float64bits(lo)
+	exp := b >> mantissaBits & (1<<exponentBits - 1)
+	mantissa := b & (1<<mantissaBits - 1)
+	if exp != 0 {
+		mantissa |= 1 << mantissaBits // implicit bit.
+	}
+	a.addBin(int(exp), lo < 0, 0, mantissa)
+}
+
+// Merge adds all the values accumulated in b to a.
+// After Merge a is the same as if every value added to b was added to a directly.
+// Useful for summing up in parallel: each goroutine uses its own Sum, then the partial sums are merged.
+// If a tracks min and max (or the condition) and b does not, a does not know them anymore:
+// Min and Max (or Condition) return NaN until Reset.
+func (a *Sum) Merge(b *Sum) {
+	for i := range a.mantissaLo {
+		lo, carry := bits.Add64(a.mantissaLo[i], b.mantissaLo[i], 0)
+		a.mantissaLo[i] = lo
+		if b.mantissaHi[i] != 0 || carry != 0 {
+			a.addHi(uint64(i), int64(b.mantissaHi[i])+int64(carry))
+		}
+	}
+	if a.nans == 0 {
+		a.nanBits = b.nanBits
+	}
+	if a.TrackMinMax && b.count+b.plusInfs+b.minusInfs != 0 {
+		if b.TrackMinMax {
+			a.minMax(b.min)
+			a.minMax(b.max)
+		} else {
+			// b's extremes are unknown, so are a's from now on.
+			a.min, a.max = math.NaN(), math.NaN()
+		}
+	}
+	if a.TrackCondition && !b.TrackCondition && b.count != 0 {
+		a.abs = math.NaN()
+	}
+	a.abs += b.abs
+	if b.hasDecimal() {
+		a.addDecimal(new(big.Int).Set(b.dec), b.decScale)
+	}
+	a.count += b.count
+	a.plusInfs += b.plusInfs
+	a.minusInfs += b.minusInfs
+	a.nans += b.nans
+	a.negZeros += b.negZeros
+}
+
+// Equal reports whether a and b accumulated the same values: compares the bins and the counters.
+// Since the bins are exact, the result does not depend on the order the values were added in:
+// any permutation of the same values gives Equal sums, as long as no bin carried over to a higher one
+// (that takes more than 2^42 summands with the same exponent).
+// Does not compare the options, min and max, the sum of absolute values, or NaN payloads,
+// as those may depend on the order. Compares the decimal summands by value, see AddDecimal.
+func (a *Sum) Equal(b *Sum) bool {
+	return a.mantissaLo == b.mantissaLo && a.mantissaHi == b.mantissaHi &&
+		a.count == b.count && a.plusInfs == b.plusInfs && a.minusInfs == b.minusInfs &&
+		a.nans == b.nans && a.negZeros == b.negZeros &&
+		(!a.hasDecimal() && !b.hasDecimal() || a.decimal().Cmp(b.decimal()) == 0)
+}
+
+// Reset sets the sum to zero, so the accumulator can be reused without allocating a new one.
+// Keeps the options: PreserveSignedZero, TrackMinMax and TrackCondition.
+func (a *Sum) Reset() {
+	clear(a.mantissaLo[:])
+	clear(a.mantissaHi[:])
+	a.count = 0
+	a.plusInfs = 0
+	a.minusInfs = 0
+	a.nans = 0
+	a.negZeros = 0
+	a.nanBits = 0
+	a.min = 0
+	a.max = 0
+	a.abs = 0
+	if a.dec != nil {
+		a.dec.SetInt64(0)
+	}
+	a.decScale = 0
+}
+
+// Clone returns an independent copy of a, including the options.
+func (a *Sum) Clone() *Sum {
+	c := *a
+	if a.dec != nil {
+		c.dec = new(big.Int).Set(a.dec)
+	}
+	return &c
+}
+
+// Count returns the number of finite values added to the sum, including zeroes.
+// Values added with Add are not counted, see AddTracked.
+// Together with Val it gives the mean: a.Val()/float64(a.Count()).
+func (a *Sum) Count() int {
+	return a.count
+}
+
+// PlusInfs returns the number of +infs added to the sum.
+func (a *Sum) PlusInfs() int {
+	return a.plusInfs
+}
+
+// MinusInfs returns the number of -infs added to the sum.
+func (a *Sum) MinusInfs() int {
+	return a.minusInfs
+}
+
+// NaNs returns the number of NaNs added to the sum.
+func (a *Sum) NaNs() int {
+	return a.nans
+}
+
+// Min returns the smallest value added to the sum, ignoring NaNs. Follows IEEE comparison: -0 == +0.
+// Returns NaN if no values other than NaNs were added, or if TrackMinMax is not set.
+// Does not see the values added with Add, see AddTracked. Min is not affected by Scale.
+func (a *Sum) Min() float64 {
+	if !a.TrackMinMax || a.count+a.plusInfs+a.minusInfs == 0 {
+		return math.NaN()
+	}
+	return a.min
+}
+
+// Max returns the largest value added to the sum, see Min.
+func (a *Sum) Max() float64 {
+	if !a.TrackMinMax || a.count+a.plusInfs+a.minusInfs == 0 {
+		return math.NaN()
+	}
+	return a.max
 }
 
 // Val returns the current sum as float64.
+// If there were NaNs among the summands, returns the first of them.
+// If the sum is NaN because of (+Inf) + (-Inf), returns math.NaN().
 func (a *Sum) Val() float64 {
 	v, nan := a.BigVal()
 	if nan {
+		if a.nans > 0 {
+			return math.Float64frombits(a.nanBits)
+		}
 		return math.NaN()
 	}
+	if a.hasDecimal() && a.class() == classFinite {
+		r, _ := a.ExactRat()
+		f, _ := r.Float64()
+		return f
+	}
 	f, _ := v.Float64()
 	return f
 }
 
-// BigVal returns the current sum as (sum *big.Float, isNan bool) pair
+// ValAcc returns Val together with the accuracy of the rounding, as big.Float.Float64 does:
+// big.Below if Val is less than the exact sum, big.Above if it is greater, big.Exact otherwise.
+// A finite sum that overflows float64 is ±Inf with big.Above (for +Inf) or big.Below (for -Inf).
+// If the sum is ±Inf because of inf summands, or NaN, the accuracy is big.Exact.
+func (a *Sum) ValAcc() (float64, big.Accuracy) {
+	if a.class() != classFinite || a.negZero() {
+		return a.Val(), big.Exact
+	}
+	return a.exact().Float64()
+}
+
+// negZero reports whether the sum is -0 with PreserveSignedZero.
+func (a *Sum) negZero() bool {
+	return a.PreserveSignedZero && a.negZeros != 0 && a.negZeros == a.count
+}
+
+// ValWithBound returns the current sum as float64 together with an upper bound on the rounding error:
+// |val - exact sum| <= absErr.
+// The bound is 0 if the exact sum is representable as float64.
+// If the exact sum is finite, but overflows float64, val is ±Inf and absErr is +Inf.
+// If there are infs among the summands, absErr is 0; if the sum is NaN, both are NaN.
+func (a *Sum) ValWithBound() (val float64, absErr float64) {
+	if a.nans != 0 || a.plusInfs != 0 || a.minusInfs != 0 {
+		v := a.Val()
+		if math.IsNaN(v) {
+			return v, v
+		}
+		return v, 0
+	}
+	if a.negZero() {
+		return math.Copysign(0, -1), 0
+	}
+	e := a.exact()
+	val, _ = e.Float64()
+	if math.IsInf(val, 0) {
+		return val, math.Inf(1)
+	}
+	e.Sub(e, new(big.Float).SetFloat64(val))
+	absErr, acc := e.Abs(e).Float64()
+	if acc == big.Below {
+		absErr = math.Nextafter(absErr, math.Inf(1))
+	}
+	return val, absErr
+}
+
+// Condition returns an estimate of the condition number of the sum: sum(|x|) / |sum(x)|.
+// Close to 1 means there was little cancellation, float64 summation in any order would be accurate.
+// A large condition number means there was a lot of cancellation: naive summation loses
+// about log2(Condition) bits.
+// Returns 1 for a sum of zeros, +Inf if non-zero summands cancelled out to 0.
+// Returns NaN if TrackCondition is not set or the sum is not finite.
+// Does not see the values added with Add, see AddTracked.
+// The sum of absolute values is accumulated in float64, so it is approximate and may overflow to +Inf.
+func (a *Sum) Condition() float64 {
+	if !a.TrackCondition || a.class() != classFinite {
+		return math.NaN()
+	}
+	if a.abs == 0 {
+		return 1
+	}
+	return a.abs / math.Abs(a.Val())
+}
+
+// ValOverflow returns Val, and whether it is ±Inf because the exact sum, while finite, is out of the float64 range.
+// overflowed is false if the sum is ±Inf because of an inf summand, or NaN.
+func (a *Sum) ValOverflow() (v float64, overflowed bool) {
+	v = a.Val()
+	return v, math.IsInf(v, 0) && a.class() == classFinite
+}
+
+// ValRounded is Val, with the exact sum rounded to float64 according to mode, e.g.
+// ValRounded(big.ToNegativeInf) <= exact sum <= ValRounded(big.ToPositiveInf).
+// Like IEEE 754, rounding toward zero never overflows: a finite sum beyond the float64 range
+// is rounded to ±math.MaxFloat64 by the modes that round it toward zero, to ±Inf by the others.
+// Val is ValRounded(big.ToNearestEven).
+func (a *Sum) ValRounded(mode big.RoundingMode) float64 {
+	if a.class() != classFinite || a.negZero() {
+		return a.Val()
+	}
+	// The sum is a multiple of the smallest subnormal, so it only needs rounding
+	// where float64 has the full precision.
+	r := new(big.Float).SetPrec(mantissaBits + 1).SetMode(mode).Set(a.exact())
+	f, _ := r.Float64()
+	if math.IsInf(f, 0) && (mode == big.ToZero || mode == big.ToNegativeInf && f > 0 || mode == big.ToPositiveInf && f < 0) {
+		return math.Copysign(math.MaxFloat64, f)
+	}
+	return f
+}
+
+// RSub returns v - sum, rounded to float64 once.
+// Unlike v - a.Val(), the sum is not rounded first, so the result is accurate even if v is close to the sum,
+// e.g. for the residuals of iterative refinement.
+// Infs and NaNs follow the float64 rules for v - a.Val().
+func (a *Sum) RSub(v float64) float64 {
+	d, nan := a.RSubBig(v)
+	if nan {
+		return v - a.Val()
+	}
+	f, _ := d.Float64()
+	return f
+}
+
+// RSubBig returns v - sum as (diff *big.Float, isNan bool) pair, see BigVal.
+// The finite difference is exact.
+func (a *Sum) RSubBig(v float64) (*big.Float, bool) {
+	if a.class() != classFinite || math.IsInf(v, 0) || math.IsNaN(v) {
+		return bigVal(v - a.Val())
+	}
+	// Both v and the sum are multiples of 2^minExp, see SplitVal.
+	h := new(big.Float).SetFloat64(v)
+	h.SetMantExp(h, -minExp)
+	x, _ := h.Int(nil)
+	x.Sub(x, a.scaledInt())
+	if x.Sign() == 0 {
+		// v equals the sum, the float64 difference is exact and has the IEEE sign of zero.
+		return big.NewFloat(v - a.Val()), false
+	}
+	d := new(big.Float).SetInt(x)
+	return d.SetMantExp(d, minExp), false
+}
+
+// SplitVal returns the current sum as hi + residual, where hi is Val and residual is exact:
+// the part of the sum that does not fit into hi.
+// residual is 0 if the sum is representable as float64.
+// If the sum is ±Inf or NaN, or overflows float64, residual is nil.
+func (a *Sum) SplitVal() (hi float64, residual *big.Float) {
+	hi = a.Val()
+	if a.class() != classFinite || math.IsInf(hi, 0) {
+		return hi, nil
+	}
+	// Both the sum and hi are multiples of 2^minExp, so is the difference.
+	h := new(big.Float).SetFloat64(hi)
+	h.SetMantExp(h, -minExp)
+	hInt, _ := h.Int(nil)
+	n := a.scaledInt()
+	residual = new(big.Float).SetInt(n.Sub(n, hInt))
+	return hi, residual.SetMantExp(residual, minExp)
+}
+
+// String implements fmt.Stringer.
+// Returns the value, marked as exact or approximate, e.g. "42.5 (exact)" or "0.1 (approx)".
+// If there were infs or NaNs among the summands, reports their counts instead, e.g. "NaN (3 nan summands)".
+func (a *Sum) String() string {
+	v, absErr := a.ValWithBound()
+	b := make([]byte, 0, 64)
+	b = strconv.AppendFloat(b, v, 'g', -1, 64)
+	switch {
+	case a.nans != 0:
+		b = append(b, " ("...)
+		b = strconv.AppendInt(b, int64(a.nans), 10)
+		b = append(b, " nan summands)"...)
+	case a.plusInfs != 0 || a.minusInfs != 0:
+		b = append(b, " ("...)
+		if a.plusInfs != 0 {
+			b = strconv.AppendInt(b, int64(a.plusInfs), 10)
+			b = append(b, " +inf"...)
+		}
+		if a.plusInfs != 0 && a.minusInfs != 0 {
+			b = append(b, ", "...)
+		}
+		if a.minusInfs != 0 {
+			b = strconv.AppendInt(b, int64(a.minusInfs), 10)
+			b = append(b, " -inf"...)
+		}
+		b = append(b, " summands)"...)
+	case absErr == 0:
+		b = append(b, " (exact)"...)
+	default:
+		b = append(b, " (approx)"...)
+	}
+	return string(b)
+}
+
+// BigVal returns the current sum as (sum *big.Float, isNan bool) pair.
+// The finite sum is exact.
 func (a *Sum) BigVal() (*big.Float, bool) {
 	if a.nans > 0 {
 		return nil, true
@@ -125,43 +789,338 @@ func (a *Sum) BigVal() (*big.Float, bool) {
 	if a.plusInfs != 0 {
 		return big.NewFloat(math.Inf(1)), false
 	}
-	var q bfAdder
-	// end at exponentBits-1 to ignore nans and infs which were handled above.
-	for i := 0; i < 1<<exponentBits-1; i++ {
-		sign := 1.0
-		hi := a.mantissaHi[i]
-		lo := a.mantissaLo[i]
-		if lo == 0 && hi == 0 {
-			continue
-		}
-		if hi < 0 {
-			sign = -1
-			hi = -hi
-			hi--
-			lo = -lo
+	if a.negZero() {
+		return big.NewFloat(math.Copysign(0, -1)), false
+	}
+	return a.exact(), false
+}
+
+// Cmp compares the exact values of the sums and returns:
+//
+//	-1 if a <  b
+//	 0 if a == b
+//	+1 if a >  b
+//
+// NaNs are ordered after everything else, including +Inf, and are equal to each other.
+func (a *Sum) Cmp(b *Sum) int {
+	ca, cb := a.class(), b.class()
+	switch {
+	case ca < cb:
+		return -1
+	case ca > cb:
+		return 1
+	case ca != classFinite:
+		return 0
+	case a.mantissaLo == b.mantissaLo && a.mantissaHi == b.mantissaHi:
+		return 0
+	}
+	return a.scaledInt().Cmp(b.scaledInt())
+}
+
+// Classes of sums, in Cmp order.
+const (
+	classMinusInf = iota
+	classFinite
+	classPlusInf
+	classNaN
+)
+
+func (a *Sum) class() int {
+	switch {
+	case a.nans != 0 || a.plusInfs != 0 && a.minusInfs != 0:
+		return classNaN
+	case a.plusInfs != 0:
+		return classPlusInf
+	case a.minusInfs != 0:
+		return classMinusInf
+	}
+	return classFinite
+}
+
+// IsNaN reports whether the sum is NaN: there was a NaN summand, or both +Inf and -Inf.
+func (a *Sum) IsNaN() bool {
+	return a.class() == classNaN
+}
+
+// IsInf reports whether the sum is an infinity, according to sign.
+// If sign > 0, IsInf reports whether the sum is +Inf.
+// If sign < 0, IsInf reports whether the sum is -Inf.
+// If sign == 0, IsInf reports whether the sum is either infinity.
+func (a *Sum) IsInf(sign int) bool {
+	switch a.class() {
+	case classPlusInf:
+		return sign >= 0
+	case classMinusInf:
+		return sign <= 0
+	}
+	return false
+}
+
+// Sign returns -1, 0 or +1 depending on the sign of the exact sum.
+// Does not round the sum to float64, so it is cheaper than checking the sign of Val.
+// Returns +1 for +Inf, -1 for -Inf and 0 for NaN.
+func (a *Sum) Sign() int {
+	switch a.class() {
+	case classPlusInf:
+		return 1
+	case classMinusInf:
+		return -1
+	case classNaN:
+		return 0
+	}
+	return a.scaledInt().Sign()
+}
+
+// ExactRat returns the current sum as (sum *big.Rat, isNan bool) pair.
+// Unlike BigVal, the result is exact, including the decimal summands, see AddDecimal.
+// Infs can not be represented as big.Rat: if the sum is ±Inf, returns (nil, false).
+func (a *Sum) ExactRat() (*big.Rat, bool) {
+	if a.nans > 0 || a.plusInfs != 0 && a.minusInfs != 0 {
+		return nil, true
+	}
+	if a.plusInfs != 0 || a.minusInfs != 0 {
+		return nil, false
+	}
+	d := new(big.Int).Lsh(big.NewInt(1), -minExp)
+	r := new(big.Rat).SetFrac(a.scaledInt(), d)
+	if a.hasDecimal() {
+		r.Add(r, a.decimal())
+	}
+	return r, false
+}
+
+// BigInt returns the current sum as (sum *big.Int, ok bool) pair.
+// ok is false (and sum is nil) if the sum is not an integer, or is NaN or ±Inf.
+func (a *Sum) BigInt() (*big.Int, bool) {
+	if a.class() != classFinite {
+		return nil, false
+	}
+	x := a.scaledInt()
+	if x.Sign() != 0 && x.TrailingZeroBits() < -minExp {
+		return nil, false
+	}
+	return x.Rsh(x, -minExp), true
+}
+
+// Int64 returns the current sum as (sum int64, ok bool) pair.
+// ok is false if the sum is not an integer, does not fit into int64, or is NaN or ±Inf.
+// Unlike int64(a.Val()), does not round.
+func (a *Sum) Int64() (int64, bool) {
+	x, ok := a.BigInt()
+	if !ok || !x.IsInt64() {
+		return 0, false
+	}
+	return x.Int64(), true
+}
+
+// Scale multiplies the sum by c.
+// The result is exact, unless it has bits below the smallest subnormal: those are rounded to the nearest,
+// ties away from zero.
+// In particular, scaling by a power of two is exact as long as it does not underflow.
+// Follows IEEE rules for the infs: 0*Inf and Inf*0 make the sum NaN, negative c flips the signs of infs.
+// With PreserveSignedZero, a zero result is -0 if the signs of the sum and of c differ, +0 otherwise.
+// If the exact result is too large for the bins (~2^1066), it becomes ±Inf.
+func (a *Sum) Scale(c float64) {
+	a.abs *= math.Abs(c)
+	if math.IsNaN(c) {
+		a.addNaN(c)
+		return
+	}
+	x := a.scaledInt()
+	// A zero result is negative if the signs of the sum and of c differ, as in IEEE.
+	neg := (x.Sign() < 0 || x.Sign() == 0 && a.negZero()) != math.Signbit(c)
+	if c == 0 {
+		if a.plusInfs != 0 || a.minusInfs != 0 {
+			a.addNaN(math.NaN())
 		}
-		exp := uint64(i)
-		if exp == 0 {
-			exp = 1 // Handling subnormals
+		clear(a.mantissaLo[:])
+		clear(a.mantissaHi[:])
+		a.signZero(neg)
+		return
+	}
+	if c < 0 {
+		a.plusInfs, a.minusInfs = a.minusInfs, a.plusInfs
+		x.Neg(x)
+		c = -c
+	}
+	if math.IsInf(c, 1) {
+		clear(a.mantissaLo[:])
+		clear(a.mantissaHi[:])
+		switch {
+		case a.plusInfs != 0 || a.minusInfs != 0:
+			// Already infinite.
+		case x.Sign() == 0:
+			a.addNaN(math.NaN())
+		case x.Sign() > 0:
+			a.plusInfs++
+		default:
+			a.minusInfs++
 		}
-		mantissa := lo & (1<<mantissaBits - 1)
-		if mantissa != 0 {
-			// ints between -2^(mantissaBits+1) and 2^(mantissaBits+1) can be represented as floats.
-			u := big.NewFloat(float64(mantissa) * sign)
-			u.SetMantExp(u, int(exp)-exponentBias-mantissaBits)
-			q.Add(u)
+		return
+	}
+	frac, exp := math.Frexp(c)
+	x.Mul(x, big.NewInt(int64(math.Ldexp(frac, mantissaBits+1))))
+	shift(x, exp-mantissaBits-1)
+	if x.BitLen() > maxScaledBits {
+		if x.Sign() > 0 {
+			a.plusInfs++
+		} else {
+			a.minusInfs++
 		}
+		x.SetInt64(0)
+	}
+	a.setScaledInt(x)
+	if x.Sign() == 0 {
+		a.signZero(neg)
+	}
+}
 
-		mantissa = lo >> (mantissaBits)
-		mantissa |= uint64(hi) << (64 - mantissaBits)
+// signZero makes a zero sum -0 if neg, +0 otherwise, when PreserveSignedZero is set.
+// A sum with no summands stays +0.
+func (a *Sum) signZero(neg bool) {
+	if !a.PreserveSignedZero || a.class() != classFinite {
+		return
+	}
+	if neg {
+		a.negZeros = a.count
+	} else {
+		a.negZeros = 0
+	}
+}
+
+// Bits needed to represent the bins of Sum, see scaledInt:
+// the top bin is scaled by 2^(topBin-1) and holds 95 bits.
+const topBin = 1<<exponentBits - 2
+const maxScaledBits = topBin - 1 + 95
+
+// setScaledInt sets the finite part of the sum to x*2^minExp.
+// |x| must be less than 2^maxScaledBits.
+func (a *Sum) setScaledInt(x *big.Int) {
+	clear(a.mantissaLo[:])
+	clear(a.mantissaHi[:])
+	a.addScaledInt(x)
+}
+
+// addScaledInt adds x*2^minExp to the finite part of the sum.
+// |x| must be less than 2^maxScaledBits.
+func (a *Sum) addScaledInt(x *big.Int) {
+	neg := x.Sign() < 0
+	m := new(big.Int).Abs(x)
+	top := new(big.Int).Rsh(m, topBin-1)
+	m.Sub(m, new(big.Int).Lsh(top, topBin-1))
+	mask := new(big.Int).SetUint64(math.MaxUint64)
+	w := &big.Int{}
+	a.addBin(topBin, neg, new(big.Int).Rsh(top, 64).Uint64(), w.And(top, mask).Uint64())
+	// Bin i is scaled by 2^(i-1), so the k-th 64-bit word goes to bin 64k+1.
+	for i := 1; m.Sign() != 0; i += 64 {
+		a.addBin(i, neg, 0, w.And(m, mask).Uint64())
+		m.Rsh(m, 64)
+	}
+}
 
-		if mantissa != 0 {
-			u := big.NewFloat(float64(mantissa) * sign)
-			u.SetMantExp(u, int(exp)-exponentBias)
-			q.Add(u)
+// addBin adds ±(hi*2^64 + lo) to the bin i.
+// hi must be less than 2^63.
+func (a *Sum) addBin(i int, neg bool, hi, lo uint64) {
+	if neg {
+		l, borrow := bits.Sub64(a.mantissaLo[i], lo, 0)
+		a.mantissaLo[i] = l
+		a.addHi(uint64(i), -int64(hi+borrow))
+		return
+	}
+	l, carry := bits.Add64(a.mantissaLo[i], lo, 0)
+	a.mantissaLo[i] = l
+	a.addHi(uint64(i), int64(hi+carry))
+}
+
+// shift multiplies x by 2^n, rounding to the nearest integer (ties away from zero) if n < 0.
+func shift(x *big.Int, n int) {
+	if n >= 0 {
+		x.Lsh(x, uint(n))
+		return
+	}
+	neg := x.Sign() < 0
+	x.Abs(x)
+	x.Add(x, new(big.Int).Lsh(big.NewInt(1), uint(-n-1)))
+	x.Rsh(x, uint(-n))
+	if neg {
+		x.Neg(x)
+	}
+}
+
+// scaledInt returns the exact finite part of the sum multiplied by 2^-minExp.
+// Ignores infs and nans.
+func (a *Sum) scaledInt() *big.Int {
+	v := &big.Int{}
+	var t scratch
+	for i := 0; i < 1<<exponentBits-1; i++ {
+		t.addBin(v, i, a.mantissaHi[i], a.mantissaLo[i])
+	}
+	return v
+}
+
+// Histogram returns the value of every non-empty bin by its exponent, for diagnostics:
+// the net contribution of the summands in [2^exp, 2^(exp+1)) in magnitude, with their signs.
+// Bin -1023 holds the subnormals. A bin that overflows carries into the bin 64 exponents higher,
+// and AddInt adds to the bin 52 whatever the magnitude of the integer.
+// Shows where the mass of the sum is, e.g. a few large terms that cancel out, and make the sum ill-conditioned.
+// The values are rounded to float64, a bin of many huge summands may be ±Inf.
+// Infs and NaNs are not included, see PlusInfs, MinusInfs and NaNs.
+func (a *Sum) Histogram() map[int]float64 {
+	h := make(map[int]float64)
+	var t scratch
+	v := &big.Int{}
+	f := &big.Float{}
+	for i := 0; i < 1<<exponentBits-1; i++ {
+		if a.mantissaLo[i] == 0 && a.mantissaHi[i] == 0 {
+			continue
+		}
+		t.addBin(v.SetInt64(0), i, a.mantissaHi[i], a.mantissaLo[i])
+		f.SetInt(v)
+		h[i-exponentBias], _ = f.SetMantExp(f, minExp).Float64()
+	}
+	return h
+}
+
+// PopulatedBins returns the number of non-empty bins, the ones Histogram reports:
+// about the number of bins a SparseSum of the same summands would take, to choose between the two.
+// Summands with the same exponent share a bin, so it is a handful for data in a narrow range.
+// A bin whose summands cancel out exactly is empty again.
+func (a *Sum) PopulatedBins() int {
+	n := 0
+	for i := 0; i < 1<<exponentBits-1; i++ {
+		if a.mantissaLo[i] != 0 || a.mantissaHi[i] != 0 {
+			n++
 		}
 	}
-	return q.BigVal(), false
+	return n
+}
+
+// scratch holds temporaries for adding bins to a big.Int.
+type scratch struct {
+	bin, lo big.Int
+}
+
+// addBin adds the value of the bin number i, scaled by 2^-minExp, to v.
+func (t *scratch) addBin(v *big.Int, i int, hi int32, lo uint64) {
+	if lo == 0 && hi == 0 {
+		return
+	}
+	exp := i
+	if exp == 0 {
+		exp = 1 // Handling subnormals
+	}
+	t.bin.SetInt64(int64(hi))
+	t.bin.Lsh(&t.bin, 64)
+	t.bin.Add(&t.bin, t.lo.SetUint64(lo))
+	t.bin.Lsh(&t.bin, uint(exp-1))
+	v.Add(v, &t.bin)
+}
+
+// exact returns the exact finite part of the sum as big.Float.
+// Ignores infs and nans.
+func (a *Sum) exact() *big.Float {
+	f := new(big.Float).SetInt(a.scaledInt())
+	return f.SetMantExp(f, minExp)
 }
 
 // Kahan implements a reasonably robust summation algorithm, see
@@ -184,63 +1143,163 @@ func (k Kahan) Val() float64 {
 	return k.s
 }
 
-// bfAdder uses big.Floats and exponent binning.
-// Handles cancellation.
-type bfAdder struct {
-	nonneg []*big.Float // bin == exponent
-	neg    []*big.Float // bin == -exponent+1
+// BigVal returns the current sum, see BigSummer.
+// It is Val as a big.Float, Kahan is no more accurate than that.
+func (k Kahan) BigVal() (*big.Float, bool) {
+	return bigVal(k.s)
 }
 
-func (b *bfAdder) Add(v *big.Float) {
-	exp := v.MantExp(nil)
-	p := &b.nonneg
-	bin := exp
-	if exp < 0 {
-		p = &b.neg
-		bin = -bin + 1
-	}
-	for len(*p) < bin+1 {
-		*p = append(*p, &big.Float{})
-	}
-	a := *p
-	a[bin].Add(a[bin], v)
-	exp1 := a[bin].MantExp(nil)
+// Reset sets the sum to zero.
+func (k *Kahan) Reset() {
+	*k = Kahan{}
+}
+
+// Merge adds the partial sum o to k, keeping both compensations.
+// Like Kahan itself, the result is approximate, but it is more accurate than
+// adding the values of the partial sums.
+func (k *Kahan) Merge(o Kahan) {
+	hi, lo := TwoSum(k.s, o.s)
+	k.s = hi
+	k.c += o.c - lo
+	// Fold the compensation into s, so Val sees it.
+	k.Add(0)
+}
 
-	if exp1 != exp {
-		b.Add(a[bin])
-		a[bin].SetFloat64(0)
+// AsSum returns a new Sum with the current value of k, including the compensation k.Val does not see:
+// to switch from Kahan to Sum mid-stream.
+// The sum has 2 summands (or 1, if there is no compensation), see Count.
+func (k Kahan) AsSum() *Sum {
+	a := new(Sum)
+	a.AddTracked(k.s)
+	if k.c != 0 {
+		a.AddTracked(-k.c)
 	}
+	return a
 }
 
-func (b bfAdder) BigVal() *big.Float {
-	var sum bigKahan // Using Kahan here is an overkill, but does not hurt.
-	for i := range b.nonneg {
-		sum.Add(b.nonneg[len(b.nonneg)-i-1])
+// AsKahan returns a Kahan with the current value of the sum, to continue with the cheaper summation.
+// The part of the sum that does not fit into Val is rounded to float64 and kept as the compensation
+// (see SplitVal), so the accuracy is not lost at the handoff:
+// it shows up in Kahan.Val once enough summands are added on top.
+// Like Kahan itself, does not handle infs and NaNs.
+func (a *Sum) AsKahan() Kahan {
+	hi, residual := a.SplitVal()
+	k := Kahan{s: hi}
+	if residual != nil {
+		r, _ := residual.Float64()
+		k.c = -r // Kahan keeps the negated low part.
 	}
-	for _, x := range b.neg {
-		sum.Add(x)
+	return k
+}
+
+// Neumaier implements Kahan-Babuska-Neumaier summation, see
+// https://en.wikipedia.org/wiki/Kahan_summation_algorithm#Further_enhancements
+// Unlike Kahan, handles summands larger than the running sum.
+// Note: does not handle infs properly.
+type Neumaier struct {
+	s, c float64
+}
+
+// Add v to the sum.
+func (n *Neumaier) Add(v float64) {
+	t := n.s + v
+	if math.Abs(n.s) >= math.Abs(v) {
+		n.c += (n.s - t) + v
+	} else {
+		n.c += (v - t) + n.s
 	}
+	n.s = t
+}
+
+// Val return the current sum.
+func (n Neumaier) Val() float64 {
+	return n.s + n.c
+}
+
+// BigVal returns the current sum, see BigSummer.
+func (n Neumaier) BigVal() (*big.Float, bool) {
+	return bigVal(n.Val())
+}
 
-	return sum.BigVal()
+// Reset sets the sum to zero.
+func (n *Neumaier) Reset() {
+	*n = Neumaier{}
 }
 
-// bigKahan: kahan using big.Float.
-type bigKahan struct {
-	s, c big.Float
+// SortedKahan is Neumaier for the values sorted by magnitude, largest first:
+// the running sum is then at least as large as the next value, so the rounding error of every step
+// is exactly FastTwoSum's lo, and there is no need to compare the magnitudes.
+// It gives the same result as Neumaier as long as the running sum stays at least as large as the next value,
+// which holds for sorted values of the same sign. Otherwise the steps where it does not hold lose
+// a part of their rounding error, and the accuracy degrades towards Kahan's, rather than falling apart.
+// Note: does not handle infs properly.
+type SortedKahan struct {
+	s, c float64
+}
+
+// Add v to the sum. |v| should not exceed the magnitude of the sum so far.
+func (k *SortedKahan) Add(v float64) {
+	hi, lo := FastTwoSum(k.s, v)
+	k.s = hi
+	k.c += lo
+}
+
+// Val return the current sum.
+func (k SortedKahan) Val() float64 {
+	return k.s + k.c
+}
+
+// BigVal returns the current sum, see BigSummer.
+func (k SortedKahan) BigVal() (*big.Float, bool) {
+	return bigVal(k.Val())
+}
+
+// Reset sets the sum to zero.
+func (k *SortedKahan) Reset() {
+	*k = SortedKahan{}
+}
+
+// Klein implements the second-order Kahan-Babuska summation by Klein, see
+// https://en.wikipedia.org/wiki/Kahan_summation_algorithm#Further_enhancements
+// Like Neumaier, but the compensation has a compensation of its own,
+// so it stays accurate when the first-order compensation loses bits too,
+// e.g. in long sums with a wide dynamic range.
+// In the order of accuracy: Dumb < Kahan < Neumaier < Klein < Sum, which is exact.
+// Note: does not handle infs properly.
+type Klein struct {
+	s, cs, ccs float64
 }
 
 // Add v to the sum.
-func (k *bigKahan) Add(v *big.Float) {
-	y := &big.Float{}
-	y.Sub(v, &k.c)
-	t := &big.Float{}
-	t.Add(&k.s, y)
-	k.c.Sub(t, &k.s)
-	k.c.Sub(&k.c, y)
-	k.s = *t
+func (k *Klein) Add(v float64) {
+	t := k.s + v
+	var c float64
+	if math.Abs(k.s) >= math.Abs(v) {
+		c = (k.s - t) + v
+	} else {
+		c = (v - t) + k.s
+	}
+	k.s = t
+	t = k.cs + c
+	if math.Abs(k.cs) >= math.Abs(c) {
+		k.ccs += (k.cs - t) + c
+	} else {
+		k.ccs += (c - t) + k.cs
+	}
+	k.cs = t
 }
 
 // Val return the current sum.
-func (k bigKahan) BigVal() *big.Float {
-	return &k.s
+func (k Klein) Val() float64 {
+	return k.s + k.cs + k.ccs
+}
+
+// BigVal returns the current sum, see BigSummer.
+func (k Klein) BigVal() (*big.Float, bool) {
+	return bigVal(k.Val())
+}
+
+// Reset sets the sum to zero.
+func (k *Klein) Reset() {
+	*k = Klein{}
 }
@@ -0,0 +1,121 @@
+package sum
+
+import (
+	"math"
+	"math/big"
+)
+
+const exponentBits32 = 8
+const mantissaBits32 = 32 - exponentBits32 - 1 // Not counting the implicit one.
+const exponentBias32 = 1<<(exponentBits32-1) - 1
+const minExp32 = 1 - exponentBias32 - mantissaBits32 // Exponent of the smallest subnormal.
+
+// Sum32 sums float32 numbers up the same way Sum does for float64.
+// Works on float32 bits directly, without converting to float64.
+// Size is ~3Kb.
+type Sum32 struct {
+	// Sum of full mantissas (including implicit bit when appopriate).
+	mantissaLo [1 << exponentBits32]uint64 // unsigned, sign is stored in hi.
+	mantissaHi [1 << exponentBits32]int32  //
+	count      int                         // Number of finite summands, including zeroes.
+	plusInfs   int                         // Number of +infs among summands.
+	minusInfs  int                         // Number of -infs among summands.
+	nans       int                         // Number of NaNs among sumands.
+}
+
+// Add a float32 value to the sum.
+func (a *Sum32) Add(v float32) {
+	b := math.Float32bits(v)
+	if b == 0 {
+		a.count++
+		return
+	}
+	sign := b >> 31
+	b &= ^uint32(1 << 31)
+	exp := b >> mantissaBits32
+	mantissa := uint64(b & (1<<mantissaBits32 - 1))
+	switch exp {
+	case 0:
+		// Subnormals and signed zeroes.
+		a.count++
+		if mantissa == 0 {
+			return
+		}
+	case 1<<exponentBits32 - 1:
+		if mantissa != 0 {
+			a.nans++
+			return
+		}
+		if sign == 0 {
+			a.plusInfs++
+			return
+		}
+		a.minusInfs++
+		return
+	default:
+		a.count++
+		mantissa |= 1 << mantissaBits32 // implicit bit.
+	}
+	prev := a.mantissaLo[exp]
+	if sign == 0 {
+		new := prev + mantissa
+		a.mantissaLo[exp] = new
+		if new < prev {
+			a.mantissaHi[exp]++
+		}
+		return
+	}
+	new := prev - mantissa
+	a.mantissaLo[exp] = new
+	if new > prev {
+		a.mantissaHi[exp]--
+	}
+}
+
+// Count returns the number of finite values added to the sum, including zeroes.
+func (a *Sum32) Count() int {
+	return a.count
+}
+
+// Val returns the current sum as float32.
+func (a *Sum32) Val() float32 {
+	v, nan := a.BigVal()
+	if nan {
+		return float32(math.NaN())
+	}
+	f, _ := v.Float32()
+	return f
+}
+
+// BigVal returns the current sum as (sum *big.Float, isNan bool) pair.
+// The sum is exact.
+func (a *Sum32) BigVal() (*big.Float, bool) {
+	if a.nans > 0 || a.plusInfs != 0 && a.minusInfs != 0 {
+		return nil, true
+	}
+	if a.minusInfs != 0 {
+		return big.NewFloat(math.Inf(-1)), false
+	}
+	if a.plusInfs != 0 {
+		return big.NewFloat(math.Inf(1)), false
+	}
+	v := &big.Int{}
+	bin := &big.Int{}
+	lo := &big.Int{}
+	for i := 0; i < 1<<exponentBits32-1; i++ {
+		if a.mantissaLo[i] == 0 && a.mantissaHi[i] == 0 {
+			continue
+		}
+		exp := i
+		if exp == 0 {
+			exp = 1 // Handling subnormals
+		}
+		bin.SetInt64(int64(a.mantissaHi[i]))
+		bin.Lsh(bin, 64)
+		bin.Add(bin, lo.SetUint64(a.mantissaLo[i]))
+		bin.Lsh(bin, uint(exp-1))
+		v.Add(v, bin)
+	}
+	f := new(big.Float).SetInt(v)
+	return f.SetMantExp(f, minExp32), false
+}
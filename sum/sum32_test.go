@@ -0,0 +1,146 @@
+package sum
+
+import (
+	"math"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+func randomFloats32(r *rand.Rand, n int) []float32 {
+	xs := make([]float32, n)
+	for i := range xs {
+		x := float32(math.Ldexp(r.Float64(), r.Intn(277)-150))
+		if r.Intn(2) == 0 {
+			x = -x
+		}
+		xs[i] = x
+	}
+	return xs
+}
+
+func TestSum32(t *testing.T) {
+	r := rand.New(rand.NewSource(6))
+	for k := 0; k < 10; k++ {
+		xs := randomFloats32(r, 10000)
+		xs = append(xs, math.SmallestNonzeroFloat32, -math.MaxFloat32, math.MaxFloat32, 1, -1)
+		var a Sum32
+		var b Sum
+		want := new(big.Float).SetPrec(1024)
+		for _, x := range xs {
+			a.Add(x)
+			b.AddTracked(float64(x))
+			want.Add(want, big.NewFloat(float64(x)))
+		}
+		got, nan := a.BigVal()
+		if nan || got.Cmp(want) != 0 {
+			t.Fatalf("expected %s, got %s", want.String(), got.String())
+		}
+		w, _ := want.Float32()
+		if a.Val() != w {
+			t.Fatalf("expected %g, got %g", w, a.Val())
+		}
+		if a.Count() != len(xs) || b.Count() != len(xs) {
+			t.Fatalf("expected %d summands, got %d", len(xs), a.Count())
+		}
+	}
+}
+
+func TestAddFloat32(t *testing.T) {
+	r := rand.New(rand.NewSource(592))
+	xs := randomFloats32(r, 100000) // Includes subnormals.
+	nan := math.Float32frombits(0x7fc00123)
+	xs = append(xs, 0, float32(math.Copysign(0, -1)), math.SmallestNonzeroFloat32, -math.MaxFloat32, math.MaxFloat32,
+		float32(math.Inf(1)), float32(math.Inf(-1)), nan)
+	var a, b Sum
+	for _, x := range xs {
+		a.AddFloat32(x)
+		b.AddTracked(float64(x))
+	}
+	if !a.Equal(&b) {
+		t.Fatalf("expected %v, got %v", &b, &a)
+	}
+	if math.Float64bits(a.Val()) != math.Float64bits(b.Val()) {
+		t.Fatalf("expected %x, got %x", math.Float64bits(b.Val()), math.Float64bits(a.Val()))
+	}
+	// Only finite values, so the sum is not NaN.
+	a.Reset()
+	b.Reset()
+	for _, x := range xs[:len(xs)-4] {
+		a.AddFloat32(x)
+		b.AddTracked(float64(x))
+	}
+	if !a.Equal(&b) || a.Val() != b.Val() {
+		t.Fatalf("expected %v, got %v", &b, &a)
+	}
+}
+
+func TestSum32Subnormals(t *testing.T) {
+	var a Sum32
+	a.Add(2e30)
+	for i := 0; i < 100; i++ {
+		a.Add(math.SmallestNonzeroFloat32)
+	}
+	a.Add(-1e30)
+	a.Add(-1e30)
+	if a.Val() != math.SmallestNonzeroFloat32*100 {
+		t.Fatalf("expected %g, got %g", math.SmallestNonzeroFloat32*100, a.Val())
+	}
+}
+
+func TestSum32Infs(t *testing.T) {
+	inf := float32(math.Inf(1))
+	nan := float32(math.NaN())
+	for _, tc := range []struct {
+		in   []float32
+		want float64
+	}{
+		{[]float32{inf}, math.Inf(1)},
+		{[]float32{-inf, 0}, math.Inf(-1)},
+		{[]float32{math.MaxFloat32, math.MaxFloat32}, math.Inf(1)},
+		{[]float32{inf, -inf}, math.NaN()},
+		{[]float32{nan, 1}, math.NaN()},
+		{[]float32{float32(math.Copysign(0, -1))}, 0},
+	} {
+		var a Sum32
+		for _, x := range tc.in {
+			a.Add(x)
+		}
+		got := float64(a.Val())
+		if math.IsNaN(tc.want) != math.IsNaN(got) || !math.IsNaN(got) && got != tc.want {
+			t.Fatalf("%v: expected %g, got %g", tc.in, tc.want, got)
+		}
+	}
+}
+
+var benchFloats32 = randomFloats32(rand.New(rand.NewSource(6)), 1<<16)
+
+func BenchmarkSum32(b *testing.B) {
+	b.SetBytes(4 * int64(len(benchFloats32)))
+	var a Sum32
+	for i := 0; i < b.N; i++ {
+		for _, x := range benchFloats32 {
+			a.Add(x)
+		}
+	}
+}
+
+func BenchmarkSumAddFloat32(b *testing.B) {
+	b.SetBytes(4 * int64(len(benchFloats32)))
+	var a Sum
+	for i := 0; i < b.N; i++ {
+		for _, x := range benchFloats32 {
+			a.AddFloat32(x)
+		}
+	}
+}
+
+func BenchmarkSum32AsFloat64(b *testing.B) {
+	b.SetBytes(4 * int64(len(benchFloats32)))
+	var a Sum
+	for i := 0; i < b.N; i++ {
+		for _, x := range benchFloats32 {
+			a.Add(float64(x))
+		}
+	}
+}
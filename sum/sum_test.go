@@ -1,8 +1,14 @@
 package sum
 
 import (
+	"cmp"
+	"encoding/binary"
+	"fmt"
 	"math"
 	"math/big"
+	"math/rand"
+	"slices"
+	"strconv"
 	"testing"
 )
 
@@ -11,7 +17,7 @@ const eps = 1e-80 * 9.87654321
 const N = 100000
 
 func TestCancellationBF(t *testing.T) {
-	a := bfAdder{}
+	a := BigSum{}
 	for _, x := range []float64{eps, 1000, 1000, 1000, 1000, 1000, -5000} {
 		a.Add(big.NewFloat(x))
 	}
@@ -42,6 +48,67 @@ func TestCancellationKahan(t *testing.T) {
 	}
 }
 
+func TestCancellationNeumaier(t *testing.T) {
+	a := Neumaier{}
+	for _, x := range []float64{eps, 1000, 1000, 1000, 1000, 1000, -5000} {
+		a.Add(x)
+	}
+	if math.Abs(a.Val()-eps)*1000 > eps {
+		t.Fatalf("exptected %s and %s to be close", big.NewFloat(a.Val()).String(), big.NewFloat(eps).String())
+	}
+}
+
+func TestSortedKahan(t *testing.T) {
+	// Small values lost next to a large one.
+	small := []float64{0x1p53}
+	for i := 0; i < 1000; i++ {
+		small = append(small, 1-float64(i)*0x1p-20)
+	}
+	var s SortedKahan
+	var d Dumb
+	for _, x := range small {
+		s.Add(x)
+		d.Add(x)
+	}
+	if want := 0x1p53 + 1000 - 999*1000/2*0x1p-20; s.Val() != want || d.Val() == want {
+		t.Fatalf("expected %g, got %g (naive %g)", want, s.Val(), d.Val())
+	}
+	// Random signs and magnitudes, sorted by magnitude, largest first.
+	r := rand.New(rand.NewSource(595))
+	for k := 0; k < 10; k++ {
+		xs := randomFloats(r, 10000)
+		for i, x := range xs {
+			xs[i] = math.Ldexp(x, r.Intn(100)-math.Ilogb(x))
+		}
+		slices.SortFunc(xs, func(a, b float64) int { return cmp.Compare(math.Abs(b), math.Abs(a)) })
+		var s SortedKahan
+		var n Neumaier
+		for _, x := range xs {
+			s.Add(x)
+			n.Add(x)
+		}
+		if s.Val() != n.Val() {
+			t.Fatalf("expected %g, got %g", n.Val(), s.Val())
+		}
+	}
+}
+
+func TestLargeSummandNeumaier(t *testing.T) {
+	in := []float64{1, 1e100, 1, -1e100}
+	k := Kahan{}
+	n := Neumaier{}
+	for _, x := range in {
+		k.Add(x)
+		n.Add(x)
+	}
+	if k.Val() == 2 {
+		t.Fatalf("expected Kahan to lose precision")
+	}
+	if n.Val() != 2 {
+		t.Fatalf("expected 2, got %g", n.Val())
+	}
+}
+
 func TestNeg(t *testing.T) {
 	a := Sum{}
 	for _, x := range []float64{-1} {
@@ -113,6 +180,84 @@ func TestSumKahan2(t *testing.T) {
 	}
 }
 
+func TestKahanMerge(t *testing.T) {
+	r := rand.New(rand.NewSource(28))
+	xs := make([]float64, 100000)
+	exact := new(big.Rat)
+	for i := range xs {
+		xs[i] = r.Float64()*1e6 - 0.5e6 + r.NormFloat64()
+		exact.Add(exact, new(big.Rat).SetFloat64(xs[i]))
+	}
+	var merged Kahan
+	naive := 0.0
+	for i := 0; i < len(xs); i += 1000 {
+		var k Kahan
+		for _, x := range xs[i : i+1000] {
+			k.Add(x)
+		}
+		merged.Merge(k)
+		naive += k.Val()
+	}
+	absErr := func(v float64) float64 {
+		d := new(big.Rat).SetFloat64(v)
+		f, _ := d.Sub(d, exact).Float64()
+		return math.Abs(f)
+	}
+	if absErr(merged.Val()) >= absErr(naive) {
+		t.Fatalf("expected merged error %g to be below naive error %g", absErr(merged.Val()), absErr(naive))
+	}
+}
+
+func TestKahanReset(t *testing.T) {
+	var a Kahan
+	a.Add(1)
+	a.Add(1e-20)
+	a.Reset()
+	a.Add(1e-20)
+	if a.Val() != 1e-20 {
+		t.Fatalf("expected %g, got %g", 1e-20, a.Val())
+	}
+}
+
+func TestKahanConvert(t *testing.T) {
+	var a Sum
+	a.Add(1)
+	a.Add(0x1p-60)
+	a.Add(0x1p-120)
+	k := a.AsKahan()
+	if k.Val() != a.Val() {
+		t.Fatalf("expected %g, got %g", a.Val(), k.Val())
+	}
+	if k.c != -0x1p-60 {
+		t.Fatalf("expected the residual as the compensation, got %g", k.c)
+	}
+	// The residual makes it to Val once the tail adds up to half an ulp of 1.
+	plain := Kahan{s: a.Val()}
+	for i := 0; i < 128; i++ {
+		a.Add(0x1p-60)
+		k.Add(0x1p-60)
+		plain.Add(0x1p-60)
+	}
+	if k.Val() != 1+0x1p-52 || k.Val() != a.Val() {
+		t.Fatalf("expected %g, got %g", a.Val(), k.Val())
+	}
+	if plain.Val() != 1 {
+		t.Fatalf("expected the unseeded Kahan to lag behind at 1, got %g", plain.Val())
+	}
+
+	// Back to Sum, the compensation included.
+	b := k.AsSum()
+	want, _ := new(big.Float).Sub(big.NewFloat(k.s), big.NewFloat(k.c)).Float64()
+	if b.Val() != want || b.Count() != 2 {
+		t.Fatalf("expected %g of 2 summands, got %g of %d", want, b.Val(), b.Count())
+	}
+	var c Sum
+	c.AddTracked(3)
+	if got := c.AsKahan().AsSum(); !got.Equal(&c) {
+		t.Fatalf("expected %v, got %v", &c, got)
+	}
+}
+
 func TestSum(t *testing.T) {
 	a := &Sum{}
 	a.Add(17)
@@ -192,6 +337,106 @@ func TestSumInfs(t *testing.T) {
 	}
 }
 
+func TestPredicates(t *testing.T) {
+	for _, tc := range []struct {
+		in                   []float64
+		nan, plusInf, minInf bool
+		sign                 int
+	}{
+		{nil, false, false, false, 0},
+		{[]float64{math.Inf(1)}, false, true, false, 1},
+		{[]float64{math.NaN()}, true, false, false, 0},
+		{[]float64{math.Inf(-1)}, false, false, true, -1},
+		{[]float64{math.Inf(-1), 0}, false, false, true, -1},
+		{[]float64{math.Inf(1), math.Inf(1), 0}, false, true, false, 1},
+		{[]float64{math.Inf(1), math.Inf(-1)}, true, false, false, 0},
+		{[]float64{math.NaN(), 0, 1, 2, 3, 4}, true, false, false, 0},
+		{[]float64{math.NaN(), math.Inf(1)}, true, false, false, 0},
+		{[]float64{math.NaN(), math.Inf(-1)}, true, false, false, 0},
+		{[]float64{1, -1}, false, false, false, 0},
+		{[]float64{math.Copysign(0, -1)}, false, false, false, 0},
+		{[]float64{-3, 1}, false, false, false, -1},
+		// Huge summands cancel out, the sign comes from the smallest subnormal.
+		{[]float64{1e300, math.SmallestNonzeroFloat64, -1e300}, false, false, false, 1},
+		{[]float64{math.MaxFloat64, -math.SmallestNonzeroFloat64, -math.MaxFloat64}, false, false, false, -1},
+	} {
+		var a Sum
+		for _, x := range tc.in {
+			a.Add(x)
+		}
+		if a.IsNaN() != tc.nan {
+			t.Fatalf("%v: expected IsNaN to be %t", tc.in, tc.nan)
+		}
+		if a.IsInf(1) != tc.plusInf || a.IsInf(-1) != tc.minInf || a.IsInf(0) != (tc.plusInf || tc.minInf) {
+			t.Fatalf("%v: expected IsInf(1) to be %t, IsInf(-1) to be %t", tc.in, tc.plusInf, tc.minInf)
+		}
+		if a.Sign() != tc.sign {
+			t.Fatalf("%v: expected sign %d, got %d", tc.in, tc.sign, a.Sign())
+		}
+	}
+}
+
+func TestCounters(t *testing.T) {
+	for _, tc := range []struct {
+		in                               []float64
+		count, plusInfs, minusInfs, nans int
+	}{
+		{[]float64{math.Inf(1)}, 0, 1, 0, 0},
+		{[]float64{math.NaN()}, 0, 0, 0, 1},
+		{[]float64{math.Inf(-1)}, 0, 0, 1, 0},
+		{[]float64{math.Inf(-1), 0}, 1, 0, 1, 0},
+		{[]float64{math.Inf(1), math.Inf(1), 0}, 1, 2, 0, 0},
+		{[]float64{math.Inf(1), math.Inf(-1)}, 0, 1, 1, 0},
+		{[]float64{math.NaN(), 0, 1, 2, 3, 4}, 5, 0, 0, 1},
+		{[]float64{math.NaN(), math.Inf(1)}, 0, 1, 0, 1},
+		{[]float64{math.NaN(), math.Inf(-1)}, 0, 0, 1, 1},
+		{[]float64{math.Copysign(0, -1), math.SmallestNonzeroFloat64, -math.SmallestNonzeroFloat64}, 3, 0, 0, 0},
+	} {
+		var a Sum
+		for _, x := range tc.in {
+			a.AddTracked(x)
+		}
+		if a.Count() != tc.count || a.PlusInfs() != tc.plusInfs || a.MinusInfs() != tc.minusInfs || a.NaNs() != tc.nans {
+			t.Fatalf("%v: expected counters %d %d %d %d, got %d %d %d %d", tc.in,
+				tc.count, tc.plusInfs, tc.minusInfs, tc.nans,
+				a.Count(), a.PlusInfs(), a.MinusInfs(), a.NaNs())
+		}
+	}
+}
+
+func TestCountSubnormals(t *testing.T) {
+	a := Sum{}
+	a.AddTracked(2e100)
+	for i := 0; i < 100; i++ {
+		a.AddTracked(math.SmallestNonzeroFloat64)
+	}
+	a.AddTracked(-1e100)
+	a.AddTracked(-1e100)
+	if a.Count() != 103 {
+		t.Fatalf("expected 103 summands, got %d", a.Count())
+	}
+	if mean := a.Val() / float64(a.Count()); mean != math.SmallestNonzeroFloat64*100/103 {
+		t.Fatalf("expected mean %g, got %g", math.SmallestNonzeroFloat64*100/103, mean)
+	}
+}
+
+func TestAddUntracked(t *testing.T) {
+	a := Sum{TrackMinMax: true, TrackCondition: true}
+	for _, x := range []float64{3, 0, math.SmallestNonzeroFloat64, -1} {
+		a.Add(x)
+	}
+	a.Add(math.Inf(1))
+	if a.Count() != 0 || a.PlusInfs() != 1 || !math.IsInf(a.Val(), 1) {
+		t.Fatalf("expected Add to count only the infs, got %d summands and %d +infs", a.Count(), a.PlusInfs())
+	}
+	a.Reset()
+	a.Add(3)
+	a.AddTracked(-1)
+	if a.Val() != 2 || a.Count() != 1 || a.Min() != -1 || a.Max() != -1 {
+		t.Fatalf("expected 2 with 1 tracked summand -1, got %g with %d in [%g, %g]", a.Val(), a.Count(), a.Min(), a.Max())
+	}
+}
+
 func TestSum0(t *testing.T) {
 	a := &Sum{}
 	a.Add(17)
@@ -216,126 +461,1708 @@ func TestSumNeg0(t *testing.T) {
 	}
 }
 
-func TestSumBF(t *testing.T) {
-	a := bfAdder{}
-	a.Add(big.NewFloat(17))
+// randomFloats returns n random floats of both signs spanning many magnitudes, including subnormals.
+func randomFloats(r *rand.Rand, n int) []float64 {
+	xs := make([]float64, n)
+	for i := range xs {
+		x := math.Ldexp(r.Float64(), r.Intn(2124)-1100) // Up to 2^1024, exclusive.
+		if r.Intn(2) == 0 {
+			x = -x
+		}
+		xs[i] = x
+	}
+	return xs
+}
 
-	for i := 0; i < N; i++ {
-		a.Add(big.NewFloat(eps))
+func TestMerge(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, k := range []int{1, 2, 3, 7, 16} {
+		xs := randomFloats(r, 10000)
+		// Make sure some bins overflow and borrow.
+		for i := 0; i < 5000; i++ {
+			xs = append(xs, math.MaxFloat64, -math.MaxFloat64/3, 1, -1.5)
+		}
+		r.Shuffle(len(xs), func(i, j int) { xs[i], xs[j] = xs[j], xs[i] })
+		var single Sum
+		for _, x := range xs {
+			single.Add(x)
+		}
+		var merged Sum
+		chunk := (len(xs) + k - 1) / k
+		for i := 0; i < len(xs); i += chunk {
+			var part Sum
+			for _, x := range xs[i:min(i+chunk, len(xs))] {
+				part.Add(x)
+			}
+			merged.Merge(&part)
+		}
+		if merged != single {
+			t.Fatalf("k=%d: merged accumulator differs from the single one", k)
+		}
+		want, _ := single.BigVal()
+		got, _ := merged.BigVal()
+		if got.Cmp(want) != 0 {
+			t.Fatalf("k=%d: expected %s, got %s", k, want.String(), got.String())
+		}
 	}
-	a.Add(big.NewFloat(-17))
-	diff := big.NewFloat(eps * N)
-	diff.Sub(diff, a.BigVal())
-	if diff.Abs(diff).Cmp(big.NewFloat(eps/1000)) != -1 {
-		t.Fatalf("exptected %s and %f to be close", a.BigVal().String(), eps)
+}
+
+// fuzzAlphabet has the special values the fuzzer would rarely hit with random bits.
+var fuzzAlphabet = []float64{
+	0, math.Copysign(0, -1), 1, -1, 0.1, math.Inf(1), math.Inf(-1), math.NaN(),
+	math.SmallestNonzeroFloat64, -math.SmallestNonzeroFloat64, 0x1p-1022 - 0x1p-1074, 0x1p-1022,
+	math.MaxFloat64, -math.MaxFloat64, 0x1p1023, 0x1p52,
+}
+
+// fuzzFloats decodes data into floats: a byte below 2*len(fuzzAlphabet) picks a value from the alphabet,
+// any other byte takes the next 8 bytes as the bits of a float64.
+func fuzzFloats(data []byte) []float64 {
+	var xs []float64
+	for len(data) > 0 {
+		c := data[0]
+		data = data[1:]
+		if int(c) < 2*len(fuzzAlphabet) || len(data) < 8 {
+			xs = append(xs, fuzzAlphabet[int(c)%len(fuzzAlphabet)])
+			continue
+		}
+		xs = append(xs, math.Float64frombits(binary.LittleEndian.Uint64(data)))
+		data = data[8:]
 	}
+	return xs
 }
 
-func TestSumBig(t *testing.T) {
-	a := Big{}
-	a.Add(17)
+func FuzzSumOrderIndependent(f *testing.F) {
+	f.Add(int64(0), []byte{})
+	f.Add(int64(1), []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15})
+	f.Add(int64(2), []byte{12, 12, 13, 8, 9, 2, 3, 4, 4, 4})
+	f.Add(int64(3), []byte{0xff, 1, 2, 3, 4, 5, 6, 7, 8, 0xff, 0, 0, 0, 0, 0, 0, 0xf0, 0x7f})
+	f.Fuzz(func(t *testing.T, seed int64, data []byte) {
+		xs := fuzzFloats(data)
+		r := rand.New(rand.NewSource(seed))
+		var a, b, merged Sum
+		for _, x := range xs {
+			a.Add(x)
+		}
+		ys := slices.Clone(xs)
+		r.Shuffle(len(ys), func(i, j int) { ys[i], ys[j] = ys[j], ys[i] })
+		for _, x := range ys {
+			b.Add(x)
+		}
+		r.Shuffle(len(ys), func(i, j int) { ys[i], ys[j] = ys[j], ys[i] })
+		for len(ys) > 0 {
+			var part Sum
+			n := r.Intn(len(ys)) + 1
+			for _, x := range ys[:n] {
+				part.Add(x)
+			}
+			merged.Merge(&part)
+			ys = ys[n:]
+		}
+		if !a.Equal(&b) {
+			t.Fatalf("%v: the sum depends on the order", xs)
+		}
+		if !a.Equal(&merged) {
+			t.Fatalf("%v: the merged sum differs", xs)
+		}
+		if va, vm := a.Val(), merged.Val(); math.Float64bits(va) != math.Float64bits(vm) && !(math.IsNaN(va) && math.IsNaN(vm)) {
+			t.Fatalf("%v: expected %g, got %g", xs, va, vm)
+		}
+	})
+}
 
-	for i := 0; i < N; i++ {
-		a.Add(eps)
+func TestMergeInfs(t *testing.T) {
+	var a, b Sum
+	a.Add(math.Inf(1))
+	b.Add(math.Inf(-1))
+	a.Merge(&b)
+	if !math.IsNaN(a.Val()) {
+		t.Fatalf("expected nan, got %f", a.Val())
 	}
-	a.Add(-17)
-	if math.Abs(a.Val()) > math.SmallestNonzeroFloat64 {
-		t.Fatalf("exptected %s to be zero", big.NewFloat(a.Val()).String())
+	var c, d Sum
+	c.Add(1)
+	d.Add(math.NaN())
+	c.Merge(&d)
+	if !math.IsNaN(c.Val()) {
+		t.Fatalf("expected nan, got %f", c.Val())
 	}
 }
 
-func BenchmarkBig(b *testing.B) {
-	b.SetBytes(8)
-	a := Big{}
-	a.Add(17)
+func TestReset(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	var a Sum
+	for _, x := range randomFloats(r, 1000) {
+		a.Add(x)
+	}
+	a.Add(math.Inf(1))
+	a.Add(math.Inf(-1))
+	a.Add(math.NaN())
+	a.Reset()
+	if a != (Sum{}) {
+		t.Fatalf("expected reset accumulator to be zero")
+	}
+	xs := randomFloats(r, 1000)
+	var b Sum
+	for _, x := range xs {
+		a.Add(x)
+		b.Add(x)
+	}
+	if a != b {
+		t.Fatalf("expected reset accumulator to behave as a new one")
+	}
+}
 
-	for i := 0; i < b.N; i++ {
-		a.Add(eps)
+func TestValWithBound(t *testing.T) {
+	for _, tc := range []struct {
+		in          []float64
+		val, absErr float64
+	}{
+		{nil, 0, 0},
+		{[]float64{1, 2}, 3, 0},
+		{[]float64{1, 0x1p-60}, 1, 0x1p-60},
+		{[]float64{1, -0x1p-60}, 1, 0x1p-60},
+		{[]float64{1, 0x1p-53, 0x1p-60}, 1 + 0x1p-52, 0x1p-53 - 0x1p-60},
+		{[]float64{math.MaxFloat64, math.MaxFloat64}, math.Inf(1), math.Inf(1)},
+		{[]float64{math.Inf(-1), 1}, math.Inf(-1), 0},
+	} {
+		var a Sum
+		for _, x := range tc.in {
+			a.Add(x)
+		}
+		val, absErr := a.ValWithBound()
+		if val != tc.val || absErr != tc.absErr {
+			t.Fatalf("%v: expected %g ± %g, got %g ± %g", tc.in, tc.val, tc.absErr, val, absErr)
+		}
+	}
+	var a Sum
+	a.Add(math.NaN())
+	if val, absErr := a.ValWithBound(); !math.IsNaN(val) || !math.IsNaN(absErr) {
+		t.Fatalf("expected nans, got %g ± %g", val, absErr)
 	}
-	a.Add(-17)
 }
 
-func BenchmarkBF(b *testing.B) {
-	b.SetBytes(8)
-	a := bfAdder{}
-	a.Add(big.NewFloat(17))
+func TestValWithBoundRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(7))
+	for k := 0; k < 100; k++ {
+		var a Sum
+		want := new(big.Float).SetPrec(4096)
+		for _, x := range randomFloats(r, 100) {
+			a.Add(x)
+			want.Add(want, big.NewFloat(x))
+		}
+		val, absErr := a.ValWithBound()
+		diff := new(big.Float).SetPrec(4096).Sub(want, big.NewFloat(val))
+		diff.Abs(diff)
+		if diff.Cmp(big.NewFloat(absErr)) > 0 {
+			t.Fatalf("expected |%g - %s| <= %g", val, want.String(), absErr)
+		}
+		if absErr > math.Abs(val)*0x1p-53 && absErr > math.SmallestNonzeroFloat64 {
+			t.Fatalf("bound %g for %g is not tight", absErr, val)
+		}
+	}
+}
 
-	fe := big.NewFloat(1e-10)
-	for i := 0; i < b.N; i++ {
-		a.Add(fe)
+func TestExactRat(t *testing.T) {
+	for _, tc := range []struct {
+		in   []float64
+		want string
+	}{
+		{nil, "0"},
+		{[]float64{0.5, 0.25}, "3/4"},
+		{[]float64{1, 0x1p-60}, "1152921504606846977/1152921504606846976"},
+		{[]float64{0.1, 0.2}, "10808639105689191/36028797018963968"},
+		{[]float64{-3, 1e300, 0.5, -1e300}, "-5/2"},
+		{[]float64{math.SmallestNonzeroFloat64}, "1/" + new(big.Int).Lsh(big.NewInt(1), 1074).String()},
+		{[]float64{math.MaxFloat64, math.MaxFloat64}, new(big.Int).Lsh(big.NewInt(1<<53-1), 972).String()},
+	} {
+		var a Sum
+		for _, x := range tc.in {
+			a.Add(x)
+		}
+		got, nan := a.ExactRat()
+		want, _ := new(big.Rat).SetString(tc.want)
+		if nan || got.Cmp(want) != 0 {
+			t.Fatalf("%v: expected %s, got %s", tc.in, want.String(), got.String())
+		}
 	}
-	a.Add(big.NewFloat(-17))
 }
 
-func BenchmarkSum(b *testing.B) {
-	b.SetBytes(8)
-	a := Sum{}
-	a.Add(17)
-	for i := 0; i < b.N; i++ {
-		a.Add(-1e-10)
+func TestExactRatInfs(t *testing.T) {
+	var a Sum
+	a.Add(math.Inf(1))
+	if r, nan := a.ExactRat(); r != nil || nan {
+		t.Fatalf("expected nil, false for +Inf, got %v, %t", r, nan)
+	}
+	a.Add(math.Inf(-1))
+	if r, nan := a.ExactRat(); r != nil || !nan {
+		t.Fatalf("expected nil, true for NaN, got %v, %t", r, nan)
 	}
-	a.Add(-17)
 }
 
-var da Dumb
+func TestValRounded(t *testing.T) {
+	up := math.Nextafter(1, 2)
+	down := math.Nextafter(1, 0)
+	max := math.MaxFloat64
+	inf := math.Inf(1)
+	for _, tc := range []struct {
+		xs []float64
+		// Expected values, in big.RoundingMode order:
+		// ToNearestEven, ToNearestAway, ToZero, AwayFromZero, ToNegativeInf, ToPositiveInf.
+		want [6]float64
+	}{
+		{[]float64{1}, [6]float64{1, 1, 1, 1, 1, 1}},
+		{[]float64{1, 0x1p-60}, [6]float64{1, 1, 1, up, 1, up}},
+		{[]float64{-1, -0x1p-60}, [6]float64{-1, -1, -1, -up, -up, -1}},
+		{[]float64{1, -0x1p-60}, [6]float64{1, 1, down, 1, down, 1}},
+		// Ties.
+		{[]float64{1, 0x1p-53}, [6]float64{1, up, 1, up, 1, up}},
+		{[]float64{-1, -0x1p-53}, [6]float64{-1, -up, -1, -up, -up, -1}},
+		// Subnormal sums are exact.
+		{[]float64{0x1p-1000, -0x1p-1000, 3 * math.SmallestNonzeroFloat64}, [6]float64{
+			3 * math.SmallestNonzeroFloat64, 3 * math.SmallestNonzeroFloat64, 3 * math.SmallestNonzeroFloat64,
+			3 * math.SmallestNonzeroFloat64, 3 * math.SmallestNonzeroFloat64, 3 * math.SmallestNonzeroFloat64}},
+		// Overflow.
+		{[]float64{max, max}, [6]float64{inf, inf, max, inf, max, inf}},
+		{[]float64{-max, -max}, [6]float64{-inf, -inf, -max, -inf, -inf, -max}},
+		{[]float64{max, 0x1p960}, [6]float64{max, max, max, inf, max, inf}},
+		// Infs are not rounded.
+		{[]float64{inf, 1}, [6]float64{inf, inf, inf, inf, inf, inf}},
+	} {
+		var a Sum
+		a.AddAll(tc.xs)
+		for mode, want := range tc.want {
+			if got := a.ValRounded(big.RoundingMode(mode)); got != want {
+				t.Fatalf("%g, %v: expected %g, got %g", tc.xs, big.RoundingMode(mode), want, got)
+			}
+		}
+	}
+}
 
-func BenchmarkDumb(b *testing.B) {
-	b.SetBytes(8)
-	da = Dumb{}
-	da.Add(17)
-	for i := 0; i < b.N; i++ {
-		da.Add(-1e-10)
+func TestValRoundedRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(569))
+	for i := 0; i < 100; i++ {
+		var a Sum
+		a.AddAll(randomFloats(r, 100))
+		exact, _ := a.ExactRat()
+		lo, hi := a.ValRounded(big.ToNegativeInf), a.ValRounded(big.ToPositiveInf)
+		if a.ValRounded(big.ToNearestEven) != a.Val() ||
+			new(big.Rat).SetFloat64(lo).Cmp(exact) > 0 || new(big.Rat).SetFloat64(hi).Cmp(exact) < 0 ||
+			hi != lo && math.Nextafter(lo, hi) != hi {
+			t.Fatalf("expected neighbors around %s, got [%g, %g]", exact.FloatString(20), lo, hi)
+		}
 	}
-	da.Add(-17)
 }
 
-var a float64
+func TestRSub(t *testing.T) {
+	r := rand.New(rand.NewSource(579))
+	better := 0
+	for i := 0; i < 100; i++ {
+		var a Sum
+		a.AddAll(randomFloats(r, 100))
+		exact, _ := a.ExactRat()
+		// A target close to the sum: the residual is tiny relative to v.
+		v := math.Nextafter(a.Val(), math.Inf(r.Intn(2)*2-1))
+		want := new(big.Rat).Sub(new(big.Rat).SetFloat64(v), exact)
+		w, _ := want.Float64()
+		if got := a.RSub(v); got != w {
+			t.Fatalf("expected %g, got %g", w, got)
+		}
+		if d, nan := a.RSubBig(v); nan || d == nil {
+			t.Fatalf("expected a finite difference, got %v, %t", d, nan)
+		} else if dr, _ := d.Rat(nil); dr.Cmp(want) != 0 {
+			t.Fatalf("expected %s, got %s", want.String(), dr.String())
+		}
+		if v-a.Val() != w {
+			better++
+		}
+	}
+	if better == 0 {
+		t.Fatalf("expected RSub to be more accurate than v - Val() at least once")
+	}
+}
 
-func BenchmarkDirect(b *testing.B) {
-	b.SetBytes(8)
-	a = 17.0
-	for i := 0; i < b.N; i++ {
-		a += -1e-10
+func TestRSubSpecial(t *testing.T) {
+	negZero := math.Copysign(0, -1)
+	inf, nan := math.Inf(1), math.NaN()
+	for _, tc := range []struct {
+		xs   []float64
+		v    float64
+		want float64
+	}{
+		{nil, 1, 1},
+		{[]float64{1, 0x1p-60}, 1, -0x1p-60},
+		{[]float64{1}, 1, 0},
+		{nil, negZero, negZero},
+		{[]float64{inf}, 1, -inf},
+		{[]float64{inf}, inf, nan},
+		{[]float64{1}, -inf, -inf},
+		{[]float64{1}, nan, nan},
+		{[]float64{-inf, inf}, 1, nan},
+	} {
+		var a Sum
+		a.AddAll(tc.xs)
+		got := a.RSub(tc.v)
+		if math.IsNaN(got) != math.IsNaN(tc.want) || !math.IsNaN(got) && math.Float64bits(got) != math.Float64bits(tc.want) {
+			t.Fatalf("%g - %g: expected %g, got %g", tc.v, tc.xs, tc.want, got)
+		}
 	}
-	a -= 17
 }
 
-func BenchmarkKahan(b *testing.B) {
-	b.SetBytes(8)
-	a := Kahan{}
-	a.Add(17)
-	for i := 0; i < b.N; i++ {
-		a.Add(-1e-10)
+func TestValAcc(t *testing.T) {
+	inf := math.Inf(1)
+	for _, tc := range []struct {
+		xs   []float64
+		want float64
+		acc  big.Accuracy
+	}{
+		{nil, 0, big.Exact},
+		{[]float64{0.5, 0.25}, 0.75, big.Exact},
+		{[]float64{0.1, 0.2}, 0.30000000000000004, big.Above},
+		{[]float64{1, 0x1p-60}, 1, big.Below},
+		{[]float64{1, -0x1p-60}, 1, big.Above},
+		{[]float64{-1, 0x1p-60}, -1, big.Below},
+		{[]float64{math.MaxFloat64, math.MaxFloat64}, inf, big.Above},
+		{[]float64{-math.MaxFloat64, -math.MaxFloat64}, -inf, big.Below},
+		{[]float64{inf, 1}, inf, big.Exact},
+		{[]float64{inf, -inf}, math.NaN(), big.Exact},
+	} {
+		var a Sum
+		a.AddAll(tc.xs)
+		got, acc := a.ValAcc()
+		if got != tc.want && !(math.IsNaN(got) && math.IsNaN(tc.want)) || acc != tc.acc {
+			t.Fatalf("%g: expected %g (%v), got %g (%v)", tc.xs, tc.want, tc.acc, got, acc)
+		}
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	r := rand.New(rand.NewSource(585))
+	var a Sum
+	mass := 0.0
+	for i := 0; i < 1000; i++ {
+		x := 1024 + 1024*r.Float64()
+		a.Add(x)
+		mass += x
+	}
+	// A stray pair of huge terms that almost cancel out, and a subnormal.
+	a.Add(0x1.8p300)
+	a.Add(-0x1p300)
+	a.Add(math.SmallestNonzeroFloat64)
+	a.Add(math.Inf(1))
+	h := a.Histogram()
+	if len(h) != 3 || h[300] != 0x1p299 || h[-1023] != math.SmallestNonzeroFloat64 || math.Abs(h[10]-mass) > 1e-9*mass {
+		t.Fatalf("expected bins 10, 300 and -1023, got %v", h)
+	}
+	var b Sum
+	b.AddAll([]float64{1, -1, -3, 0})
+	if h := b.Histogram(); len(h) != 1 || h[1] != -3 {
+		t.Fatalf("expected -3 in bin 1, got %v", h)
 	}
-	a.Add(-17)
 }
 
-// Big adds numbers as big.Floats.
-type Big struct {
-	s *big.Float
+func TestPopulatedBins(t *testing.T) {
+	r := rand.New(rand.NewSource(594))
+	var a Sum
+	if a.PopulatedBins() != 0 {
+		t.Fatalf("expected no bins, got %d", a.PopulatedBins())
+	}
+	exps := map[int]bool{}
+	for i := 0; i < 1000; i++ {
+		e := r.Intn(200) - 100
+		a.Add(math.Ldexp(1+r.Float64(), e))
+		exps[e] = true
+	}
+	a.Add(0)
+	a.Add(math.NaN())
+	if a.PopulatedBins() != len(exps) || a.PopulatedBins() != len(a.Histogram()) {
+		t.Fatalf("expected %d bins, got %d", len(exps), a.PopulatedBins())
+	}
+	a.Add(math.SmallestNonzeroFloat64)
+	a.Add(0x1p1000)
+	a.Add(-0x1p1000)
+	if a.PopulatedBins() != len(exps)+1 {
+		t.Fatalf("expected %d bins, got %d", len(exps)+1, a.PopulatedBins())
+	}
 }
 
-func (b *Big) Add(v float64) {
-	if b.s == nil {
-		b.s = big.NewFloat(0)
+func TestValOverflow(t *testing.T) {
+	var a Sum
+	for i := 0; i < 100; i++ {
+		a.Add(1e307)
+	}
+	// 1e309 is out of the float64 range.
+	if v, over := a.ValOverflow(); !math.IsInf(v, 1) || !over {
+		t.Fatalf("expected +Inf, true, got %g, %t", v, over)
+	}
+	for i := 0; i < 99; i++ {
+		a.Add(-1e307)
+	}
+	if v, over := a.ValOverflow(); v != 1e307 || over {
+		t.Fatalf("expected 1e307, false, got %g, %t", v, over)
+	}
+	for i := 0; i < 200; i++ {
+		a.Add(-1e307)
+	}
+	if v, over := a.ValOverflow(); !math.IsInf(v, -1) || !over {
+		t.Fatalf("expected -Inf, true, got %g, %t", v, over)
+	}
+	// An inf summand is not an overflow.
+	a.Add(math.Inf(-1))
+	if v, over := a.ValOverflow(); !math.IsInf(v, -1) || over {
+		t.Fatalf("expected -Inf, false, got %g, %t", v, over)
+	}
+	a.Add(math.Inf(1))
+	if v, over := a.ValOverflow(); !math.IsNaN(v) || over {
+		t.Fatalf("expected NaN, false, got %g, %t", v, over)
 	}
-	b.s.Add(b.s, big.NewFloat(v))
 }
 
-func (b Big) Val() float64 {
-	f, _ := b.s.Float64()
-	return f
+func TestCondition(t *testing.T) {
+	for _, tc := range []struct {
+		in       []float64
+		min, max float64
+	}{
+		{nil, 1, 1},
+		{[]float64{0, math.Copysign(0, -1)}, 1, 1},
+		{[]float64{1, 2, 3, 4}, 1, 1},
+		{[]float64{-1, -2, -3}, 1, 1},
+		{[]float64{1, 2, -0.5}, 3.5 / 2.5, 3.5 / 2.5},
+		// Adversarial: naive summation in this order returns 0.
+		{[]float64{0x1p-52, 1000, 1000, 1000, 1000, 1000, -5000}, 1e19, 1e20},
+		{[]float64{1, -1}, math.Inf(1), math.Inf(1)},
+	} {
+		a := Sum{TrackCondition: true}
+		a.AddAll(tc.in)
+		if got := a.Condition(); got < tc.min || got > tc.max {
+			t.Fatalf("%v: expected condition in [%g, %g], got %g", tc.in, tc.min, tc.max, got)
+		}
+	}
+	var a Sum
+	a.Add(1)
+	if c := a.Condition(); !math.IsNaN(c) {
+		t.Fatalf("expected NaN without TrackCondition, got %g", c)
+	}
+	b := Sum{TrackCondition: true}
+	b.Add(math.Inf(1))
+	if c := b.Condition(); !math.IsNaN(c) {
+		t.Fatalf("expected NaN for an infinite sum, got %g", c)
+	}
 }
 
-// Dumb adds numbers as float64s.
-type Dumb struct {
-	float64
+func TestConditionMergeScale(t *testing.T) {
+	r := rand.New(rand.NewSource(58))
+	xs := make([]float64, 1000)
+	for i := range xs {
+		xs[i] = r.NormFloat64()
+	}
+	a, b, c := Sum{TrackCondition: true}, Sum{TrackCondition: true}, Sum{TrackCondition: true}
+	a.AddAll(xs)
+	b.AddAll(xs[:500])
+	c.AddAll(xs[500:])
+	b.Merge(&c)
+	if got, want := b.Condition(), a.Condition(); math.Abs(got-want) > want*1e-12 {
+		t.Fatalf("expected merged condition %g, got %g", want, got)
+	}
+	want := a.Condition()
+	a.Scale(-0x1p-10)
+	if got := a.Condition(); math.Abs(got-want) > want*1e-12 {
+		t.Fatalf("expected Scale to keep the condition %g, got %g", want, got)
+	}
+	// The low part of a product is not a separate summand.
+	d := Sum{TrackCondition: true}
+	d.AddWeighted(0.1, 3)
+	if got := d.Condition(); got != 1 {
+		t.Fatalf("expected condition 1, got %g", got)
+	}
 }
 
-func (d *Dumb) Add(v float64) {
-	d.float64 += v
+func TestSplitVal(t *testing.T) {
+	r := rand.New(rand.NewSource(54))
+	for _, in := range [][]float64{
+		nil,
+		{0.5, 0.25},
+		{1, 0x1p-60},
+		{0.1, 0.2},
+		{-3, 1e300, 0.5, -1e300},
+		{1e300, math.SmallestNonzeroFloat64},
+		randomFloats(r, 1000),
+	} {
+		var a Sum
+		for _, x := range in {
+			a.Add(x)
+		}
+		hi, res := a.SplitVal()
+		if hi != a.Val() {
+			t.Fatalf("%.3v: expected hi %g, got %g", in, a.Val(), hi)
+		}
+		want, _ := a.ExactRat()
+		got, _ := res.Rat(nil)
+		got.Add(got, new(big.Rat).SetFloat64(hi))
+		if got.Cmp(want) != 0 {
+			t.Fatalf("%.3v: expected hi+residual to be %s, got %s", in, want, got)
+		}
+		if exact := new(big.Rat).SetFloat64(hi).Cmp(want) == 0; exact != (res.Sign() == 0) {
+			t.Fatalf("%.3v: expected a zero residual iff the sum is exact, got %v", in, res)
+		}
+	}
+	for _, in := range [][]float64{
+		{math.Inf(1)},
+		{math.Inf(1), math.Inf(-1)},
+		{math.NaN()},
+		{math.MaxFloat64, math.MaxFloat64},
+	} {
+		var a Sum
+		for _, x := range in {
+			a.Add(x)
+		}
+		if hi, res := a.SplitVal(); res != nil || hi != a.Val() && !math.IsNaN(hi) {
+			t.Fatalf("%v: expected %g, nil, got %g, %v", in, a.Val(), hi, res)
+		}
+	}
 }
 
-func (d Dumb) Val() float64 {
-	return d.float64
+func TestInt64(t *testing.T) {
+	r := rand.New(rand.NewSource(30))
+	var a Sum
+	want := int64(0)
+	for i := 0; i < 1000; i++ {
+		x := r.Int63n(1<<53) - (1 << 50)
+		a.Add(float64(x))
+		want += x
+	}
+	if want < 1<<53 {
+		t.Fatalf("expected the sum to exceed 2^53, got %d", want)
+	}
+	got, ok := a.Int64()
+	if !ok || got != want {
+		t.Fatalf("expected %d, got %d (%t)", want, got, ok)
+	}
+	if int64(a.Val()) == want {
+		t.Fatalf("expected Val to round %d", want)
+	}
+	b, ok := a.BigInt()
+	if !ok || b.Cmp(big.NewInt(want)) != 0 {
+		t.Fatalf("expected %d, got %s (%t)", want, b.String(), ok)
+	}
+}
+
+func TestAddInt(t *testing.T) {
+	r := rand.New(rand.NewSource(573))
+	var a Sum
+	a.TrackMinMax = true
+	want := new(big.Int)
+	for _, x := range []int64{1<<53 + 1, 1<<53 + 1, math.MaxInt64, math.MinInt64, -3} {
+		a.AddInt(x)
+		want.Add(want, big.NewInt(x))
+	}
+	for i := 0; i < 1000; i++ {
+		x := r.Int63() - r.Int63()
+		a.AddInt(x)
+		want.Add(want, big.NewInt(x))
+	}
+	for i := 0; i < 10; i++ {
+		a.AddUint(math.MaxUint64)
+		a.AddInt(-1 << 62)
+		a.AddInt(-1 << 62)
+		a.AddInt(-1 << 62)
+		a.AddInt(-1 << 62)
+		a.AddInt(-1)
+		want.Add(want, big.NewInt(-2)) // 2^64 - 1 - 4*2^62 - 1.
+	}
+	a.AddTracked(0.5)
+	a.AddTracked(0.5)
+	want.Add(want, big.NewInt(1))
+	if got, ok := a.BigInt(); !ok || got.Cmp(want) != 0 {
+		t.Fatalf("expected %s, got %v (%t)", want.String(), got, ok)
+	}
+	if a.Count() != 5+1000+60+2 || a.Min() != math.MinInt64 || a.Max() != math.MaxUint64 {
+		t.Fatalf("unexpected count %d, min %g or max %g", a.Count(), a.Min(), a.Max())
+	}
+
+	var b Sum
+	b.AddInt(1<<53 + 1)
+	b.AddInt(1<<53 + 1)
+	b.AddUint(1)
+	if got, ok := b.Int64(); !ok || got != 1<<54+3 {
+		t.Fatalf("expected %d, got %d (%t)", int64(1<<54+3), got, ok)
+	}
+}
+
+func TestBigInt(t *testing.T) {
+	for _, tc := range []struct {
+		in      []float64
+		want    string
+		isInt64 bool
+	}{
+		{nil, "0", true},
+		{[]float64{math.Copysign(0, -1)}, "0", true},
+		{[]float64{0.5}, "", false},
+		{[]float64{0.5, 0.5}, "1", true},
+		{[]float64{1e300, 0.25, -1e300, 0.75}, "1", true},
+		{[]float64{3, math.SmallestNonzeroFloat64}, "", false},
+		{[]float64{-0x1p63}, "-9223372036854775808", true},
+		{[]float64{0x1p63}, "9223372036854775808", false},
+		{[]float64{0x1p100, 0x1p100}, "2535301200456458802993406410752", false},
+		{[]float64{1, math.Inf(1)}, "", false},
+		{[]float64{math.NaN()}, "", false},
+	} {
+		var a Sum
+		for _, x := range tc.in {
+			a.Add(x)
+		}
+		got, ok := a.BigInt()
+		if tc.want == "" {
+			if ok || got != nil {
+				t.Fatalf("%v: expected nil, false, got %v, %t", tc.in, got, ok)
+			}
+		} else if !ok || got.String() != tc.want {
+			t.Fatalf("%v: expected %s, got %v, %t", tc.in, tc.want, got, ok)
+		}
+		i, ok := a.Int64()
+		if ok != tc.isInt64 || ok && strconv.FormatInt(i, 10) != tc.want {
+			t.Fatalf("%v: expected %s, %t, got %d, %t", tc.in, tc.want, tc.isInt64, i, ok)
+		}
+	}
+}
+
+func TestScale(t *testing.T) {
+	r := rand.New(rand.NewSource(11))
+	for k := 0; k < 200; k++ {
+		var a Sum
+		for i := 0; i < 100; i++ {
+			a.Add(math.Ldexp(r.NormFloat64(), r.Intn(200)-100))
+		}
+		before, _ := a.ExactRat()
+		c := math.Ldexp(r.NormFloat64(), r.Intn(200)-100)
+		if k%2 == 0 {
+			c = math.Ldexp(1, r.Intn(1800)-900) // Power of two.
+		}
+		a.Scale(c)
+		got, _ := a.ExactRat()
+		want := new(big.Rat).Mul(before, new(big.Rat).SetFloat64(c))
+		if got.Cmp(want) != 0 {
+			t.Fatalf("expected %s, got %s", want.FloatString(20), got.FloatString(20))
+		}
+		if w, _ := want.Float64(); a.Val() != w {
+			t.Fatalf("expected %g, got %g", w, a.Val())
+		}
+	}
+}
+
+func TestScaleUnderflow(t *testing.T) {
+	var a Sum
+	a.Add(3 * math.SmallestNonzeroFloat64)
+	a.Scale(0.5)
+	if a.Val() != 2*math.SmallestNonzeroFloat64 {
+		t.Fatalf("expected %g, got %g", 2*math.SmallestNonzeroFloat64, a.Val())
+	}
+	a.Scale(-0x1p-10)
+	if a.Val() != 0 {
+		t.Fatalf("expected 0, got %g", a.Val())
+	}
+}
+
+func TestScaleOverflow(t *testing.T) {
+	var a Sum
+	a.Add(math.MaxFloat64)
+	a.Scale(0x1p30)
+	if !math.IsInf(a.Val(), 1) {
+		t.Fatalf("expected +Inf, got %g", a.Val())
+	}
+	// Still finite inside.
+	a.Scale(0x1p-30)
+	if a.Val() != math.MaxFloat64 {
+		t.Fatalf("expected %g, got %g", math.MaxFloat64, a.Val())
+	}
+	a.Scale(-0x1p100)
+	if !math.IsInf(a.Val(), -1) {
+		t.Fatalf("expected -Inf, got %g", a.Val())
+	}
+	a.Scale(0x1p-100)
+	if !math.IsInf(a.Val(), -1) {
+		t.Fatalf("expected -Inf to stay, got %g", a.Val())
+	}
+}
+
+func TestScaleSpecial(t *testing.T) {
+	for _, tc := range []struct {
+		in   []float64
+		c    float64
+		want float64
+	}{
+		{[]float64{1, 2}, 0, 0},
+		{[]float64{1, 2}, -2, -6},
+		{[]float64{1, 2}, math.Inf(1), math.Inf(1)},
+		{[]float64{1, 2}, math.Inf(-1), math.Inf(-1)},
+		{[]float64{1, -1}, math.Inf(1), math.NaN()},
+		{[]float64{1, 2}, math.NaN(), math.NaN()},
+		{[]float64{math.Inf(1), 2}, 0, math.NaN()},
+		{[]float64{math.Inf(1), 2}, -1, math.Inf(-1)},
+		{[]float64{math.Inf(-1), 2}, math.Inf(-1), math.Inf(1)},
+		{[]float64{math.Inf(-1), 2}, 3, math.Inf(-1)},
+	} {
+		var a Sum
+		for _, x := range tc.in {
+			a.Add(x)
+		}
+		a.Scale(tc.c)
+		got := a.Val()
+		if math.IsNaN(tc.want) != math.IsNaN(got) || !math.IsNaN(got) && got != tc.want {
+			t.Fatalf("%v * %g: expected %g, got %g", tc.in, tc.c, tc.want, got)
+		}
+	}
+}
+
+func TestPreserveSignedZero(t *testing.T) {
+	negZero := math.Copysign(0, -1)
+	for _, tc := range []struct {
+		in       []float64
+		negative bool
+	}{
+		{nil, false},
+		{[]float64{negZero}, true},
+		{[]float64{negZero, negZero, negZero}, true},
+		{[]float64{negZero, 0, negZero}, false},
+		{[]float64{0}, false},
+		{[]float64{negZero, 1, negZero, -1}, false},
+		{[]float64{negZero, math.SmallestNonzeroFloat64, -math.SmallestNonzeroFloat64}, false},
+		{[]float64{negZero, math.Inf(-1)}, false},
+	} {
+		a := Sum{PreserveSignedZero: true}
+		var b Sum
+		for _, x := range tc.in {
+			a.AddTracked(x)
+			b.AddTracked(x)
+		}
+		if a.Val() != 0 && !math.IsInf(a.Val(), 0) {
+			t.Fatalf("%v: expected zero, got %g", tc.in, a.Val())
+		}
+		if a.Val() == 0 && math.Signbit(a.Val()) != tc.negative {
+			t.Fatalf("%v: expected negative zero to be %t, got %g", tc.in, tc.negative, a.Val())
+		}
+		if b.Val() == 0 && math.Signbit(b.Val()) {
+			t.Fatalf("%v: expected +0 by default, got %g", tc.in, b.Val())
+		}
+	}
+}
+
+func TestPreserveSignedZeroReset(t *testing.T) {
+	a := Sum{PreserveSignedZero: true}
+	a.AddTracked(1)
+	a.Reset()
+	a.AddTracked(math.Copysign(0, -1))
+	if !math.Signbit(a.Val()) {
+		t.Fatalf("expected -0, got %g", a.Val())
+	}
+}
+
+func TestPreserveSignedZeroAccessors(t *testing.T) {
+	negZero := math.Copysign(0, -1)
+	a := Sum{PreserveSignedZero: true}
+	a.AddTracked(negZero)
+	if v, absErr := a.ValWithBound(); !math.Signbit(v) || v != 0 || absErr != 0 {
+		t.Fatalf("ValWithBound: expected -0 and 0, got %g and %g", v, absErr)
+	}
+	if got := a.String(); got != "-0 (exact)" {
+		t.Fatalf("String: expected -0 (exact), got %q", got)
+	}
+	if hi, residual := a.SplitVal(); !math.Signbit(hi) || hi != 0 || residual.Sign() != 0 {
+		t.Fatalf("SplitVal: expected -0 and 0, got %g and %g", hi, residual)
+	}
+
+	for _, tc := range []struct {
+		in       []float64
+		c        float64
+		negative bool
+	}{
+		{[]float64{negZero}, -2, false},
+		{[]float64{negZero}, 2, true},
+		{[]float64{0}, -2, true},
+		{[]float64{1, -1}, -2, true},
+		{[]float64{negZero}, negZero, false},
+		{[]float64{negZero}, 0, true},
+		{[]float64{3}, negZero, true},
+		{[]float64{-3}, 0, true},
+		{[]float64{-math.SmallestNonzeroFloat64}, 0.25, true},
+		{[]float64{math.SmallestNonzeroFloat64}, -0.25, true},
+	} {
+		a := Sum{PreserveSignedZero: true}
+		a.AddAll(tc.in)
+		a.Scale(tc.c)
+		if v := a.Val(); v != 0 || math.Signbit(v) != tc.negative {
+			t.Fatalf("%v * %g: expected negative zero to be %t, got %g", tc.in, tc.c, tc.negative, v)
+		}
+		if v, _ := a.ValWithBound(); math.Signbit(v) != tc.negative {
+			t.Fatalf("%v * %g: expected ValWithBound negative zero to be %t, got %g", tc.in, tc.c, tc.negative, v)
+		}
+	}
+}
+
+func TestZeroes(t *testing.T) {
+	negZero := math.Copysign(0, -1)
+	r := rand.New(rand.NewSource(596))
+	xs := slices.DeleteFunc(randomFloats(r, 1000), func(x float64) bool { return x == 0 }) // Some underflow.
+	xs = append(xs, math.SmallestNonzeroFloat64, -math.SmallestNonzeroFloat64)
+	var plain, zeroes, all Sum
+	zeroes.TrackMinMax = true
+	plain.AddAll(xs)
+	for i, x := range xs {
+		zeroes.AddTracked(x)
+		zeroes.AddTracked([]float64{0, negZero}[i%2])
+		zeroes.AddTracked(negZero)
+		all.AddAll([]float64{0, x, negZero})
+	}
+	if zeroes.mantissaLo != plain.mantissaLo || zeroes.mantissaHi != plain.mantissaHi ||
+		all.mantissaLo != plain.mantissaLo || all.mantissaHi != plain.mantissaHi {
+		t.Fatalf("expected zeroes not to touch the bins")
+	}
+	if zeroes.Count() != 3*len(xs) || zeroes.negZeros != len(xs)+len(xs)/2 || all.negZeros != len(xs) {
+		t.Fatalf("expected %d summands and %d negative zeroes, got %d and %d",
+			3*len(xs), len(xs)+len(xs)/2, zeroes.Count(), zeroes.negZeros)
+	}
+	if zeroes.Val() != plain.Val() || zeroes.Min() != slices.Min(xs) || zeroes.Max() != slices.Max(xs) {
+		t.Fatalf("expected %g, got %g", plain.Val(), zeroes.Val())
+	}
+	// Zeroes alone.
+	var z Sum
+	z.TrackMinMax = true
+	z.AddTracked(negZero)
+	z.AddTracked(negZero)
+	if z.PopulatedBins() != 0 || z.Count() != 2 || !math.Signbit(z.Min()) || z.Val() != 0 || math.Signbit(z.Val()) {
+		t.Fatalf("expected +0 of 2 summands with min -0, got %g of %d with min %g", z.Val(), z.Count(), z.Min())
+	}
+	z.PreserveSignedZero = true
+	if !math.Signbit(z.Val()) {
+		t.Fatalf("expected -0, got %g", z.Val())
+	}
+	z.AddTracked(0)
+	if z.PopulatedBins() != 0 || z.Count() != 3 || math.Signbit(z.Val()) {
+		t.Fatalf("expected +0 of 3 summands, got %g of %d", z.Val(), z.Count())
+	}
+}
+
+func TestNaNPayload(t *testing.T) {
+	const quiet = 0x7ff8_0000_0000_beef
+	const signaling = 0xfff0_0000_0000_dead
+	a := Sum{}
+	a.Add(1)
+	a.Add(math.Float64frombits(quiet))
+	a.Add(2)
+	a.Add(math.Float64frombits(signaling))
+	a.Add(math.Inf(1))
+	if got := math.Float64bits(a.Val()); got != quiet {
+		t.Fatalf("expected %#x, got %#x", uint64(quiet), got)
+	}
+
+	var b, c Sum
+	b.Add(math.Float64frombits(signaling))
+	c.Add(3)
+	c.Merge(&b)
+	c.Add(math.Float64frombits(quiet))
+	if got := math.Float64bits(c.Val()); got != signaling {
+		t.Fatalf("expected %#x, got %#x", uint64(signaling), got)
+	}
+}
+
+func TestString(t *testing.T) {
+	for _, tc := range []struct {
+		in   []float64
+		want string
+	}{
+		{nil, "0 (exact)"},
+		{[]float64{40, 2.5}, "42.5 (exact)"},
+		{[]float64{0.1, 0x1p-80}, "0.1 (approx)"},
+		{[]float64{math.MaxFloat64, math.MaxFloat64}, "+Inf (approx)"},
+		{[]float64{math.NaN(), 1, math.NaN(), math.Inf(1), math.NaN()}, "NaN (3 nan summands)"},
+		{[]float64{math.Inf(1), 1, math.Inf(1)}, "+Inf (2 +inf summands)"},
+		{[]float64{math.Inf(-1)}, "-Inf (1 -inf summands)"},
+		{[]float64{math.Inf(1), math.Inf(-1)}, "NaN (1 +inf, 1 -inf summands)"},
+	} {
+		var a Sum
+		for _, x := range tc.in {
+			a.Add(x)
+		}
+		if got := a.String(); got != tc.want {
+			t.Fatalf("%v: expected %q, got %q", tc.in, tc.want, got)
+		}
+	}
+	if got := fmt.Sprint(&Sum{}); got != "0 (exact)" {
+		t.Fatalf("expected %q, got %q", "0 (exact)", got)
+	}
+}
+
+func TestCmp(t *testing.T) {
+	sum := func(xs ...float64) *Sum {
+		var a Sum
+		for _, x := range xs {
+			a.Add(x)
+		}
+		return &a
+	}
+	ordered := []*Sum{
+		sum(math.Inf(-1), 1e300),
+		sum(-1e300, -1e300),
+		sum(-1, -0x1p-60),
+		sum(-1),
+		sum(0x1p-1074, -0x1p-1074),
+		sum(0x1p-1074),
+		sum(1, 0x1p-61),
+		sum(1, 0x1p-60),
+		sum(1, 0x1p-61, 0x1p-61, 0x1p-1074),
+		sum(1e300, 1e300),
+		sum(math.Inf(1)),
+		sum(math.NaN()),
+	}
+	for i, a := range ordered {
+		for j, b := range ordered {
+			want := 0
+			if i < j {
+				want = -1
+			} else if i > j {
+				want = 1
+			}
+			if got := a.Cmp(b); got != want {
+				t.Fatalf("%d vs %d: expected %d, got %d", i, j, want, got)
+			}
+		}
+	}
+	// Val can not tell these apart.
+	a, b := sum(1, 0x1p-60), sum(1, 0x1p-61)
+	if a.Val() != b.Val() || a.Cmp(b) != 1 {
+		t.Fatalf("expected Cmp to tell %s and %s apart", a, b)
+	}
+	// Same value, different order and representation.
+	if c := sum(1, 0x1p-60, 1e100, -1e100); c.Cmp(a) != 0 {
+		t.Fatalf("expected %s and %s to be equal", c, a)
+	}
+	if c := sum(math.Inf(1), math.Inf(-1)); c.Cmp(sum(math.NaN())) != 0 {
+		t.Fatalf("expected NaNs to be equal")
+	}
+}
+
+func TestAddBig(t *testing.T) {
+	one := big.NewFloat(1).SetPrec(200)
+	v := new(big.Float).SetPrec(200).SetMantExp(one, -199)
+	v.Add(v, one) // 1 + 2^-199.
+	w := new(big.Float).SetPrec(300).SetMantExp(one, 1023)
+	w.Sub(w, new(big.Float).SetMantExp(one, 1000)) // 2^1023 - 2^1000 does not fit float64.
+	w.Add(w, new(big.Float).SetMantExp(one, -1074))
+	var a Sum
+	a.AddBig(v)
+	a.AddTracked(-1)
+	a.AddBig(w)
+	a.AddBig(new(big.Float).Neg(w))
+	got, _ := a.ExactRat()
+	want, _ := new(big.Float).SetMantExp(one, -199).Rat(nil)
+	if got.Cmp(want) != 0 {
+		t.Fatalf("expected %s, got %s", want.String(), got.String())
+	}
+	if a.Count() != 4 {
+		t.Fatalf("expected 4 summands, got %d", a.Count())
+	}
+	var b Sum
+	b.AddBig(v)
+	got, _ = b.ExactRat()
+	want, _ = v.Rat(nil)
+	if got.Cmp(want) != 0 {
+		t.Fatalf("expected %s, got %s", want.String(), got.String())
+	}
+}
+
+func TestAddSquared(t *testing.T) {
+	r := rand.New(rand.NewSource(25))
+	var a Sum
+	want := new(big.Rat)
+	for i := 0; i < 10000; i++ {
+		// Squares neither overflow nor get close to subnormals.
+		x := math.Ldexp(r.Float64()+0.5, r.Intn(900)-450)
+		if r.Intn(2) == 0 {
+			x = -x
+		}
+		a.AddSquared(x)
+		xr := new(big.Rat).SetFloat64(x)
+		want.Add(want, xr.Mul(xr, xr))
+	}
+	got, _ := a.ExactRat()
+	if got.Cmp(want) != 0 {
+		t.Fatalf("expected %s, got %s", want.FloatString(20), got.FloatString(20))
+	}
+	if a.Count() != 10000 {
+		t.Fatalf("expected 10000 summands, got %d", a.Count())
+	}
+	f, _ := want.Float64()
+	if a.Val() != f {
+		t.Fatalf("expected %g, got %g", f, a.Val())
+	}
+}
+
+func TestAddSquaredNaive(t *testing.T) {
+	// (1+2^-30)^2 = 1 + 2^-29 + 2^-60, the last bit is lost by v*v.
+	// The two small squares add 2^-53, so the naive sum is a tie and rounds down,
+	// while the exact sum is above the tie.
+	xs := []float64{1 + 0x1p-30, 0x1p-27, 0x1p-27}
+	var a, naive Sum
+	for _, x := range xs {
+		a.AddSquared(x)
+		naive.Add(x * x)
+	}
+	want := 1 + 0x1p-29 + 0x1p-52
+	if a.Val() != want {
+		t.Fatalf("expected %g, got %g", want, a.Val())
+	}
+	if naive.Val() == want {
+		t.Fatalf("expected the naive sum of squares to be off")
+	}
+	if got := math.Sqrt(a.Val()); got != math.Sqrt(want) {
+		t.Fatalf("expected %g, got %g", math.Sqrt(want), got)
+	}
+}
+
+func TestAddSquaredSpecial(t *testing.T) {
+	for _, tc := range []struct {
+		v    float64
+		want float64
+	}{
+		{math.Inf(1), math.Inf(1)},
+		{math.Inf(-1), math.Inf(1)},
+		{1e200, math.Inf(1)},
+		{-1e200, math.Inf(1)},
+		{math.NaN(), math.NaN()},
+		{math.Copysign(0, -1), 0},
+		{math.SmallestNonzeroFloat64, 0},
+		{0x1p-537, math.SmallestNonzeroFloat64},
+	} {
+		a := Sum{TrackMinMax: true}
+		a.AddSquared(tc.v)
+		got := a.Val()
+		if got != tc.want && !(math.IsNaN(got) && math.IsNaN(tc.want)) {
+			t.Fatalf("%g: expected %g, got %g", tc.v, tc.want, got)
+		}
+		if got != a.Min() && !math.IsNaN(got) {
+			t.Fatalf("%g: expected min %g, got %g", tc.v, got, a.Min())
+		}
+	}
+}
+
+func TestAddN(t *testing.T) {
+	negZero := math.Copysign(0, -1)
+	for _, v := range []float64{
+		0, negZero, 1, -1, 0.1, -math.MaxFloat64, math.SmallestNonzeroFloat64, -0x1p-1022 + 0x1p-1074,
+		math.Inf(1), math.Inf(-1), math.NaN(),
+	} {
+		for _, k := range []uint64{0, 1, 2, 1000} {
+			var a, b Sum
+			a.TrackMinMax, b.TrackMinMax = true, true
+			a.TrackCondition, b.TrackCondition = true, true
+			a.AddTracked(3)
+			b.AddTracked(3)
+			a.AddN(v, k)
+			for i := uint64(0); i < k; i++ {
+				b.AddTracked(v)
+			}
+			if !a.Equal(&b) || a.Min() != b.Min() && !math.IsNaN(a.Min()) || a.Max() != b.Max() && !math.IsNaN(a.Max()) {
+				t.Fatalf("AddN(%g, %d) differs from %d Adds", v, k, k)
+			}
+			if ca, cb := a.Condition(), b.Condition(); math.Abs(ca-cb) > 1e-9*cb {
+				t.Fatalf("AddN(%g, %d): expected condition %g, got %g", v, k, cb, ca)
+			}
+		}
+	}
+}
+
+func TestAddNOverflow(t *testing.T) {
+	// The product of the mantissa and k does not fit into 64 bits, and the bin carries.
+	for _, v := range []float64{1, -1, math.Ldexp(1-0x1p-53, 500), math.SmallestNonzeroFloat64, -0x1p-1022 + 0x1p-1074} {
+		var a Sum
+		want := new(big.Rat)
+		for _, k := range []uint64{math.MaxUint64, 1<<63 + 5, math.MaxUint64, 12345} {
+			a.AddN(v, k)
+			want.Add(want, new(big.Rat).Mul(new(big.Rat).SetFloat64(v), new(big.Rat).SetInt(new(big.Int).SetUint64(k))))
+		}
+		got, nan := a.ExactRat()
+		if nan || got.Cmp(want) != 0 {
+			t.Fatalf("%g: expected %s, got %v", v, want.String(), got)
+		}
+	}
+}
+
+func TestAdd2(t *testing.T) {
+	r := rand.New(rand.NewSource(575))
+	var a, b Sum
+	want := new(big.Rat)
+	for i := 0; i < 1000; i++ {
+		x, y := r.NormFloat64()*1e10, r.NormFloat64()
+		a.Add2(TwoProduct(x, y))
+		hi, lo := TwoProduct(x, y)
+		b.AddTracked(hi)
+		b.AddTracked(lo)
+		want.Add(want, new(big.Rat).Mul(new(big.Rat).SetFloat64(x), new(big.Rat).SetFloat64(y)))
+	}
+	if got, _ := a.ExactRat(); got.Cmp(want) != 0 {
+		t.Fatalf("expected %s, got %s", want.String(), got.String())
+	}
+	if !a.Equal(&b) {
+		t.Fatalf("expected Add2 to be the same as two Adds")
+	}
+}
+
+func TestAddWeighted(t *testing.T) {
+	r := rand.New(rand.NewSource(53))
+	var a Sum
+	var naive float64
+	exact := new(big.Rat)
+	for i := 0; i < 10000; i++ {
+		// Large weights on small values and small weights on large values, cancelling each other out.
+		w := math.Ldexp(1+r.Float64(), r.Intn(200)-100)
+		x := math.Ldexp(r.Float64(), -r.Intn(200)+100)
+		if r.Intn(2) == 0 {
+			x = -x
+		}
+		a.AddWeighted(w, x)
+		naive += w * x
+		exact.Add(exact, new(big.Rat).Mul(new(big.Rat).SetFloat64(w), new(big.Rat).SetFloat64(x)))
+	}
+	want, _ := exact.Float64()
+	if got := a.Val(); got != want {
+		t.Fatalf("expected %g, got %g (naive %g)", want, got, naive)
+	}
+	if v, _ := a.BigVal(); v.Cmp(new(big.Float).SetPrec(0).SetRat(exact)) != 0 {
+		t.Fatalf("expected the exact sum %v, got %v", exact.FloatString(20), v)
+	}
+	if a.Count() != 10000 {
+		t.Fatalf("expected 10000 summands, got %d", a.Count())
+	}
+}
+
+func TestAddWeightedSpecial(t *testing.T) {
+	inf, nan := math.Inf(1), math.NaN()
+	for _, tc := range []struct {
+		w, x float64
+		want float64
+	}{
+		{0, 5, 0},
+		{5, 0, 0},
+		{0, inf, nan},
+		{-2, inf, -inf},
+		{inf, -inf, -inf},
+		{nan, 1, nan},
+		{1e200, -1e200, -inf},
+		{3, 0.1, 0.30000000000000004}, // Rounded once, like float64 multiplication.
+	} {
+		var a Sum
+		a.AddWeighted(tc.w, tc.x)
+		got := a.Val()
+		if got != tc.want && !(math.IsNaN(got) && math.IsNaN(tc.want)) {
+			t.Fatalf("%g*%g: expected %g, got %g", tc.w, tc.x, tc.want, got)
+		}
+	}
+}
+
+func TestAddWeightedTracked(t *testing.T) {
+	r := rand.New(rand.NewSource(553))
+	a := Sum{TrackMinMax: true, TrackCondition: true}
+	var b Sum
+	var min float64
+	for i := 0; i < 1000; i++ {
+		// Some of the low parts are subnormal.
+		w, x := math.Ldexp(1+r.Float64(), -r.Intn(600)), -math.Ldexp(1+r.Float64(), -r.Intn(600))
+		a.AddWeighted(w, x)
+		b.AddWeighted(w, x)
+		min = math.Min(min, w*x)
+	}
+	if !a.TrackMinMax || !a.TrackCondition {
+		t.Fatalf("expected AddWeighted to keep the options")
+	}
+	if !a.Equal(&b) {
+		t.Fatalf("expected tracking not to affect the sum")
+	}
+	if a.Min() != min || math.Abs(a.Condition()-1) > 1e-12 || a.Count() != 1000 {
+		t.Fatalf("expected min %g, condition 1 and 1000 summands, got %g, %g and %d", min, a.Min(), a.Condition(), a.Count())
+	}
+}
+
+func TestAddBigRounding(t *testing.T) {
+	half := new(big.Float).SetMantExp(big.NewFloat(1), -1075)
+	for _, tc := range []struct {
+		v    *big.Float
+		want float64
+	}{
+		{half, math.SmallestNonzeroFloat64},
+		{new(big.Float).Neg(half), -math.SmallestNonzeroFloat64},
+		{new(big.Float).Mul(half, big.NewFloat(0.75)), 0},
+		{new(big.Float).Mul(half, big.NewFloat(-3)), -2 * math.SmallestNonzeroFloat64},
+		{new(big.Float).Mul(half, big.NewFloat(5)), 3 * math.SmallestNonzeroFloat64},
+	} {
+		var a Sum
+		a.AddBig(tc.v)
+		if a.Val() != tc.want {
+			t.Fatalf("%s: expected %g, got %g", tc.v.String(), tc.want, a.Val())
+		}
+	}
+}
+
+func TestAddBigSpecial(t *testing.T) {
+	huge := new(big.Float).SetMantExp(big.NewFloat(1), 1024)
+	for _, tc := range []struct {
+		v    *big.Float
+		want float64
+	}{
+		{new(big.Float).SetInf(false), math.Inf(1)},
+		{new(big.Float).SetInf(true), math.Inf(-1)},
+		{huge, math.Inf(1)},
+		{new(big.Float).Neg(huge), math.Inf(-1)},
+		{big.NewFloat(math.MaxFloat64), math.MaxFloat64},
+	} {
+		var a Sum
+		a.AddBig(tc.v)
+		if a.Val() != tc.want {
+			t.Fatalf("%s: expected %g, got %g", tc.v.String(), tc.want, a.Val())
+		}
+	}
+	a := Sum{PreserveSignedZero: true}
+	a.AddBig(new(big.Float).Neg(new(big.Float)))
+	if a.Count() != 1 || !math.Signbit(a.Val()) {
+		t.Fatalf("expected -0, got %g", a.Val())
+	}
+}
+
+func TestAddAll(t *testing.T) {
+	r := rand.New(rand.NewSource(32))
+	xs := randomFloats(r, 100000)
+	for _, x := range []float64{0, math.Copysign(0, -1), math.SmallestNonzeroFloat64, -0x1p-1050,
+		math.Inf(1), math.Inf(-1), math.NaN(), math.MaxFloat64, -math.MaxFloat64} {
+		xs[r.Intn(len(xs))] = x
+	}
+	for _, track := range []bool{false, true} {
+		a := Sum{TrackMinMax: track}
+		b := Sum{TrackMinMax: track}
+		for _, x := range xs {
+			a.AddTracked(x)
+		}
+		b.AddAll(xs[:len(xs)/2])
+		b.AddAll(xs[len(xs)/2:])
+		if a != b {
+			t.Fatalf("expected AddAll to match Add")
+		}
+	}
+}
+
+func TestEqualOrderIndependent(t *testing.T) {
+	r := rand.New(rand.NewSource(33))
+	xs := randomFloats(r, 1000)
+	// Adversarial: values cancelling each other out, carries and borrows in the bins.
+	for i := 0; i < 100; i++ {
+		x := math.Ldexp(1-r.Float64()/2, r.Intn(2000)-1000)
+		xs = append(xs, x, x, -x, math.MaxFloat64, -math.MaxFloat64, math.SmallestNonzeroFloat64)
+	}
+	xs = append(xs, 0, math.Copysign(0, -1), math.Inf(1), math.Inf(-1), math.Inf(1), math.NaN(), math.NaN())
+	var want Sum
+	want.AddAll(xs)
+	for i := 0; i < 100; i++ {
+		r.Shuffle(len(xs), func(i, j int) { xs[i], xs[j] = xs[j], xs[i] })
+		var a Sum
+		for _, x := range xs {
+			a.AddTracked(x)
+		}
+		if !a.Equal(&want) {
+			t.Fatalf("expected sums of permutations to be equal")
+		}
+	}
+}
+
+func TestClone(t *testing.T) {
+	r := rand.New(rand.NewSource(60))
+	a := Sum{TrackMinMax: true, TrackCondition: true}
+	a.AddAll(randomFloats(r, 1000))
+	c := a.Clone()
+	if !c.Equal(&a) || *c != a {
+		t.Fatalf("expected the clone to be identical")
+	}
+	want := a.Val()
+	c.Add(1e300)
+	c.Add(math.NaN())
+	if a.Val() != want || a.NaNs() != 0 {
+		t.Fatalf("expected the original to be unaffected by the clone, got %g", a.Val())
+	}
+}
+
+func TestEqual(t *testing.T) {
+	sum := func(xs ...float64) *Sum {
+		a := &Sum{}
+		a.AddAll(xs)
+		return a
+	}
+	for _, tc := range []struct {
+		a, b  *Sum
+		equal bool
+	}{
+		{sum(), sum(), true},
+		{sum(1, 2), sum(3), false}, // Different count.
+		{sum(1, 2), sum(2, 1), true},
+		{sum(0.5, 0.5), sum(1, 0), false},          // Different bins.
+		{sum(0), sum(math.Copysign(0, -1)), false}, // Different negZeros.
+		{sum(math.Inf(1)), sum(math.Inf(-1)), false},
+		{sum(math.NaN()), sum(-math.NaN()), true},
+	} {
+		if tc.a.Equal(tc.b) != tc.equal || tc.b.Equal(tc.a) != tc.equal {
+			t.Fatalf("%s and %s: expected Equal to be %t", tc.a, tc.b, tc.equal)
+		}
+	}
+}
+
+func TestBinOverflow(t *testing.T) {
+	big1 := math.Ldexp(1-0x1p-53, 100)
+	for _, tc := range []struct {
+		hi int32
+		lo uint64
+		v  float64
+	}{
+		// A bin about to overflow, as if 2^42 values were added to it.
+		{math.MaxInt32, math.MaxUint64 - 1<<52, big1},
+		{math.MinInt32, 1 << 52, -big1},
+		{math.MaxInt32, math.MaxUint64, math.Ldexp(1, 30)},
+		// Subnormals.
+		{math.MaxInt32, math.MaxUint64, math.SmallestNonzeroFloat64},
+		{math.MinInt32, 0, -math.SmallestNonzeroFloat64},
+	} {
+		exp := math.Float64bits(math.Abs(tc.v)) >> mantissaBits
+		for _, addAll := range []bool{false, true} {
+			var a Sum
+			a.mantissaHi[exp] = tc.hi
+			a.mantissaLo[exp] = tc.lo
+			// The bin it carries to overflows as well.
+			to := max(exp, 1) + 64
+			a.mantissaHi[to] = math.MaxInt32
+			a.mantissaLo[to] = math.MaxUint64
+			if tc.hi < 0 {
+				a.mantissaHi[to] = math.MinInt32
+				a.mantissaLo[to] = 0
+			}
+			want, _ := a.ExactRat()
+			want.Add(want, new(big.Rat).SetFloat64(tc.v))
+			if addAll {
+				a.AddAll([]float64{tc.v})
+			} else {
+				a.Add(tc.v)
+			}
+			if a.mantissaHi[exp] == tc.hi {
+				t.Fatalf("%g: expected the bin to carry over", tc.v)
+			}
+			got, nan := a.ExactRat()
+			if nan || got.Cmp(want) != 0 {
+				t.Fatalf("%g: expected %s, got %v", tc.v, want.String(), got)
+			}
+		}
+	}
+}
+
+func TestBinOverflowMerge(t *testing.T) {
+	// Doubling the sum by merging it into itself 62 times is the same as adding every value 2^62 times:
+	// the bins carry over many times, the sum must stay exact.
+	const doublings = 62
+	for _, xs := range [][]float64{
+		{1},
+		{-1},
+		{math.Ldexp(1-0x1p-53, 100), -3, 0.1},
+		{math.SmallestNonzeroFloat64, -0x1p-1022 + 0x1p-1074},
+		{math.Ldexp(1, 900), -math.Ldexp(1, 836), 1e-300},
+	} {
+		var a Sum
+		a.AddAll(xs)
+		want, _ := a.ExactRat()
+		want.Mul(want, new(big.Rat).SetInt(new(big.Int).Lsh(big.NewInt(1), doublings)))
+		for i := 0; i < doublings; i++ {
+			a.Merge(a.Clone())
+		}
+		got, nan := a.ExactRat()
+		if nan || got.Cmp(want) != 0 {
+			t.Fatalf("%g: expected %s, got %v", xs, want.FloatString(20), got)
+		}
+	}
+}
+
+func TestBinOverflowScale(t *testing.T) {
+	var a Sum
+	a.Add(1)
+	a.mantissaHi[1023] = math.MaxInt32
+	a.mantissaLo[1023] = math.MaxUint64
+	want, _ := a.ExactRat()
+	a.Merge(a.Clone())
+	want.Add(want, want)
+	if got, nan := a.ExactRat(); nan || got.Cmp(want) != 0 {
+		t.Fatalf("expected %s, got %v", want.String(), got)
+	}
+	a.Scale(-1)
+	want.Neg(want)
+	if got, nan := a.ExactRat(); nan || got.Cmp(want) != 0 {
+		t.Fatalf("expected %s, got %v", want.String(), got)
+	}
+}
+
+func TestTopBinOverflow(t *testing.T) {
+	var a Sum
+	a.mantissaHi[topBin] = math.MaxInt32
+	a.mantissaLo[topBin] = math.MaxUint64
+	a.Add(math.MaxFloat64)
+	if !math.IsNaN(a.Val()) {
+		t.Fatalf("expected NaN, got %g", a.Val())
+	}
+}
+
+func TestMinMax(t *testing.T) {
+	negZero := math.Copysign(0, -1)
+	nan := math.NaN()
+	for _, tc := range []struct {
+		in       []float64
+		min, max float64
+	}{
+		{nil, nan, nan},
+		{[]float64{nan, nan}, nan, nan},
+		{[]float64{3}, 3, 3},
+		{[]float64{nan, 3, -2, nan, 1e300, -math.SmallestNonzeroFloat64}, -2, 1e300},
+		{[]float64{math.SmallestNonzeroFloat64, 2 * math.SmallestNonzeroFloat64}, math.SmallestNonzeroFloat64, 2 * math.SmallestNonzeroFloat64},
+		{[]float64{math.Inf(1), 1, math.Inf(-1)}, math.Inf(-1), math.Inf(1)},
+		{[]float64{math.Inf(1), 1}, 1, math.Inf(1)},
+		{[]float64{math.Inf(-1)}, math.Inf(-1), math.Inf(-1)},
+		{[]float64{negZero, 0}, negZero, negZero},
+		{[]float64{0, negZero}, 0, 0},
+	} {
+		a := Sum{TrackMinMax: true}
+		for _, x := range tc.in {
+			a.AddTracked(x)
+		}
+		same := func(x, y float64) bool {
+			return math.Float64bits(x) == math.Float64bits(y) || math.IsNaN(x) && math.IsNaN(y)
+		}
+		if !same(a.Min(), tc.min) || !same(a.Max(), tc.max) {
+			t.Fatalf("%v: expected min %g and max %g, got %g and %g", tc.in, tc.min, tc.max, a.Min(), a.Max())
+		}
+	}
+}
+
+func TestMinMaxExact(t *testing.T) {
+	r := rand.New(rand.NewSource(23))
+	xs := randomFloats(r, 10000)
+	a := Sum{TrackMinMax: true}
+	var b Sum
+	c := Sum{TrackMinMax: true}
+	for i, x := range xs {
+		a.AddTracked(x)
+		b.AddTracked(x)
+		if i%2 == 0 {
+			c.AddTracked(x)
+		}
+	}
+	if a.mantissaLo != b.mantissaLo || a.mantissaHi != b.mantissaHi || a.Val() != b.Val() {
+		t.Fatalf("expected tracking min and max not to affect the sum")
+	}
+	if !math.IsNaN(b.Min()) || !math.IsNaN(b.Max()) {
+		t.Fatalf("expected NaN without TrackMinMax, got %g and %g", b.Min(), b.Max())
+	}
+	d := Sum{TrackMinMax: true}
+	for i := 1; i < len(xs); i += 2 {
+		d.AddTracked(xs[i])
+	}
+	c.Merge(&d)
+	if c.Min() != slices.Min(xs) || c.Max() != slices.Max(xs) || a.Min() != c.Min() || a.Max() != c.Max() {
+		t.Fatalf("expected min %g and max %g, got %g and %g", slices.Min(xs), slices.Max(xs), c.Min(), c.Max())
+	}
+}
+
+func TestMergeUntracked(t *testing.T) {
+	a := Sum{TrackMinMax: true, TrackCondition: true}
+	a.AddTracked(1)
+	var b Sum
+	b.AddTracked(-5)
+	a.Merge(&b)
+	a.AddTracked(7)
+	if a.Val() != 3 {
+		t.Fatalf("expected 3, got %g", a.Val())
+	}
+	if !math.IsNaN(a.Min()) || !math.IsNaN(a.Max()) || !math.IsNaN(a.Condition()) {
+		t.Fatalf("expected unknown min, max and condition, got %g, %g and %g", a.Min(), a.Max(), a.Condition())
+	}
+	a.Reset()
+	a.AddTracked(2)
+	if a.Min() != 2 || a.Max() != 2 || a.Condition() != 1 {
+		t.Fatalf("expected Reset to track again, got %g, %g and %g", a.Min(), a.Max(), a.Condition())
+	}
+	// An empty b changes nothing.
+	var empty Sum
+	a.Merge(&empty)
+	if a.Min() != 2 || a.Max() != 2 || a.Condition() != 1 {
+		t.Fatalf("expected an empty merge to keep tracking, got %g, %g and %g", a.Min(), a.Max(), a.Condition())
+	}
+}
+
+func TestSumBF(t *testing.T) {
+	a := BigSum{}
+	a.Add(big.NewFloat(17))
+
+	for i := 0; i < N; i++ {
+		a.Add(big.NewFloat(eps))
+	}
+	a.Add(big.NewFloat(-17))
+	diff := big.NewFloat(eps * N)
+	diff.Sub(diff, a.BigVal())
+	if diff.Abs(diff).Cmp(big.NewFloat(eps/1000)) != -1 {
+		t.Fatalf("exptected %s and %f to be close", a.BigVal().String(), eps)
+	}
+}
+
+func TestSumBig(t *testing.T) {
+	a := Big{}
+	a.Add(17)
+
+	for i := 0; i < N; i++ {
+		a.Add(eps)
+	}
+	a.Add(-17)
+	if math.Abs(a.Val()) > math.SmallestNonzeroFloat64 {
+		t.Fatalf("exptected %s to be zero", big.NewFloat(a.Val()).String())
+	}
+}
+
+func BenchmarkBig(b *testing.B) {
+	b.SetBytes(8)
+	a := Big{}
+	a.Add(17)
+
+	for i := 0; i < b.N; i++ {
+		a.Add(eps)
+	}
+	a.Add(-17)
+}
+
+func BenchmarkBF(b *testing.B) {
+	b.SetBytes(8)
+	a := BigSum{}
+	a.Add(big.NewFloat(17))
+
+	fe := big.NewFloat(1e-10)
+	for i := 0; i < b.N; i++ {
+		a.Add(fe)
+	}
+	a.Add(big.NewFloat(-17))
+}
+
+func BenchmarkSum(b *testing.B) {
+	b.SetBytes(8)
+	a := Sum{}
+	a.Add(17)
+	for i := 0; i < b.N; i++ {
+		a.Add(-1e-10)
+	}
+	a.Add(-17)
+}
+
+var sumSink *Sum
+
+func BenchmarkSumAddLoop(b *testing.B) {
+	xs := randomFloats(rand.New(rand.NewSource(32)), 1000000)
+	b.SetBytes(8 * int64(len(xs)))
+	var a Sum
+	for i := 0; i < b.N; i++ {
+		for _, x := range xs {
+			a.Add(x)
+		}
+	}
+}
+
+func BenchmarkSumAddAll(b *testing.B) {
+	xs := randomFloats(rand.New(rand.NewSource(32)), 1000000)
+	b.SetBytes(8 * int64(len(xs)))
+	var a Sum
+	for i := 0; i < b.N; i++ {
+		a.AddAll(xs)
+	}
+}
+
+// BenchmarkSumClone shows the cost of copying a Sum, which is the same as passing it by value.
+func BenchmarkSumClone(b *testing.B) {
+	var a Sum
+	a.Add(1)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sumSink = a.Clone()
+	}
+}
+
+func BenchmarkSumNew(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sumSink = &Sum{}
+		sumSink.Add(17)
+	}
+}
+
+func BenchmarkSumReset(b *testing.B) {
+	b.ReportAllocs()
+	sumSink = &Sum{}
+	for i := 0; i < b.N; i++ {
+		sumSink.Reset()
+		sumSink.Add(17)
+	}
+}
+
+var da Dumb
+
+func BenchmarkDumb(b *testing.B) {
+	b.SetBytes(8)
+	da = Dumb{}
+	da.Add(17)
+	for i := 0; i < b.N; i++ {
+		da.Add(-1e-10)
+	}
+	da.Add(-17)
+}
+
+var a float64
+
+func BenchmarkDirect(b *testing.B) {
+	b.SetBytes(8)
+	a = 17.0
+	for i := 0; i < b.N; i++ {
+		a += -1e-10
+	}
+	a -= 17
+}
+
+func BenchmarkKahan(b *testing.B) {
+	b.SetBytes(8)
+	a := Kahan{}
+	a.Add(17)
+	for i := 0; i < b.N; i++ {
+		a.Add(-1e-10)
+	}
+	a.Add(-17)
+}
+
+func BenchmarkNeumaier(b *testing.B) {
+	b.SetBytes(8)
+	a := Neumaier{}
+	a.Add(17)
+	for i := 0; i < b.N; i++ {
+		a.Add(-1e-10)
+	}
+	a.Add(-17)
+}
+
+func BenchmarkSortedKahan(b *testing.B) {
+	b.SetBytes(8)
+	a := SortedKahan{}
+	a.Add(17)
+	for i := 0; i < b.N; i++ {
+		a.Add(-1e-10)
+	}
+	a.Add(-17)
+}
+
+func BenchmarkKlein(b *testing.B) {
+	b.SetBytes(8)
+	a := Klein{}
+	a.Add(17)
+	for i := 0; i < b.N; i++ {
+		a.Add(-1e-10)
+	}
+	a.Add(-17)
 }
@@ -0,0 +1,135 @@
+package sum
+
+import (
+	"math"
+	"math/big"
+)
+
+// Summer is implemented by all the accumulators in this package.
+// Lets the code be generic over the summation algorithm.
+type Summer interface {
+	Add(v float64)
+	Val() float64
+}
+
+// BigSummer is a Summer that can return the sum with more precision than float64.
+// BigVal returns (sum, isNaN), sum is nil if the sum is NaN.
+type BigSummer interface {
+	Summer
+	BigVal() (*big.Float, bool)
+}
+
+var (
+	_ BigSummer = (*Sum)(nil)
+	_ BigSummer = (*SparseSum)(nil)
+	_ BigSummer = (*ConcurrentSum)(nil)
+	_ BigSummer = (*ClampSum)(nil)
+	_ BigSummer = (*Kahan)(nil)
+	_ BigSummer = (*Neumaier)(nil)
+	_ BigSummer = (*SortedKahan)(nil)
+	_ BigSummer = (*Klein)(nil)
+	_ BigSummer = (*Big)(nil)
+	_ BigSummer = (*Dumb)(nil)
+)
+
+// bigVal returns v as BigVal does.
+func bigVal(v float64) (*big.Float, bool) {
+	if math.IsNaN(v) {
+		return nil, true
+	}
+	return big.NewFloat(v), false
+}
+
+// Big adds numbers as big.Floats.
+// Every addition is rounded to the precision of float64, but the exponent does not overflow.
+// Panics on NaNs.
+type Big struct {
+	s *big.Float
+}
+
+// Add a float64 value.
+func (b *Big) Add(v float64) {
+	if b.s == nil {
+		b.s = big.NewFloat(0)
+	}
+	b.s.Add(b.s, big.NewFloat(v))
+}
+
+// Val returns the current sum as float64.
+func (b Big) Val() float64 {
+	if b.s == nil {
+		return 0
+	}
+	f, _ := b.s.Float64()
+	return f
+}
+
+// BigVal returns the current sum, see BigSummer.
+// The sum is never NaN: Big panics on NaNs.
+func (b Big) BigVal() (*big.Float, bool) {
+	if b.s == nil {
+		return new(big.Float), false
+	}
+	return new(big.Float).Set(b.s), false
+}
+
+// Reset sets the sum to zero.
+func (b *Big) Reset() {
+	b.s = nil
+}
+
+// Dumb adds numbers as float64s.
+// Here as a baseline: it is the fastest and the least accurate.
+type Dumb struct {
+	float64
+}
+
+// Add a float64 value.
+func (d *Dumb) Add(v float64) {
+	d.float64 += v
+}
+
+// Val returns the current sum.
+func (d Dumb) Val() float64 {
+	return d.float64
+}
+
+// BigVal returns the current sum, see BigSummer.
+func (d Dumb) BigVal() (*big.Float, bool) {
+	return bigVal(d.float64)
+}
+
+// Reset sets the sum to zero.
+func (d *Dumb) Reset() {
+	d.float64 = 0
+}
+
+// recommendedTol is the relative error Recommended aims for: about 10 significant digits.
+const recommendedTol = 0x1p-32
+
+// Recommended returns the lightest accumulator likely to sum up expectedN values
+// with the largest to the smallest magnitude ratio of dynamicRange to a relative error below 2^-32.
+// The heuristic: with n summands spanning the range r, cancellation can make the sum as small as the smallest one,
+// so the condition number sum(|x|)/|sum(x)| is up to c = n*r. With the unit roundoff u = 2^-53,
+// the relative error bounds are (2u + n*u^2)*c for Kahan, u + n*u^2*c for Neumaier and u + n^2*u^3*c for Klein,
+// the first one that is below the target is picked, Sum (exact) otherwise.
+// Returns a *Kahan, a *Neumaier, a *Klein or a *Sum.
+// A dynamicRange that is not a finite number >= 1 means the range is unknown, which gives a *Sum.
+// Only Sum handles infs properly, use it if the summands can be infinite.
+func Recommended(expectedN int, dynamicRange float64) Summer {
+	if !(dynamicRange >= 1) || math.IsInf(dynamicRange, 0) {
+		return new(Sum)
+	}
+	const u = 0x1p-53
+	n := float64(max(expectedN, 1))
+	c := n * dynamicRange
+	switch {
+	case (2*u+n*u*u)*c <= recommendedTol:
+		return new(Kahan)
+	case u+n*u*u*c <= recommendedTol:
+		return new(Neumaier)
+	case u+n*n*u*u*u*c <= recommendedTol:
+		return new(Klein)
+	}
+	return new(Sum)
+}
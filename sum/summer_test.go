@@ -0,0 +1,186 @@
+package sum
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+// u is the unit roundoff of float64.
+const u = 0x1p-53
+
+func TestSummers(t *testing.T) {
+	// Adversarial: large values cancelling each other out, mixed with small ones.
+	r := rand.New(rand.NewSource(26))
+	var xs []float64
+	for i := 0; i < 1000; i++ {
+		x := math.Ldexp(r.Float64(), r.Intn(100)-50)
+		if r.Intn(2) == 0 {
+			x = -x
+		}
+		xs = append(xs, x)
+		if i%10 == 0 {
+			big := math.Ldexp(1+r.Float64(), 60+r.Intn(40))
+			xs = append(xs, big, x, -big)
+		}
+	}
+	exact := new(big.Rat)
+	var abs Sum
+	for _, x := range xs {
+		exact.Add(exact, new(big.Rat).SetFloat64(x))
+		abs.Add(math.Abs(x))
+	}
+	want, _ := exact.Float64()
+	absSum := abs.Val()
+	n := float64(len(xs))
+
+	for _, tc := range []struct {
+		name string
+		s    Summer
+		// bound on the absolute error, 0 means correctly rounded.
+		bound float64
+	}{
+		{"Sum", &Sum{}, 0},
+		{"SparseSum", &SparseSum{}, 0},
+		{"ConcurrentSum", NewConcurrentSum(4), 0},
+		{"Neumaier", &Neumaier{}, u*math.Abs(want) + 2*n*u*u*absSum},
+		{"Klein", &Klein{}, u*math.Abs(want) + 2*n*u*u*absSum},
+		{"Kahan", &Kahan{}, (2*u + 2*n*u*u) * absSum},
+		{"Big", &Big{}, (n - 1) * u * absSum},
+		{"Dumb", &Dumb{}, (n - 1) * u * absSum},
+	} {
+		for _, x := range xs {
+			tc.s.Add(x)
+		}
+		got := tc.s.Val()
+		if tc.bound == 0 {
+			if got != want {
+				t.Fatalf("%s: expected %g, got %g", tc.name, want, got)
+			}
+		} else {
+			diff := new(big.Rat).SetFloat64(got)
+			diff.Sub(diff, exact)
+			if f, _ := diff.Abs(diff).Float64(); f > tc.bound {
+				t.Fatalf("%s: expected error below %g, got %g", tc.name, tc.bound, f)
+			}
+		}
+		if b, ok := tc.s.(BigSummer); ok && tc.bound == 0 {
+			v, nan := b.BigVal()
+			if nan {
+				t.Fatalf("%s: expected a finite sum", tc.name)
+			}
+			if f, _ := v.Float64(); f != want {
+				t.Fatalf("%s: expected %g, got %g", tc.name, want, f)
+			}
+		}
+	}
+}
+
+func TestSummersBigValReset(t *testing.T) {
+	for _, s := range []interface {
+		BigSummer
+		Reset()
+	}{&Sum{}, &Kahan{}, &Neumaier{}, &Klein{}, &Big{}, &Dumb{}} {
+		for _, x := range []float64{1.5, -0.25, 0x1p60} {
+			s.Add(x)
+		}
+		v, nan := s.BigVal()
+		if f, _ := v.Float64(); nan || f != s.Val() {
+			t.Fatalf("%T: expected BigVal to be %g, got %v, %t", s, s.Val(), v, nan)
+		}
+		s.Reset()
+		if v, _ := s.BigVal(); s.Val() != 0 || v.Sign() != 0 {
+			t.Fatalf("%T: expected 0 after Reset, got %g, %v", s, s.Val(), v)
+		}
+	}
+}
+
+func TestBigZero(t *testing.T) {
+	var b Big
+	if b.Val() != 0 {
+		t.Fatalf("expected 0, got %g", b.Val())
+	}
+	if v, nan := b.BigVal(); nan || v.Sign() != 0 {
+		t.Fatalf("expected 0, got %v, %t", v, nan)
+	}
+}
+
+func TestSummersLadder(t *testing.T) {
+	// A huge value keeps the small ones out of the running sum, then cancels out.
+	// The first-order compensation accumulates the 1s, and is too large to keep the tiny values,
+	// only the second-order compensation of Klein keeps them.
+	const k = 1000
+	xs := []float64{0x1p100}
+	for i := 0; i < k; i++ {
+		xs = append(xs, 1, 0x1p-60)
+	}
+	xs = append(xs, -0x1p100, -k)
+	want := k * 0x1p-60
+
+	errs := make(map[string]float64)
+	for _, tc := range []struct {
+		name string
+		s    Summer
+	}{
+		{"Dumb", &Dumb{}},
+		{"Kahan", &Kahan{}},
+		{"Neumaier", &Neumaier{}},
+		{"Klein", &Klein{}},
+		{"Sum", &Sum{}},
+	} {
+		for _, x := range xs {
+			tc.s.Add(x)
+		}
+		errs[tc.name] = math.Abs(tc.s.Val() - want)
+	}
+	if errs["Sum"] != 0 || errs["Klein"] > u*want {
+		t.Fatalf("expected Sum to be exact and Klein to be accurate, got errors %v", errs)
+	}
+	if errs["Neumaier"] < want/2 {
+		t.Fatalf("expected Neumaier to lose the tiny values, got errors %v", errs)
+	}
+	if errs["Dumb"] < errs["Kahan"] || errs["Kahan"] < errs["Neumaier"] {
+		t.Fatalf("expected Dumb <= Kahan <= Neumaier in accuracy, got errors %v", errs)
+	}
+}
+
+func TestRecommended(t *testing.T) {
+	for _, tc := range []struct {
+		n    int
+		r    float64
+		want string
+	}{
+		// Benign: few summands of about the same magnitude.
+		{1000, 10, "*sum.Kahan"},
+		{0, 1, "*sum.Kahan"},
+		{1e6, 1e6, "*sum.Neumaier"},
+		{1e6, 1e20, "*sum.Klein"},
+		// Adversarial cancellation, as in TestSummersLadder.
+		{2002, 0x1p160, "*sum.Sum"},
+		// Unknown range.
+		{10, math.Inf(1), "*sum.Sum"},
+		{10, math.NaN(), "*sum.Sum"},
+		{10, 0, "*sum.Sum"},
+	} {
+		if got := fmt.Sprintf("%T", Recommended(tc.n, tc.r)); got != tc.want {
+			t.Fatalf("n=%d, range=%g: expected %s, got %s", tc.n, tc.r, tc.want, got)
+		}
+	}
+}
+
+func TestRecommendedAccuracy(t *testing.T) {
+	r := rand.New(rand.NewSource(566))
+	const n = 1000
+	s := Recommended(n, 10)
+	var exact Sum
+	for i := 0; i < n; i++ {
+		x := (1 + 9*r.Float64()) * float64(1-2*r.Intn(2))
+		s.Add(x)
+		exact.Add(x)
+	}
+	if err := math.Abs(s.Val()-exact.Val()) / math.Abs(exact.Val()); err > recommendedTol {
+		t.Fatalf("%T: expected the relative error below %g, got %g", s, recommendedTol, err)
+	}
+}
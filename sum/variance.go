@@ -0,0 +1,90 @@
+package sum
+
+import (
+	"math"
+	"math/big"
+)
+
+// Variance computes mean, variance and standard deviation of float64 numbers.
+// Keeps exact sums of the values and of their squares, so it does not suffer from cancellation
+// even if the mean is large relative to the spread.
+// The squares are exact as long as they do not overflow or underflow float64
+// (roughly, for 1e-145 < |v| < 1e154).
+// A square that overflows (|v| > ~1.34e154) makes the variance +Inf.
+// Infs and NaNs make the variance NaN.
+type Variance struct {
+	m  Mean
+	sq Sum // Sum of squares.
+}
+
+// Add a float64 value.
+func (v *Variance) Add(x float64) {
+	v.m.Add(x)
+	if math.IsInf(x, 0) || math.IsNaN(x) {
+		return
+	}
+	v.sq.AddSquared(x)
+}
+
+// Count returns the number of finite values added.
+func (v *Variance) Count() int {
+	return v.m.Count()
+}
+
+// Mean returns the mean of the values, see Mean.Val.
+func (v *Variance) Mean() float64 {
+	return v.m.Val()
+}
+
+// Variance returns the population variance.
+// Returns NaN if no values were added.
+func (v *Variance) Variance() float64 {
+	return v.moment(0)
+}
+
+// SampleVariance returns the sample (unbiased) variance.
+// Returns NaN if less than two values were added.
+func (v *Variance) SampleVariance() float64 {
+	return v.moment(1)
+}
+
+// StdDev returns the population standard deviation.
+func (v *Variance) StdDev() float64 {
+	return math.Sqrt(v.Variance())
+}
+
+// moment returns (n*sum(x^2) - sum(x)^2) / (n*(n-ddof)).
+func (v *Variance) moment(ddof int) float64 {
+	s := &v.m.s
+	n := s.count
+	if s.nans != 0 || s.plusInfs != 0 || s.minusInfs != 0 || n-ddof <= 0 || v.sq.nans != 0 {
+		return math.NaN()
+	}
+	if v.sq.plusInfs != 0 {
+		// A square overflowed, the bins do not have it.
+		return math.Inf(1)
+	}
+	return scaledQuo(comoment(n, s, s, &v.sq), n, n-ddof)
+}
+
+// comoment returns n*sum(xy) - sum(x)*sum(y) scaled by 2^(-2*minExp).
+// The sums are scaled by 2^-minExp, so
+// n*sum(xy) - sum(x)*sum(y) == (n*xy*2^-minExp - x*y) * 2^(2*minExp).
+func comoment(n int, x, y, xy *Sum) *big.Int {
+	p := x.scaledInt()
+	p.Mul(p, y.scaledInt())
+	q := xy.scaledInt()
+	q.Mul(q, big.NewInt(int64(n)))
+	q.Lsh(q, -minExp)
+	return q.Sub(q, p)
+}
+
+// scaledQuo returns q*2^(2*minExp) / (a*b), see comoment.
+func scaledQuo(q *big.Int, a, b int) float64 {
+	f := new(big.Float).SetInt(q)
+	f.SetMantExp(f, 2*minExp)
+	d := new(big.Int).Mul(big.NewInt(int64(a)), big.NewInt(int64(b)))
+	f.Quo(f, new(big.Float).SetInt(d))
+	r, _ := f.Float64()
+	return r
+}
@@ -0,0 +1,98 @@
+package sum
+
+import (
+	"math"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+func TestVarianceLargeOffset(t *testing.T) {
+	r := rand.New(rand.NewSource(5))
+	const n = 100000
+	xs := make([]float64, n)
+	var v Variance
+	mean := new(big.Float).SetPrec(4096)
+	for i := range xs {
+		xs[i] = 1e9 + (r.Float64()*2-1)*1e-3
+		v.Add(xs[i])
+		mean.Add(mean, big.NewFloat(xs[i]))
+	}
+	mean.Quo(mean, big.NewFloat(n))
+	m2 := new(big.Float).SetPrec(4096)
+	for _, x := range xs {
+		d := new(big.Float).SetPrec(4096).Sub(big.NewFloat(x), mean)
+		m2.Add(m2, d.Mul(d, d))
+	}
+	want, _ := new(big.Float).Quo(m2, big.NewFloat(n)).Float64()
+	wantSample, _ := new(big.Float).Quo(m2, big.NewFloat(n-1)).Float64()
+	wantMean, _ := mean.Float64()
+
+	if got := v.Mean(); got != wantMean {
+		t.Fatalf("expected mean %g, got %g", wantMean, got)
+	}
+	if got := v.Variance(); math.Abs(got-want) > want*1e-15 {
+		t.Fatalf("expected variance %g, got %g", want, got)
+	}
+	if got := v.SampleVariance(); math.Abs(got-wantSample) > wantSample*1e-15 {
+		t.Fatalf("expected sample variance %g, got %g", wantSample, got)
+	}
+	if got := v.StdDev(); math.Abs(got-math.Sqrt(want)) > math.Sqrt(want)*1e-15 {
+		t.Fatalf("expected std dev %g, got %g", math.Sqrt(want), got)
+	}
+
+	// The textbook formula falls apart.
+	var s, sq Dumb
+	for _, x := range xs {
+		s.Add(x)
+		sq.Add(x * x)
+	}
+	naive := sq.Val()/n - (s.Val()/n)*(s.Val()/n)
+	if math.Abs(naive-want) < want {
+		t.Fatalf("expected naive variance %g to be far from %g", naive, want)
+	}
+}
+
+func TestVarianceEdgeCases(t *testing.T) {
+	var v Variance
+	if !math.IsNaN(v.Variance()) || !math.IsNaN(v.SampleVariance()) || !math.IsNaN(v.Mean()) {
+		t.Fatalf("expected nans for empty Variance")
+	}
+	v.Add(3)
+	if v.Variance() != 0 {
+		t.Fatalf("expected zero variance of a single value, got %g", v.Variance())
+	}
+	if !math.IsNaN(v.SampleVariance()) {
+		t.Fatalf("expected nan sample variance of a single value, got %g", v.SampleVariance())
+	}
+	v.Add(5)
+	if v.Variance() != 1 || v.SampleVariance() != 2 || v.StdDev() != 1 {
+		t.Fatalf("expected variance 1 and sample variance 2, got %g and %g", v.Variance(), v.SampleVariance())
+	}
+	v.Add(math.Inf(1))
+	if !math.IsNaN(v.Variance()) {
+		t.Fatalf("expected nan, got %g", v.Variance())
+	}
+}
+
+func TestVarianceOverflow(t *testing.T) {
+	var v Variance
+	for _, x := range []float64{1e200, 1e200, 3e200} {
+		v.Add(x)
+	}
+	if !math.IsInf(v.Variance(), 1) || !math.IsInf(v.SampleVariance(), 1) || !math.IsInf(v.StdDev(), 1) {
+		t.Fatalf("expected +Inf, got %g, %g and %g", v.Variance(), v.SampleVariance(), v.StdDev())
+	}
+	if v.Mean() != 5e200/3 {
+		t.Fatalf("expected mean %g, got %g", 5e200/3, v.Mean())
+	}
+	// Just below the overflow the squares are exact.
+	v = Variance{}
+	for _, x := range []float64{1e153, 1e153, 3e153} {
+		v.Add(x)
+	}
+	want := 8e306 / 9 // ((2e153)^2 * 2 + (4e153)^2) / 27.
+	if got := v.Variance(); math.IsInf(got, 0) || math.Abs(got-want) > want*1e-15 {
+		t.Fatalf("expected %g, got %g", want, got)
+	}
+}
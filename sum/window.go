@@ -0,0 +1,78 @@
+package sum
+
+import (
+	"math"
+	"math/big"
+)
+
+// Window keeps the exact sum of the last n float64 values.
+// Evicted values are subtracted exactly, so the sum does not drift no matter how many values are pushed.
+// Like Sum, it handles infs and NaNs: they affect the sum only while they are in the window.
+// Size is ~24Kb plus 8 bytes per value.
+type Window struct {
+	s    Sum
+	buf  []float64
+	next int // Index of the oldest value once buf is full.
+}
+
+// NewWindow creates a new Window over the last n values.
+// Panics if n <= 0.
+func NewWindow(n int) *Window {
+	if n <= 0 {
+		panic("sum: window size must be positive")
+	}
+	return &Window{buf: make([]float64, 0, n)}
+}
+
+// Push adds v to the window, evicting the oldest value if the window is full.
+func (w *Window) Push(v float64) {
+	if len(w.buf) < cap(w.buf) {
+		w.buf = append(w.buf, v)
+	} else {
+		w.s.remove(w.buf[w.next])
+		w.buf[w.next] = v
+		w.next = (w.next + 1) % len(w.buf)
+	}
+	w.s.AddTracked(v)
+}
+
+// Len returns the number of values in the window.
+// It is below n until n values are pushed.
+func (w *Window) Len() int {
+	return len(w.buf)
+}
+
+// Val returns the sum of the values in the window as float64, see Sum.Val.
+// The payload of a NaN result may come from a NaN that was already evicted.
+func (w *Window) Val() float64 {
+	return w.s.Val()
+}
+
+// BigVal returns the sum of the values in the window as (sum *big.Float, isNan bool) pair, see Sum.BigVal.
+func (w *Window) BigVal() (*big.Float, bool) {
+	return w.s.BigVal()
+}
+
+// remove undoes Add(v), v must have been added before.
+// Does not undo min/max tracking.
+func (a *Sum) remove(v float64) {
+	switch {
+	case math.IsNaN(v):
+		a.nans--
+		if a.nans == 0 {
+			a.nanBits = 0
+		}
+	case math.IsInf(v, 1):
+		a.plusInfs--
+	case math.IsInf(v, -1):
+		a.minusInfs--
+	case v == 0:
+		a.count--
+		if math.Signbit(v) {
+			a.negZeros--
+		}
+	default:
+		a.Add(-v)
+		a.count--
+	}
+}
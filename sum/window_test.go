@@ -0,0 +1,79 @@
+package sum
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestWindow(t *testing.T) {
+	const n = 1000
+	r := rand.New(rand.NewSource(31))
+	xs := randomFloats(r, 1000000)
+	w := NewWindow(n)
+	naive := 0.0
+	for i, x := range xs {
+		w.Push(x)
+		naive += x
+		if i >= n {
+			naive -= xs[i-n]
+		}
+		if want := min(i+1, n); w.Len() != want {
+			t.Fatalf("expected length %d, got %d", want, w.Len())
+		}
+		if i%10007 != 0 && i != len(xs)-1 {
+			continue
+		}
+		var a Sum
+		for _, x := range xs[max(0, i-n+1) : i+1] {
+			a.AddTracked(x)
+		}
+		if w.s.mantissaLo != a.mantissaLo || w.s.mantissaHi != a.mantissaHi || w.s.count != a.count {
+			t.Fatalf("%d: expected the window to match the sum of its values", i)
+		}
+		if w.Val() != a.Val() {
+			t.Fatalf("%d: expected %g, got %g", i, a.Val(), w.Val())
+		}
+	}
+	if naive == w.Val() {
+		t.Fatalf("expected the naive window to drift")
+	}
+}
+
+func TestWindowSpecial(t *testing.T) {
+	w := NewWindow(2)
+	nan := math.NaN()
+	for _, tc := range []struct {
+		push float64
+		want float64
+	}{
+		{math.Inf(1), math.Inf(1)},
+		{1, math.Inf(1)},
+		{math.Inf(-1), math.Inf(-1)},
+		{nan, nan},
+		{math.Copysign(0, -1), nan},
+		{2, 2},
+		{math.Inf(1), math.Inf(1)},
+		{math.Inf(-1), nan},
+		{3, math.Inf(-1)},
+		{4, 7},
+	} {
+		w.Push(tc.push)
+		got := w.Val()
+		if got != tc.want && !(math.IsNaN(got) && math.IsNaN(tc.want)) {
+			t.Fatalf("after %g: expected %g, got %g", tc.push, tc.want, got)
+		}
+	}
+	if w.s.count != 2 || w.s.nans != 0 || w.s.negZeros != 0 || w.s.plusInfs != 0 || w.s.minusInfs != 0 {
+		t.Fatalf("expected the counters to only reflect the window")
+	}
+}
+
+func TestWindowPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected a panic")
+		}
+	}()
+	NewWindow(0)
+}